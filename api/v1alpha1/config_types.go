@@ -2,52 +2,66 @@
 // api/v1alpha1/config_types.go
 package v1alpha1
 
+// GroupVersion is the apiVersion of config files of this version. Files
+// without an apiVersion are read as v1alpha1.
+const GroupVersion = "expressops/v1alpha1"
+
 // Config represents the root configuration structure for the application
 type Config struct {
-	Logging LoggingConfig `yaml:"logging"`
-	Server  ServerConfig  `yaml:"server"`
-	Plugins []Plugin      `yaml:"plugins"`
-	Flows   []Flow        `yaml:"flows"`
+	APIVersion string        `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string        `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Logging    LoggingConfig `yaml:"logging" json:"logging"`
+	Server     ServerConfig  `yaml:"server" json:"server"`
+	Plugins    []Plugin      `yaml:"plugins" json:"plugins"`
+	Flows      []Flow        `yaml:"flows" json:"flows"`
 }
 
 // LoggingConfig represents the logging-related configuration options
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
+	Level  string `yaml:"level" json:"level" enum:"trace,debug,info,warn,warning,error,fatal,panic"`
+	Format string `yaml:"format" json:"format" enum:"text,json"`
 }
 
 // ServerConfig represents the server-related configuration options
 type ServerConfig struct {
-	Port       int        `yaml:"port" default:"8080"`
-	Address    string     `yaml:"address" default:"0.0.0.0"`
-	TimeoutSec int        `yaml:"timeoutSeconds" default:"4"`
-	HTTP       HTTPConfig `yaml:"http"`
+	Port       int        `yaml:"port" json:"port" default:"8080"`
+	Address    string     `yaml:"address" json:"address" default:"0.0.0.0"`
+	TimeoutSec int        `yaml:"timeoutSeconds" json:"timeoutSeconds" default:"4"`
+	HTTP       HTTPConfig `yaml:"http" json:"http"`
+	Auth       AuthConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
 }
 
 // HTTPConfig represents HTTP-specific configuration settings
 type HTTPConfig struct {
-	ProtocolVersion int `yaml:"protocolVersion"`
+	ProtocolVersion int `yaml:"protocolVersion" json:"protocolVersion"`
+}
+
+// AuthConfig represents the authentication settings of the /api/v1 endpoints.
+// When no tokens are configured the API is left open.
+type AuthConfig struct {
+	Tokens []string `yaml:"tokens,omitempty" json:"tokens,omitempty"`
 }
 
 // Plugin represents a plugin configuration entry
 type Plugin struct {
-	Name   string                 `yaml:"name"`
-	Path   string                 `yaml:"path"`
-	Type   string                 `yaml:"type"`
-	Config map[string]interface{} `yaml:"config"`
+	Name   string                 `yaml:"name" json:"name" required:"true"`
+	Path   string                 `yaml:"path" json:"path"`
+	Type   string                 `yaml:"type" json:"type"`
+	Config map[string]interface{} `yaml:"config" json:"config"`
 }
 
 // Flow represents a workflow definition
 type Flow struct {
-	Name          string `yaml:"name"`
-	CustomHandler string `yaml:"customHandler,omitempty"`
-	Pipeline      []Step `yaml:"pipeline"`
+	Name          string `yaml:"name" json:"name" required:"true"`
+	Description   string `yaml:"description,omitempty" json:"description,omitempty"`
+	CustomHandler string `yaml:"customHandler,omitempty" json:"customHandler,omitempty"`
+	Pipeline      []Step `yaml:"pipeline" json:"pipeline" required:"true"`
 }
 
 // Step represents each step in a flow pipeline
 type Step struct {
-	PluginRef  string                 `yaml:"pluginRef"`
-	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
-	Parallel   bool                   `yaml:"parallel,omitempty"`
-	DependsOn  []string               `yaml:"dependsOn,omitempty"`
+	PluginRef  string                 `yaml:"pluginRef" json:"pluginRef" required:"true"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Parallel   bool                   `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	DependsOn  []string               `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
 }
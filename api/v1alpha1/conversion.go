@@ -0,0 +1,191 @@
+// api/v1alpha1/conversion.go
+package v1alpha1
+
+import (
+	"fmt"
+	"reflect"
+
+	"expressops/api/v1beta1"
+)
+
+// ConvertTo converts the config to v1beta1.
+// Steps get an ID, their pluginRef suffixed with -2, -3... when a plugin
+// appears more than once in a flow. dependsOn entries name plugins in
+// v1alpha1; they are rewritten to the ID of the last step running that
+// plugin, which is the step the engine used to wait on.
+func (src *Config) ConvertTo(dst *v1beta1.Config) {
+	dst.APIVersion = v1beta1.GroupVersion
+	dst.Kind = v1beta1.Kind
+	dst.Logging = v1beta1.LoggingConfig(src.Logging)
+	dst.Server = v1beta1.ServerConfig{
+		Port:       src.Server.Port,
+		Address:    src.Server.Address,
+		TimeoutSec: src.Server.TimeoutSec,
+		HTTP:       v1beta1.HTTPConfig(src.Server.HTTP),
+		Auth:       v1beta1.AuthConfig{Tokens: src.Server.Auth.Tokens},
+	}
+
+	dst.Plugins = nil
+	for _, p := range src.Plugins {
+		dst.Plugins = append(dst.Plugins, v1beta1.Plugin{
+			Name:   p.Name,
+			Path:   p.Path,
+			Type:   p.Type,
+			Config: p.Config,
+		})
+	}
+
+	dst.Flows = nil
+	for _, flow := range src.Flows {
+		out := v1beta1.Flow{
+			Name:          flow.Name,
+			Description:   flow.Description,
+			CustomHandler: flow.CustomHandler,
+		}
+
+		used := make(map[string]bool, len(flow.Pipeline))
+		lastByPlugin := make(map[string]string)
+		for _, step := range flow.Pipeline {
+			id := step.PluginRef
+			for n := 2; used[id]; n++ {
+				id = fmt.Sprintf("%s-%d", step.PluginRef, n)
+			}
+			used[id] = true
+			lastByPlugin[step.PluginRef] = id
+
+			out.Pipeline = append(out.Pipeline, v1beta1.Step{
+				ID:         id,
+				PluginRef:  step.PluginRef,
+				Parameters: step.Parameters,
+				Parallel:   step.Parallel,
+				DependsOn:  step.DependsOn,
+			})
+		}
+
+		for i := range out.Pipeline {
+			if len(out.Pipeline[i].DependsOn) == 0 {
+				continue
+			}
+			deps := make([]string, 0, len(out.Pipeline[i].DependsOn))
+			for _, ref := range out.Pipeline[i].DependsOn {
+				if id, ok := lastByPlugin[ref]; ok {
+					ref = id
+				}
+				deps = append(deps, ref)
+			}
+			out.Pipeline[i].DependsOn = deps
+		}
+
+		dst.Flows = append(dst.Flows, out)
+	}
+}
+
+// ConvertFrom converts a v1beta1 config to v1alpha1. It fails when the config
+// uses fields that v1alpha1 cannot represent: tracing, redaction, secrets,
+// audit, rate limits, plugin reloading, builtin and process plugins, flow
+// concurrency, step timeouts, retries, conditions, inputs and outputs, or
+// dependencies on a step that is not the last one running its plugin.
+func (dst *Config) ConvertFrom(src *v1beta1.Config) error {
+	if src.Server.Reload != (v1beta1.ReloadConfig{}) {
+		return fmt.Errorf("server: reload requires %s", v1beta1.GroupVersion)
+	}
+	if !reflect.DeepEqual(src.Tracing, v1beta1.TracingConfig{}) {
+		return fmt.Errorf("tracing requires %s", v1beta1.GroupVersion)
+	}
+	if !reflect.DeepEqual(src.Redaction, v1beta1.RedactionConfig{}) {
+		return fmt.Errorf("redaction requires %s", v1beta1.GroupVersion)
+	}
+	if src.Secrets != (v1beta1.SecretsConfig{}) {
+		return fmt.Errorf("secrets requires %s", v1beta1.GroupVersion)
+	}
+	if !reflect.DeepEqual(src.Audit, v1beta1.AuditConfig{}) {
+		return fmt.Errorf("audit requires %s", v1beta1.GroupVersion)
+	}
+	if src.Server.Auth.RateLimit != nil {
+		return fmt.Errorf("server: auth rateLimit requires %s", v1beta1.GroupVersion)
+	}
+	dst.APIVersion = GroupVersion
+	dst.Kind = src.Kind
+	dst.Logging = LoggingConfig(src.Logging)
+	dst.Server = ServerConfig{
+		Port:       src.Server.Port,
+		Address:    src.Server.Address,
+		TimeoutSec: src.Server.TimeoutSec,
+		HTTP:       HTTPConfig(src.Server.HTTP),
+		Auth:       AuthConfig{Tokens: src.Server.Auth.Tokens},
+	}
+
+	dst.Plugins = nil
+	for _, p := range src.Plugins {
+		if p.BuiltinName() != "" {
+			return fmt.Errorf("plugin '%s': builtin plugins require %s", p.Name, v1beta1.GroupVersion)
+		}
+		if p.Process != nil {
+			return fmt.Errorf("plugin '%s': process plugins require %s", p.Name, v1beta1.GroupVersion)
+		}
+		dst.Plugins = append(dst.Plugins, Plugin{
+			Name:   p.Name,
+			Path:   p.Path,
+			Type:   p.Type,
+			Config: p.Config,
+		})
+	}
+
+	dst.Flows = nil
+	for _, flow := range src.Flows {
+		if flow.Concurrency != nil || flow.RateLimit != nil {
+			return fmt.Errorf("flow '%s': concurrency and rateLimit require %s", flow.Name, v1beta1.GroupVersion)
+		}
+		out := Flow{
+			Name:          flow.Name,
+			Description:   flow.Description,
+			CustomHandler: flow.CustomHandler,
+		}
+
+		pluginByID := make(map[string]string, len(flow.Pipeline))
+		lastByPlugin := make(map[string]string)
+		for _, step := range flow.Pipeline {
+			id := step.ID
+			if id == "" {
+				id = step.PluginRef
+			}
+			pluginByID[id] = step.PluginRef
+			lastByPlugin[step.PluginRef] = id
+		}
+
+		for _, step := range flow.Pipeline {
+			id := step.ID
+			if id == "" {
+				id = step.PluginRef
+			}
+			if step.Timeout != "" || step.Retry != nil || step.Condition != "" {
+				return fmt.Errorf("flow '%s', step '%s': timeout, retry and condition require %s", flow.Name, id, v1beta1.GroupVersion)
+			}
+			if len(step.Inputs) > 0 || len(step.Outputs) > 0 {
+				return fmt.Errorf("flow '%s', step '%s': inputs and outputs require %s", flow.Name, id, v1beta1.GroupVersion)
+			}
+
+			var deps []string
+			for _, depID := range step.DependsOn {
+				ref, ok := pluginByID[depID]
+				if !ok {
+					return fmt.Errorf("flow '%s', step '%s': dependsOn '%s' matches no step", flow.Name, id, depID)
+				}
+				if lastByPlugin[ref] != depID {
+					return fmt.Errorf("flow '%s', step '%s': dependency on '%s' cannot be expressed in %s", flow.Name, id, depID, GroupVersion)
+				}
+				deps = append(deps, ref)
+			}
+
+			out.Pipeline = append(out.Pipeline, Step{
+				PluginRef:  step.PluginRef,
+				Parameters: step.Parameters,
+				Parallel:   step.Parallel,
+				DependsOn:  deps,
+			})
+		}
+
+		dst.Flows = append(dst.Flows, out)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"expressops/api/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	src := Config{
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+		Server:  ServerConfig{Port: 8080, Auth: AuthConfig{Tokens: []string{"t"}}},
+		Plugins: []Plugin{{Name: "slack", Path: "plugins/slack/slack.so", Config: map[string]interface{}{"k": "v"}}},
+		Flows: []Flow{{
+			Name:        "onboarding",
+			Description: "Create a user",
+			Pipeline: []Step{
+				{PluginRef: "create-user"},
+				{PluginRef: "slack", DependsOn: []string{"create-user"}},
+				{PluginRef: "permissions", DependsOn: []string{"create-user"}},
+				{PluginRef: "slack", Parallel: true, DependsOn: []string{"permissions"}},
+			},
+		}},
+	}
+
+	var hub v1beta1.Config
+	src.ConvertTo(&hub)
+	assert.Equal(t, v1beta1.GroupVersion, hub.APIVersion)
+	assert.Equal(t, []string{"create-user", "slack", "permissions", "slack-2"},
+		[]string{hub.Flows[0].Pipeline[0].ID, hub.Flows[0].Pipeline[1].ID, hub.Flows[0].Pipeline[2].ID, hub.Flows[0].Pipeline[3].ID})
+	require.NoError(t, hub.Validate())
+
+	var back Config
+	require.NoError(t, back.ConvertFrom(&hub))
+	assert.Equal(t, src.Flows, back.Flows)
+	assert.Equal(t, src.Plugins, back.Plugins)
+	assert.Equal(t, src.Server, back.Server)
+}
+
+func TestConvertFromRejectsNewFields(t *testing.T) {
+	hub := v1beta1.Config{Flows: []v1beta1.Flow{{
+		Name:     "f",
+		Pipeline: []v1beta1.Step{{ID: "a", PluginRef: "a", Timeout: "5s"}},
+	}}}
+	var dst Config
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "timeout, retry and condition require")
+
+	hub.Flows[0].Pipeline = []v1beta1.Step{
+		{ID: "first", PluginRef: "a"},
+		{ID: "second", PluginRef: "a"},
+		{ID: "b", PluginRef: "b", DependsOn: []string{"first"}},
+	}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "cannot be expressed")
+
+	hub = v1beta1.Config{Server: v1beta1.ServerConfig{Reload: v1beta1.ReloadConfig{Watch: true}}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "reload requires")
+
+	hub = v1beta1.Config{Tracing: v1beta1.TracingConfig{Exporter: v1beta1.TracingExporterStdout}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "tracing requires")
+
+	hub = v1beta1.Config{Redaction: v1beta1.RedactionConfig{Patterns: []string{"xoxb-.*"}}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "redaction requires")
+
+	hub = v1beta1.Config{Secrets: v1beta1.SecretsConfig{CacheTTL: "1m"}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "secrets requires")
+
+	hub = v1beta1.Config{Audit: v1beta1.AuditConfig{File: "audit.log"}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "audit requires")
+
+	hub = v1beta1.Config{Server: v1beta1.ServerConfig{Auth: v1beta1.AuthConfig{RateLimit: &v1beta1.RateLimit{Requests: 1}}}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "auth rateLimit requires")
+
+	hub = v1beta1.Config{Flows: []v1beta1.Flow{{Name: "f", Concurrency: &v1beta1.Concurrency{Max: 1}}}}
+	assert.ErrorContains(t, dst.ConvertFrom(&hub), "flow 'f': concurrency and rateLimit require")
+}
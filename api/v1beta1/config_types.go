@@ -0,0 +1,316 @@
+// Package v1beta1 provides API types for the configuration of ExpressOps.
+// It is the newest config version and the one used internally; older
+// versions are converted to it when loaded.
+// api/v1beta1/config_types.go
+package v1beta1
+
+// GroupVersion is the apiVersion of config files of this version
+const GroupVersion = "expressops/v1beta1"
+
+// Kind is the kind of the root configuration document
+const Kind = "Config"
+
+// Config represents the root configuration structure for the application
+type Config struct {
+	APIVersion string          `yaml:"apiVersion" json:"apiVersion" enum:"expressops/v1beta1"`
+	Kind       string          `yaml:"kind" json:"kind" enum:"Config"`
+	Logging    LoggingConfig   `yaml:"logging" json:"logging"`
+	Server     ServerConfig    `yaml:"server" json:"server"`
+	Tracing    TracingConfig   `yaml:"tracing,omitempty" json:"tracing,omitempty"`
+	Redaction  RedactionConfig `yaml:"redaction,omitempty" json:"redaction,omitempty"`
+	Secrets    SecretsConfig   `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Audit      AuditConfig     `yaml:"audit,omitempty" json:"audit,omitempty"`
+	Plugins    []Plugin        `yaml:"plugins" json:"plugins"`
+	Flows      []Flow          `yaml:"flows" json:"flows"`
+}
+
+// LoggingConfig represents the logging-related configuration options
+type LoggingConfig struct {
+	Level  string `yaml:"level" json:"level" enum:"trace,debug,info,warn,warning,error,fatal,panic"`
+	Format string `yaml:"format" json:"format" enum:"text,json"`
+}
+
+// Tracing exporters
+const (
+	TracingExporterNone     = "none"
+	TracingExporterStdout   = "stdout"
+	TracingExporterOTLPGRPC = "otlp-grpc"
+	TracingExporterOTLPHTTP = "otlp-http"
+)
+
+// Tracing samplers, named as in OTEL_TRACES_SAMPLER
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// TracingConfig represents the OpenTelemetry tracing settings. Spans are
+// not exported unless an exporter is selected.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter,omitempty" json:"exporter,omitempty" enum:"none,stdout,otlp-grpc,otlp-http"`
+	// Endpoint of the OTLP collector, either host:port or a URL such as
+	// https://collector:4318/v1/traces. Defaults to the OTEL_EXPORTER_OTLP_*
+	// environment variables, then to localhost.
+	Endpoint string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Insecure sends spans over plain HTTP or gRPC without TLS
+	Insecure bool              `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	TLS      *TracingTLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+	Sampler  SamplerConfig     `yaml:"sampler,omitempty" json:"sampler,omitempty"`
+	// ServiceName defaults to expressops-service
+	ServiceName string `yaml:"serviceName,omitempty" json:"serviceName,omitempty"`
+	// ResourceAttributes are added to every span, e.g. deployment.environment
+	ResourceAttributes map[string]string `yaml:"resourceAttributes,omitempty" json:"resourceAttributes,omitempty"`
+}
+
+// TracingTLSConfig holds the certificates used to reach the collector
+type TracingTLSConfig struct {
+	CAFile             string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	CertFile           string `yaml:"certFile,omitempty" json:"certFile,omitempty"`
+	KeyFile            string `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+}
+
+// SamplerConfig selects which traces are recorded
+type SamplerConfig struct {
+	// Type defaults to parentbased_always_on
+	Type string `yaml:"type,omitempty" json:"type,omitempty" enum:"always_on,always_off,traceidratio,parentbased_always_on,parentbased_always_off,parentbased_traceidratio"`
+	// Ratio of traces sampled by the ratio samplers, between 0 and 1
+	Ratio *float64 `yaml:"ratio,omitempty" json:"ratio,omitempty"`
+}
+
+// RedactionConfig extends what is masked in logs, flow responses, stored
+// executions and traces. The values of plugin config fields and parameters
+// named like a secret, such as password, token or webhook_url, are always
+// masked.
+type RedactionConfig struct {
+	// Keys are regular expressions matching the names of further fields and
+	// parameters holding secrets, e.g. ^ssn$
+	Keys []string `yaml:"keys,omitempty" json:"keys,omitempty"`
+	// Patterns are regular expressions whose matches are masked wherever
+	// they appear, e.g. xox[abp]-[0-9A-Za-z-]+ for Slack tokens
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
+// SecretsConfig sets up the providers of the secretRef values that may
+// replace any value of a plugin config or of step parameters:
+//
+//	webhook_url:
+//	  secretRef: {provider: file, name: slack-webhook-secret, key: SLACK_WEBHOOK_URL}
+//
+// The env, file and kubernetes providers work with their defaults; the
+// encrypted provider needs a path.
+type SecretsConfig struct {
+	// CacheTTL is how long a resolved value is reused, e.g. "1m". Defaults
+	// to 5m. Reloading a plugin always reads its secrets again.
+	CacheTTL   string                  `yaml:"cacheTTL,omitempty" json:"cacheTTL,omitempty"`
+	File       FileSecretsConfig       `yaml:"file,omitempty" json:"file,omitempty"`
+	Kubernetes KubernetesSecretsConfig `yaml:"kubernetes,omitempty" json:"kubernetes,omitempty"`
+	Encrypted  EncryptedSecretsConfig  `yaml:"encrypted,omitempty" json:"encrypted,omitempty"`
+}
+
+// FileSecretsConfig sets up the file provider, reading dir/name/key as laid
+// out by a Kubernetes Secret mounted as a volume at dir/name
+type FileSecretsConfig struct {
+	// Dir defaults to /var/run/secrets/expressops
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+}
+
+// KubernetesSecretsConfig sets up the kubernetes provider, reading Secrets
+// through the API. Left empty, it uses the service account of the pod.
+type KubernetesSecretsConfig struct {
+	// Namespace defaults to the namespace of the pod
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	// APIServer defaults to the in-cluster address of the API server
+	APIServer string `yaml:"apiServer,omitempty" json:"apiServer,omitempty"`
+	TokenFile string `yaml:"tokenFile,omitempty" json:"tokenFile,omitempty"`
+	CAFile    string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+}
+
+// EncryptedSecretsConfig sets up the encrypted provider, reading a YAML
+// file of values encrypted with `expressops secrets encrypt`
+type EncryptedSecretsConfig struct {
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// KeyFile holds the key made by `expressops secrets keygen`. Defaults
+	// to the EXPRESSOPS_SECRETS_KEY environment variable.
+	KeyFile string `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+}
+
+// AuditConfig sets up the audit trail: a record of every flow run, with
+// who started it, from where, its parameters and its outcome, and of
+// administrative actions such as plugin reloads. Each record holds the
+// hash of the previous one, so that `expressops audit verify` finds
+// records changed or removed. Nothing is recorded unless a file or syslog
+// is set.
+type AuditConfig struct {
+	// File receives one JSON record per line, appended
+	File   string             `yaml:"file,omitempty" json:"file,omitempty"`
+	Syslog *SyslogAuditConfig `yaml:"syslog,omitempty" json:"syslog,omitempty"`
+}
+
+// SyslogAuditConfig sends the audit records to syslog with the auth
+// facility
+type SyslogAuditConfig struct {
+	// Network and Address of a remote daemon, e.g. udp and logs:514. The
+	// local daemon is used when both are empty.
+	Network string `yaml:"network,omitempty" json:"network,omitempty" enum:"udp,tcp,unix,unixgram"`
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// Tag defaults to expressops
+	Tag string `yaml:"tag,omitempty" json:"tag,omitempty"`
+}
+
+// ServerConfig represents the server-related configuration options
+type ServerConfig struct {
+	Port       int          `yaml:"port" json:"port" default:"8080"`
+	Address    string       `yaml:"address" json:"address" default:"0.0.0.0"`
+	TimeoutSec int          `yaml:"timeoutSeconds" json:"timeoutSeconds" default:"4"`
+	HTTP       HTTPConfig   `yaml:"http" json:"http"`
+	Auth       AuthConfig   `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Reload     ReloadConfig `yaml:"reload,omitempty" json:"reload,omitempty"`
+}
+
+// HTTPConfig represents HTTP-specific configuration settings
+type HTTPConfig struct {
+	ProtocolVersion int `yaml:"protocolVersion" json:"protocolVersion"`
+}
+
+// AuthConfig represents the authentication settings of the /api/v1 endpoints.
+// When no tokens are configured the API is left open.
+type AuthConfig struct {
+	Tokens []string `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	// RateLimit bounds the flow runs started by each caller: each token has
+	// its own budget, and so has each client address without a token
+	RateLimit *RateLimit `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+}
+
+// ReloadConfig controls the hot reloading of plugins. Plugins can always be
+// reloaded through POST /api/v1/plugins/{name}/reload; with Watch set the
+// server also reloads a plugin when its entry in the config file or one of
+// its files changes.
+type ReloadConfig struct {
+	Watch bool `yaml:"watch,omitempty" json:"watch,omitempty"`
+	// Interval is how often the files are checked, e.g. "5s". Defaults to 2s.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// PluginTypeBuiltin selects the builtin plugin named like the entry when
+// no `builtin` is given
+const PluginTypeBuiltin = "builtin"
+
+// Plugin represents a plugin configuration entry. A plugin is either a
+// `.so` file at Path, a plugin compiled into the binary, named by Builtin, or
+// an executable run out of process, described by Process.
+type Plugin struct {
+	Name    string                 `yaml:"name" json:"name" required:"true"`
+	Path    string                 `yaml:"path,omitempty" json:"path,omitempty"`
+	Builtin string                 `yaml:"builtin,omitempty" json:"builtin,omitempty"`
+	Process *ProcessConfig         `yaml:"process,omitempty" json:"process,omitempty"`
+	Type    string                 `yaml:"type" json:"type"`
+	Config  map[string]interface{} `yaml:"config" json:"config"`
+}
+
+// ProcessConfig describes a plugin executable speaking the JSON-RPC plugin
+// protocol on its stdin and stdout
+type ProcessConfig struct {
+	Command string            `yaml:"command" json:"command" required:"true"`
+	Args    []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Dir     string            `yaml:"dir,omitempty" json:"dir,omitempty"`
+	// Timeout bounds every call to the process, e.g. "10s". Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// BuiltinName returns the builtin plugin the entry refers to, if any
+func (p Plugin) BuiltinName() string {
+	if p.Builtin != "" {
+		return p.Builtin
+	}
+	if p.Type == PluginTypeBuiltin && p.Path == "" && p.Process == nil {
+		return p.Name
+	}
+	return ""
+}
+
+// Flow represents a workflow definition
+type Flow struct {
+	Name          string `yaml:"name" json:"name" required:"true"`
+	Description   string `yaml:"description,omitempty" json:"description,omitempty"`
+	CustomHandler string `yaml:"customHandler,omitempty" json:"customHandler,omitempty"`
+	Pipeline      []Step `yaml:"pipeline" json:"pipeline" required:"true"`
+	// Concurrency bounds the runs of the flow going on at a time
+	Concurrency *Concurrency `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+	// RateLimit bounds how often the flow is started, whoever starts it
+	RateLimit *RateLimit `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+}
+
+// Concurrency policies, for a run that would go past the maximum
+const (
+	// ConcurrencyReject answers 429 Too Many Requests (default)
+	ConcurrencyReject = "reject"
+	// ConcurrencyQueue waits for a run to finish, up to the server timeout
+	ConcurrencyQueue = "queue"
+)
+
+// Concurrency bounds the simultaneous runs of a flow
+type Concurrency struct {
+	Max    int    `yaml:"max" json:"max" required:"true"`
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty" enum:"reject,queue"`
+	// MaxQueued bounds the runs waiting with the queue policy, past which
+	// they are rejected. Zero leaves the queue unbounded.
+	MaxQueued int `yaml:"maxQueued,omitempty" json:"maxQueued,omitempty"`
+}
+
+// RateLimit allows Requests runs per Period on average, e.g. 10 per "1m",
+// and bursts of up to Burst runs
+type RateLimit struct {
+	Requests int `yaml:"requests" json:"requests" required:"true"`
+	// Period defaults to 1s
+	Period string `yaml:"period,omitempty" json:"period,omitempty"`
+	// Burst defaults to Requests
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// Step conditions. A step runs only when its condition holds once all its
+// dependencies have finished.
+const (
+	// ConditionOnSuccess runs the step when every dependency succeeded (default)
+	ConditionOnSuccess = "on_success"
+	// ConditionOnFailure runs the step only when a dependency failed
+	ConditionOnFailure = "on_failure"
+	// ConditionAlways runs the step whatever the outcome of its dependencies
+	ConditionAlways = "always"
+)
+
+// Step represents each step in a flow pipeline
+type Step struct {
+	// ID identifies the step within its flow and defaults to PluginRef.
+	// DependsOn entries refer to step IDs.
+	ID         string                 `yaml:"id,omitempty" json:"id,omitempty"`
+	PluginRef  string                 `yaml:"pluginRef" json:"pluginRef" required:"true"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Parallel   bool                   `yaml:"parallel,omitempty" json:"parallel,omitempty"`
+	DependsOn  []string               `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	// Timeout bounds a single attempt of the step, e.g. "30s"
+	Timeout   string       `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Retry     *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Condition string       `yaml:"condition,omitempty" json:"condition,omitempty" enum:"on_success,on_failure,always"`
+	// Inputs set named values in the context of the plugin from expressions
+	// over the step parameters and the results of previous steps, e.g.
+	// message: "{{ steps.formatter.result }}"
+	Inputs map[string]string `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	// Outputs publish values computed from the step result, e.g.
+	// user_id: "{{ result.data.id }}", as steps.<id>.outputs.<name>
+	Outputs map[string]string `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+}
+
+// RetryPolicy describes how a failed step is retried
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, including the first one
+	Attempts int `yaml:"attempts" json:"attempts" required:"true"`
+	// Backoff is the delay between attempts, e.g. "2s"
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+}
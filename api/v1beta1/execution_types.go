@@ -0,0 +1,73 @@
+// api/v1beta1/execution_types.go
+package v1beta1
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ExecutionStatus is the lifecycle state of a flow execution
+type ExecutionStatus string
+
+const (
+	ExecutionRunning   ExecutionStatus = "running"
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+	ExecutionCancelled ExecutionStatus = "cancelled"
+)
+
+// Finished reports whether the execution reached a terminal state
+func (s ExecutionStatus) Finished() bool {
+	return s == ExecutionSucceeded || s == ExecutionFailed || s == ExecutionCancelled
+}
+
+// Execution is the API representation of a single flow run
+type Execution struct {
+	ID         string                 `json:"id"`
+	Flow       string                 `json:"flow"`
+	Status     ExecutionStatus        `json:"status"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Results    []interface{}          `json:"results,omitempty"`
+	StartedAt  time.Time              `json:"startedAt"`
+	FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+	// Logs are the lines logged by the engine and the plugins during the run
+	Logs []ExecutionLog `json:"logs,omitempty"`
+	// LogsTruncated is set when lines were dropped for exceeding the limit
+	LogsTruncated bool `json:"logsTruncated,omitempty"`
+}
+
+// ExecutionLog is a line logged during an execution. Fields holds the
+// structured fields of the line, such as the step it was logged by.
+type ExecutionLog struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// ExecutionRequest is the body accepted by POST /api/v1/executions
+type ExecutionRequest struct {
+	Flow   string                 `json:"flow"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// FlowSummary is the short flow description returned by GET /api/v1/flows
+type FlowSummary struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	CustomHandler string   `json:"customHandler,omitempty"`
+	Plugins       []string `json:"plugins"`
+}
+
+// PluginSummary describes a loaded plugin, as returned by GET /api/v1/plugins.
+// Every field but Name comes from the optional plugin manifest.
+type PluginSummary struct {
+	Name         string          `json:"name"`
+	Version      string          `json:"version,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	Author       string          `json:"author,omitempty"`
+	APIVersion   string          `json:"apiVersion,omitempty"`
+	Inputs       []string        `json:"inputs,omitempty"`
+	Outputs      []string        `json:"outputs,omitempty"`
+	ConfigSchema json.RawMessage `json:"configSchema,omitempty"`
+}
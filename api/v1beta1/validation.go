@@ -0,0 +1,385 @@
+// api/v1beta1/validation.go
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"expressops/internal/expr"
+	"expressops/internal/secrets"
+)
+
+// SetDefaults fills the fields a config file may omit: apiVersion, kind and
+// the ID of every step
+func SetDefaults(cfg *Config) {
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = GroupVersion
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = Kind
+	}
+	for i := range cfg.Flows {
+		for j := range cfg.Flows[i].Pipeline {
+			step := &cfg.Flows[i].Pipeline[j]
+			if step.ID == "" {
+				step.ID = step.PluginRef
+			}
+		}
+	}
+}
+
+// Validate checks the fields that the YAML decoder cannot: plugin sources,
+// unique step IDs, dependsOn references, durations, retry attempts,
+// conditions, limits and the references of inputs and outputs.
+// It expects SetDefaults to have been applied.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := c.Server.Reload.IntervalDuration(); err != nil {
+		errs = append(errs, fmt.Errorf("server: invalid reload interval: %w", err))
+	}
+	if l := c.Server.Auth.RateLimit; l != nil {
+		errs = append(errs, l.validate("server: auth")...)
+	}
+	errs = append(errs, c.Tracing.validate()...)
+	errs = append(errs, c.Redaction.validate()...)
+	errs = append(errs, c.Secrets.validate()...)
+	errs = append(errs, c.Audit.validate()...)
+	for _, p := range c.Plugins {
+		if p.Name == "" {
+			continue
+		}
+		errs = append(errs, c.Secrets.validateRefs(fmt.Sprintf("plugin '%s': config", p.Name), p.Config)...)
+		sources := 0
+		for _, set := range []bool{p.Path != "", p.BuiltinName() != "", p.Process != nil} {
+			if set {
+				sources++
+			}
+		}
+		switch {
+		case sources > 1:
+			errs = append(errs, fmt.Errorf("plugin '%s': set only one of path, builtin or process", p.Name))
+		case sources == 0:
+			errs = append(errs, fmt.Errorf("plugin '%s': a path, a builtin or a process is required", p.Name))
+		}
+		if p.Process != nil {
+			if p.Process.Command == "" {
+				errs = append(errs, fmt.Errorf("plugin '%s': process command is required", p.Name))
+			}
+			if _, err := p.Process.TimeoutDuration(); err != nil {
+				errs = append(errs, fmt.Errorf("plugin '%s': invalid process timeout: %w", p.Name, err))
+			}
+		}
+	}
+
+	for _, flow := range c.Flows {
+		if l := flow.Concurrency; l != nil {
+			errs = append(errs, l.validate(fmt.Sprintf("flow '%s'", flow.Name))...)
+		}
+		if l := flow.RateLimit; l != nil {
+			errs = append(errs, l.validate(fmt.Sprintf("flow '%s'", flow.Name))...)
+		}
+		ancestors := flow.Ancestors()
+		ids := make(map[string]bool, len(flow.Pipeline))
+		for _, step := range flow.Pipeline {
+			if step.PluginRef == "" {
+				continue
+			}
+			if ids[step.ID] {
+				errs = append(errs, fmt.Errorf("flow '%s': duplicate step id '%s', set a unique `id` on each step", flow.Name, step.ID))
+			}
+			ids[step.ID] = true
+		}
+
+		for _, step := range flow.Pipeline {
+			if step.PluginRef == "" {
+				continue
+			}
+			prefix := fmt.Sprintf("flow '%s', step '%s'", flow.Name, step.ID)
+			errs = append(errs, c.Secrets.validateRefs(prefix+": parameters", step.Parameters)...)
+			for _, dep := range step.DependsOn {
+				if !ids[dep] {
+					errs = append(errs, fmt.Errorf("%s: dependsOn '%s' matches no step", prefix, dep))
+				}
+			}
+			if _, err := step.TimeoutDuration(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid timeout: %w", prefix, err))
+			}
+			if step.Retry != nil {
+				if step.Retry.Attempts < 1 {
+					errs = append(errs, fmt.Errorf("%s: retry attempts must be at least 1", prefix))
+				}
+				if _, err := step.Retry.BackoffDuration(); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid retry backoff: %w", prefix, err))
+				}
+			}
+			switch step.Condition {
+			case "", ConditionOnSuccess, ConditionOnFailure, ConditionAlways:
+			default:
+				errs = append(errs, fmt.Errorf("%s: unknown condition '%s' (use %s, %s or %s)",
+					prefix, step.Condition, ConditionOnSuccess, ConditionOnFailure, ConditionAlways))
+			}
+			errs = append(errs, validateTemplates(prefix, "inputs", step.Inputs, ancestors[step.ID])...)
+			errs = append(errs, validateTemplates(prefix, "outputs", step.Outputs, nil)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ancestors returns, for each step ID of the flow, the IDs of the steps that
+// finish before it starts: its dependsOn entries or, when it has none and is
+// not parallel, the previous step, and their own ancestors
+func (f Flow) Ancestors() map[string]map[string]bool {
+	direct := make(map[string][]string, len(f.Pipeline))
+	previous := ""
+	for _, step := range f.Pipeline {
+		if step.PluginRef == "" {
+			continue
+		}
+		switch {
+		case len(step.DependsOn) > 0:
+			direct[step.ID] = step.DependsOn
+		case previous != "" && !step.Parallel:
+			direct[step.ID] = []string{previous}
+		}
+		previous = step.ID
+	}
+
+	ancestors := make(map[string]map[string]bool, len(f.Pipeline))
+	var visit func(id string, seen map[string]bool)
+	visit = func(id string, seen map[string]bool) {
+		for _, dep := range direct[id] {
+			if !seen[dep] {
+				seen[dep] = true
+				visit(dep, seen)
+			}
+		}
+	}
+	for _, step := range f.Pipeline {
+		if step.PluginRef == "" {
+			continue
+		}
+		seen := make(map[string]bool)
+		visit(step.ID, seen)
+		ancestors[step.ID] = seen
+	}
+	return ancestors
+}
+
+// validateTemplates checks the expressions of step inputs, which may refer
+// to params and to the steps in ancestors, or of step outputs, which may
+// refer to params and to the result of the step (ancestors is nil)
+func validateTemplates(prefix, field string, templates map[string]string, ancestors map[string]bool) []error {
+	var errs []error
+	for _, name := range sortedKeys(templates) {
+		tmpl, err := expr.Parse(templates[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s.%s: %w", prefix, field, name, err))
+			continue
+		}
+		for _, ref := range tmpl.References() {
+			if err := checkReference(ref, ancestors, field == "outputs"); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s.%s: %w", prefix, field, name, err))
+			}
+		}
+	}
+	return errs
+}
+
+func checkReference(ref []string, ancestors map[string]bool, isOutput bool) error {
+	path := strings.Join(ref, ".")
+	switch {
+	case ref[0] == "params":
+		return nil
+	case ref[0] == "result" && isOutput:
+		return nil
+	case ref[0] == "steps" && !isOutput:
+		if len(ref) < 3 {
+			return fmt.Errorf("'%s': expected steps.<id>.result, steps.<id>.outputs or steps.<id>.status", path)
+		}
+		if !ancestors[ref[1]] {
+			return fmt.Errorf("'%s': step '%s' does not run before this step, add it to dependsOn", path, ref[1])
+		}
+		switch ref[2] {
+		case "result", "outputs", "status":
+			return nil
+		}
+		return fmt.Errorf("'%s': expected steps.<id>.result, steps.<id>.outputs or steps.<id>.status", path)
+	case isOutput:
+		return fmt.Errorf("'%s': outputs may only refer to result and params", path)
+	default:
+		return fmt.Errorf("'%s': inputs may only refer to steps and params", path)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t TracingConfig) validate() []error {
+	var errs []error
+	switch t.Exporter {
+	case "", TracingExporterNone, TracingExporterStdout, TracingExporterOTLPGRPC, TracingExporterOTLPHTTP:
+	default:
+		errs = append(errs, fmt.Errorf("tracing: unknown exporter '%s'", t.Exporter))
+	}
+	if t.TLS != nil && (t.TLS.CertFile == "") != (t.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tracing: tls certFile and keyFile must be set together"))
+	}
+
+	ratio := t.Sampler.Ratio
+	switch t.Sampler.Type {
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		if ratio == nil {
+			errs = append(errs, fmt.Errorf("tracing: sampler %s requires a ratio", t.Sampler.Type))
+		}
+	case "", SamplerAlwaysOn, SamplerAlwaysOff, SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff:
+		if ratio != nil {
+			errs = append(errs, errors.New("tracing: sampler ratio is only used by the traceidratio samplers"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing: unknown sampler '%s'", t.Sampler.Type))
+	}
+	if ratio != nil && (*ratio < 0 || *ratio > 1) {
+		errs = append(errs, fmt.Errorf("tracing: sampler ratio %v is not between 0 and 1", *ratio))
+	}
+	return errs
+}
+
+func (r RedactionConfig) validate() []error {
+	var errs []error
+	for _, list := range []struct {
+		name  string
+		exprs []string
+	}{{"keys", r.Keys}, {"patterns", r.Patterns}} {
+		for _, e := range list.exprs {
+			if _, err := regexp.Compile(e); err != nil {
+				errs = append(errs, fmt.Errorf("redaction: invalid %s expression '%s': %w", list.name, e, err))
+			}
+		}
+	}
+	return errs
+}
+
+func (s SecretsConfig) validate() []error {
+	if _, err := s.CacheTTLDuration(); err != nil {
+		return []error{fmt.Errorf("secrets: invalid cacheTTL: %w", err)}
+	}
+	return nil
+}
+
+func (c Concurrency) validate(prefix string) []error {
+	var errs []error
+	if c.Max < 1 {
+		errs = append(errs, fmt.Errorf("%s: concurrency max must be at least 1", prefix))
+	}
+	switch c.Policy {
+	case "", ConcurrencyReject, ConcurrencyQueue:
+	default:
+		errs = append(errs, fmt.Errorf("%s: unknown concurrency policy '%s' (use %s or %s)",
+			prefix, c.Policy, ConcurrencyReject, ConcurrencyQueue))
+	}
+	if c.MaxQueued < 0 {
+		errs = append(errs, fmt.Errorf("%s: concurrency maxQueued must not be negative", prefix))
+	}
+	return errs
+}
+
+func (r RateLimit) validate(prefix string) []error {
+	var errs []error
+	if r.Requests < 1 {
+		errs = append(errs, fmt.Errorf("%s: rateLimit requests must be at least 1", prefix))
+	}
+	if r.Burst < 0 {
+		errs = append(errs, fmt.Errorf("%s: rateLimit burst must not be negative", prefix))
+	}
+	if d, err := r.PeriodDuration(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: invalid rateLimit period: %w", prefix, err))
+	} else if d == 0 && r.Period != "" {
+		errs = append(errs, fmt.Errorf("%s: rateLimit period must not be zero", prefix))
+	}
+	return errs
+}
+
+func (a AuditConfig) validate() []error {
+	if a.Syslog == nil {
+		return nil
+	}
+	var errs []error
+	switch a.Syslog.Network {
+	case "", "udp", "tcp", "unix", "unixgram":
+	default:
+		errs = append(errs, fmt.Errorf("audit: unknown syslog network '%s'", a.Syslog.Network))
+	}
+	if (a.Syslog.Network == "") != (a.Syslog.Address == "") {
+		errs = append(errs, errors.New("audit: syslog network and address must be set together"))
+	}
+	return errs
+}
+
+// validateRefs checks the secretRefs of a plugin config or of step
+// parameters
+func (s SecretsConfig) validateRefs(prefix string, values map[string]interface{}) []error {
+	var errs []error
+	secrets.Walk(values, func(path string, ref secrets.Ref, err error) {
+		if err == nil && ref.Provider == secrets.ProviderEncrypted && s.Encrypted.Path == "" {
+			err = errors.New("the encrypted provider requires secrets.encrypted.path")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.%s: %w", prefix, path, err))
+		}
+	})
+	return errs
+}
+
+// TimeoutDuration returns the parsed timeout of the step, zero when unset
+func (s Step) TimeoutDuration() (time.Duration, error) {
+	return parseDuration(s.Timeout)
+}
+
+// TimeoutDuration returns the parsed call timeout of the process, zero when unset
+func (p ProcessConfig) TimeoutDuration() (time.Duration, error) {
+	return parseDuration(p.Timeout)
+}
+
+// IntervalDuration returns the parsed watch interval, zero when unset
+func (r ReloadConfig) IntervalDuration() (time.Duration, error) {
+	return parseDuration(r.Interval)
+}
+
+// CacheTTLDuration returns the parsed cache TTL, zero when unset
+func (s SecretsConfig) CacheTTLDuration() (time.Duration, error) {
+	return parseDuration(s.CacheTTL)
+}
+
+// PeriodDuration returns the parsed period, zero when unset
+func (r RateLimit) PeriodDuration() (time.Duration, error) {
+	return parseDuration(r.Period)
+}
+
+// BackoffDuration returns the parsed delay between attempts, zero when unset
+func (r RetryPolicy) BackoffDuration() (time.Duration, error) {
+	return parseDuration(r.Backoff)
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration '%s' is negative", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,67 @@
+// cmd/audit.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"expressops/internal/audit"
+)
+
+const auditUsage = `Usage: expressops audit <command>
+
+Commands:
+  verify <file>
+         Check the hash chain of an audit file, or of stdin with -, and
+         report the first record that was changed or removed.
+`
+
+// runAuditCommands implements `expressops audit` and returns the process exit code
+func runAuditCommands(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, auditUsage)
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	var err error
+	switch args[0] {
+	case "verify":
+		err = runVerifyCommand(args[1:])
+	default:
+		err = fmt.Errorf("unknown audit command '%s'", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runVerifyCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: expressops audit verify <file>")
+	}
+
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	n, err := audit.Verify(r)
+	if err != nil {
+		return fmt.Errorf("%s: %d records verified, then %w", args[0], n, err)
+	}
+	fmt.Printf("%s: %d records verified\n", args[0], n)
+	return nil
+}
@@ -0,0 +1,19 @@
+// cmd/builtins.go
+package main
+
+// Bundled plugins register themselves as builtins when imported, so they are
+// compiled into the binary and selected in the config with `builtin: <name>`
+import (
+	_ "expressops/plugins/clean-disk"
+	_ "expressops/plugins/exec"
+	_ "expressops/plugins/flowlister"
+	_ "expressops/plugins/formatters"
+	_ "expressops/plugins/healthcheck"
+	_ "expressops/plugins/httprequest"
+	_ "expressops/plugins/kubehealth"
+	_ "expressops/plugins/permissions"
+	_ "expressops/plugins/slack"
+	_ "expressops/plugins/sleep"
+	_ "expressops/plugins/testprint"
+	_ "expressops/plugins/usercreation"
+)
@@ -0,0 +1,668 @@
+// cmd/client.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/client"
+)
+
+const clientUsage = `Usage: expressops client [global flags] <command> [args]
+
+Commands:
+  flows list                         List the flows registered in the server
+  flows describe <flow>              Show the pipeline of a flow
+  flows graph <flow> [--format mermaid|dot] [--execution id]
+                                     Render the resolved execution plan
+  plugins list                       List the loaded plugins and their manifest
+  plugins reload <plugin>            Load a new version of a plugin from the config file
+  run <flow> [--param k=v ...]       Run a flow (add --wait or --watch to follow it)
+  executions list                    List recent executions
+  executions get <id>                Show an execution and its step results
+  executions watch <id>              Follow an execution until it finishes
+  executions logs <id> [--follow]    Print the lines logged by an execution
+  executions cancel <id>             Cancel a running execution
+  config get-contexts                List the configured servers
+  config current-context             Print the selected context
+  config use-context <name>          Select the context used by default
+  config set-context <name> --server URL [--token T]
+                                     Add or update a server
+
+Global flags:
+  --context name   Context from the client config to use
+  --server url     Server URL (overrides the context)
+  --token token    API token (overrides the context, or $EXPRESSOPS_TOKEN)
+  --config path    Client config file (default $EXPRESSOPS_CLIENT_CONFIG or ~/.expressops/config)
+  -o format        Output format: table or json
+`
+
+// exitFlowFailed is returned when a followed execution does not succeed
+const exitFlowFailed = 2
+
+// clientOptions holds the flags shared by every client command
+type clientOptions struct {
+	contextName string
+	server      string
+	token       string
+	configPath  string
+	output      string
+	out         io.Writer
+}
+
+func (o *clientOptions) bind(fs *flag.FlagSet) {
+	fs.StringVar(&o.contextName, "context", o.contextName, "context to use")
+	fs.StringVar(&o.server, "server", o.server, "server URL")
+	fs.StringVar(&o.token, "token", o.token, "API token")
+	fs.StringVar(&o.configPath, "config", o.configPath, "client config file")
+	fs.StringVar(&o.output, "o", o.output, "output format (table|json)")
+}
+
+// newClient resolves the server and token from flags, env and the context file
+func (o *clientOptions) newClient() (*client.Client, error) {
+	server, token := o.server, o.token
+	if token == "" {
+		token = os.Getenv("EXPRESSOPS_TOKEN")
+	}
+	if server == "" {
+		server = os.Getenv("EXPRESSOPS_SERVER")
+	}
+
+	if server == "" || (token == "" && o.contextName != "") {
+		cfg, err := client.LoadConfig(o.configPath)
+		if err != nil {
+			return nil, err
+		}
+		ctx, err := cfg.Lookup(o.contextName)
+		if err != nil {
+			return nil, err
+		}
+		if server == "" {
+			server = ctx.Server
+		}
+		if token == "" {
+			token = ctx.Token
+		}
+	}
+
+	return client.New(server, token), nil
+}
+
+// runClient implements `expressops client` and returns the process exit code
+func runClient(args []string) int {
+	opts := &clientOptions{configPath: client.DefaultConfigPath(), output: "table", out: os.Stdout}
+
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, clientUsage) }
+	opts.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 1
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	code := 0
+	switch rest[0] {
+	case "flows":
+		err = runFlowsCommand(ctx, opts, rest[1:])
+	case "plugins":
+		err = runPluginsCommand(ctx, opts, rest[1:])
+	case "run":
+		code, err = runRunCommand(ctx, opts, rest[1:])
+	case "executions":
+		code, err = runExecutionsCommand(ctx, opts, rest[1:])
+	case "config":
+		err = runConfigCommand(opts, rest[1:])
+	case "help":
+		fs.Usage()
+	default:
+		err = fmt.Errorf("unknown command '%s'", rest[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return code
+}
+
+// parseCommand parses flags that may appear before or after positional arguments
+func parseCommand(name string, opts *clientOptions, args []string, register func(*flag.FlagSet)) ([]string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts.bind(fs)
+	if register != nil {
+		register(fs)
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func runFlowsCommand(ctx context.Context, opts *clientOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: expressops client flows list|describe|graph <flow>")
+	}
+
+	var format, executionID string
+	positional, err := parseCommand("flows "+args[0], opts, args[1:], func(fs *flag.FlagSet) {
+		fs.StringVar(&format, "format", "mermaid", "graph format (mermaid|dot)")
+		fs.StringVar(&executionID, "execution", "", "color the graph by the outcome of this execution")
+	})
+	if err != nil {
+		return err
+	}
+	c, err := opts.newClient()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		flows, err := c.ListFlows(ctx)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(opts.out, flows)
+		}
+		tw := newTable(opts.out)
+		fmt.Fprintln(tw, "NAME\tSTEPS\tDESCRIPTION")
+		for _, f := range flows {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", f.Name, len(f.Plugins), f.Description)
+		}
+		return tw.Flush()
+
+	case "describe":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: expressops client flows describe <flow>")
+		}
+		flow, err := c.GetFlow(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(opts.out, flow)
+		}
+		printFlow(opts.out, flow)
+		return nil
+
+	case "graph":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: expressops client flows graph <flow> [--format mermaid|dot] [--execution id]")
+		}
+		graph, err := c.GetFlowGraph(ctx, positional[0], format, executionID)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(opts.out, graph)
+		return err
+
+	default:
+		return fmt.Errorf("unknown flows command '%s'", args[0])
+	}
+}
+
+func runPluginsCommand(ctx context.Context, opts *clientOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: expressops client plugins list|reload")
+	}
+	positional, err := parseCommand("plugins "+args[0], opts, args[1:], nil)
+	if err != nil {
+		return err
+	}
+	c, err := opts.newClient()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		plugins, err := c.ListPlugins(ctx)
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(opts.out, plugins)
+		}
+		tw := newTable(opts.out)
+		fmt.Fprintln(tw, "NAME\tVERSION\tAPI\tINPUTS\tOUTPUTS\tDESCRIPTION")
+		for _, p := range plugins {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, orDash(p.Version), orDash(p.APIVersion),
+				orDash(strings.Join(p.Inputs, ",")), orDash(strings.Join(p.Outputs, ",")), p.Description)
+		}
+		return tw.Flush()
+
+	case "reload":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: expressops client plugins reload <plugin>")
+		}
+		plugin, err := c.ReloadPlugin(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		if opts.output == "json" {
+			return printJSON(opts.out, plugin)
+		}
+		fmt.Fprintf(opts.out, "Plugin %s reloaded (version %s)\n", plugin.Name, orDash(plugin.Version))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown plugins command '%s'", args[0])
+	}
+}
+
+// paramsFlag collects repeated --param key=value flags
+type paramsFlag map[string]interface{}
+
+func (p paramsFlag) String() string { return "" }
+
+func (p paramsFlag) Set(value string) error {
+	// Accept key=value and the key:value syntax of the /flow endpoint
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		kv = strings.SplitN(value, ":", 2)
+	}
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("invalid param '%s', expected key=value", value)
+	}
+	p[kv[0]] = kv[1]
+	return nil
+}
+
+func runRunCommand(ctx context.Context, opts *clientOptions, args []string) (int, error) {
+	params := paramsFlag{}
+	var wait, watch bool
+	var interval time.Duration
+
+	positional, err := parseCommand("run", opts, args, func(fs *flag.FlagSet) {
+		fs.Var(params, "param", "flow parameter as key=value (repeatable)")
+		fs.BoolVar(&wait, "wait", false, "block until the flow finishes")
+		fs.BoolVar(&watch, "watch", false, "poll the execution and print status changes")
+		fs.DurationVar(&interval, "interval", time.Second, "poll interval for --watch")
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(positional) != 1 {
+		return 0, fmt.Errorf("usage: expressops client run <flow> [--param k=v ...] [--wait|--watch]")
+	}
+
+	c, err := opts.newClient()
+	if err != nil {
+		return 0, err
+	}
+
+	exec, err := c.Run(ctx, positional[0], params, wait)
+	if err != nil {
+		return 0, err
+	}
+
+	if watch && !exec.Status.Finished() {
+		return watchExecution(ctx, c, opts, exec.ID, interval)
+	}
+	if err := printExecutionOutput(opts, exec); err != nil {
+		return 0, err
+	}
+	return exitCodeFor(exec), nil
+}
+
+func runExecutionsCommand(ctx context.Context, opts *clientOptions, args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("usage: expressops client executions list|get|watch|logs|cancel <id>")
+	}
+
+	var interval time.Duration
+	var follow bool
+	positional, err := parseCommand("executions "+args[0], opts, args[1:], func(fs *flag.FlagSet) {
+		fs.DurationVar(&interval, "interval", time.Second, "poll interval for watch")
+		fs.BoolVar(&follow, "follow", false, "keep printing new lines until the execution finishes (logs)")
+		fs.BoolVar(&follow, "f", false, "shorthand for --follow")
+	})
+	if err != nil {
+		return 0, err
+	}
+	c, err := opts.newClient()
+	if err != nil {
+		return 0, err
+	}
+
+	if args[0] == "list" || args[0] == "ls" {
+		list, err := c.ListExecutions(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if opts.output == "json" {
+			return 0, printJSON(opts.out, list)
+		}
+		tw := newTable(opts.out)
+		fmt.Fprintln(tw, "ID\tFLOW\tSTATUS\tSTARTED\tDURATION")
+		for _, e := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.ID, e.Flow, e.Status,
+				e.StartedAt.Local().Format(time.DateTime), executionDuration(&e))
+		}
+		return 0, tw.Flush()
+	}
+
+	if len(positional) != 1 {
+		return 0, fmt.Errorf("usage: expressops client executions %s <id>", args[0])
+	}
+	id := positional[0]
+
+	switch args[0] {
+	case "get":
+		exec, err := c.GetExecution(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return 0, printExecutionOutput(opts, exec)
+
+	case "watch":
+		return watchExecution(ctx, c, opts, id, interval)
+
+	case "logs":
+		return 0, c.ExecutionLogs(ctx, id, follow, func(line v1beta1.ExecutionLog) {
+			if opts.output == "json" {
+				_ = json.NewEncoder(opts.out).Encode(line)
+				return
+			}
+			fmt.Fprintln(opts.out, formatLogLine(line))
+		})
+
+	case "cancel":
+		exec, err := c.CancelExecution(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if opts.output == "json" {
+			return 0, printJSON(opts.out, exec)
+		}
+		fmt.Fprintf(opts.out, "Cancellation requested for execution %s (status: %s)\n", exec.ID, exec.Status)
+		return 0, nil
+
+	default:
+		return 0, fmt.Errorf("unknown executions command '%s'", args[0])
+	}
+}
+
+func watchExecution(ctx context.Context, c *client.Client, opts *clientOptions, id string, interval time.Duration) (int, error) {
+	exec, err := c.WatchExecution(ctx, id, interval, func(e *v1beta1.Execution) {
+		if opts.output != "json" {
+			fmt.Fprintf(opts.out, "%s  %s  %s\n", time.Now().Format(time.TimeOnly), e.ID, e.Status)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	if opts.output != "json" {
+		fmt.Fprintln(opts.out)
+	}
+	if err := printExecutionOutput(opts, exec); err != nil {
+		return 0, err
+	}
+	return exitCodeFor(exec), nil
+}
+
+func runConfigCommand(opts *clientOptions, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: expressops client config get-contexts|current-context|use-context|set-context")
+	}
+
+	positional, err := parseCommand("config "+args[0], opts, args[1:], nil)
+	if err != nil {
+		return err
+	}
+	cfg, err := client.LoadConfig(opts.configPath)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get-contexts":
+		tw := newTable(opts.out)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER\tAUTH")
+		for _, c := range cfg.Contexts {
+			current, auth := "", "none"
+			if c.Name == cfg.CurrentContext {
+				current = "*"
+			}
+			if c.Token != "" {
+				auth = "token"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, c.Name, c.Server, auth)
+		}
+		return tw.Flush()
+
+	case "current-context":
+		if cfg.CurrentContext == "" {
+			return fmt.Errorf("current context is not set")
+		}
+		fmt.Fprintln(opts.out, cfg.CurrentContext)
+		return nil
+
+	case "use-context":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: expressops client config use-context <name>")
+		}
+		if _, err := cfg.Lookup(positional[0]); err != nil {
+			return err
+		}
+		cfg.CurrentContext = positional[0]
+		if err := cfg.Save(opts.configPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.out, "Switched to context '%s'\n", positional[0])
+		return nil
+
+	case "set-context":
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: expressops client config set-context <name> --server URL [--token T]")
+		}
+		cfg.SetContext(client.Context{Name: positional[0], Server: opts.server, Token: opts.token})
+		if cfg.CurrentContext == "" {
+			cfg.CurrentContext = positional[0]
+		}
+		if err := cfg.Save(opts.configPath); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.out, "Context '%s' saved to %s\n", positional[0], opts.configPath)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown config command '%s'", args[0])
+	}
+}
+
+func printFlow(out io.Writer, flow *v1beta1.Flow) {
+	fmt.Fprintf(out, "Name:        %s\n", flow.Name)
+	if flow.Description != "" {
+		fmt.Fprintf(out, "Description: %s\n", flow.Description)
+	}
+	if flow.CustomHandler != "" {
+		fmt.Fprintf(out, "Handler:     %s\n", flow.CustomHandler)
+	}
+	fmt.Fprintf(out, "Steps:       %d\n\n", len(flow.Pipeline))
+
+	tw := newTable(out)
+	fmt.Fprintln(tw, "#\tID\tPLUGIN\tDEPENDS ON\tPARALLEL\tPOLICY\tPARAMETERS")
+	for i, step := range flow.Pipeline {
+		deps := strings.Join(step.DependsOn, ",")
+		if deps == "" {
+			deps = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%t\t%s\t%s\n", i+1, step.ID, step.PluginRef, deps, step.Parallel, formatStepPolicy(step), formatParams(step.Parameters))
+	}
+	tw.Flush()
+}
+
+// formatStepPolicy summarizes the timeout, retry and condition of a step
+func formatStepPolicy(step v1beta1.Step) string {
+	var parts []string
+	if step.Timeout != "" {
+		parts = append(parts, "timeout="+step.Timeout)
+	}
+	if step.Retry != nil {
+		retry := fmt.Sprintf("retry=%d", step.Retry.Attempts)
+		if step.Retry.Backoff != "" {
+			retry += "/" + step.Retry.Backoff
+		}
+		parts = append(parts, retry)
+	}
+	if step.Condition != "" {
+		parts = append(parts, "if="+step.Condition)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ",")
+}
+
+func printExecutionOutput(opts *clientOptions, exec *v1beta1.Execution) error {
+	if opts.output == "json" {
+		return printJSON(opts.out, exec)
+	}
+
+	out := opts.out
+	fmt.Fprintf(out, "ID:       %s\n", exec.ID)
+	fmt.Fprintf(out, "Flow:     %s\n", exec.Flow)
+	fmt.Fprintf(out, "Status:   %s\n", exec.Status)
+	fmt.Fprintf(out, "Started:  %s\n", exec.StartedAt.Local().Format(time.DateTime))
+	if exec.FinishedAt != nil {
+		fmt.Fprintf(out, "Duration: %s\n", executionDuration(exec))
+	}
+	if len(exec.Params) > 0 {
+		fmt.Fprintf(out, "Params:   %s\n", formatParams(exec.Params))
+	}
+	if len(exec.Results) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	tw := newTable(out)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tOUTPUT")
+	for _, res := range exec.Results {
+		step, ok := res.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status, output := "ok", step["formatted_result"]
+		if errMsg, hasError := step["error"]; hasError {
+			status, output = "error", errMsg
+		} else if reason, skipped := step["skipped"]; skipped {
+			status, output = "skipped", reason
+		} else if output == nil {
+			output = step["result"]
+		}
+		name := step["step"]
+		if name == nil {
+			name = step["plugin"]
+		}
+		fmt.Fprintf(tw, "%v\t%s\t%s\n", name, status, firstLine(fmt.Sprintf("%v", output)))
+	}
+	return tw.Flush()
+}
+
+func exitCodeFor(exec *v1beta1.Execution) int {
+	if exec.Status == v1beta1.ExecutionFailed || exec.Status == v1beta1.ExecutionCancelled {
+		return exitFlowFailed
+	}
+	return 0
+}
+
+func executionDuration(exec *v1beta1.Execution) string {
+	if exec.FinishedAt == nil {
+		return "-"
+	}
+	return exec.FinishedAt.Sub(exec.StartedAt).Round(time.Millisecond).String()
+}
+
+// orDash returns s, or "-" for an empty table cell
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatParams(params map[string]interface{}) string {
+	if len(params) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, params[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// formatLogLine renders a log line of an execution the way the server logs
+// it: time, level, message and then its fields in key order
+func formatLogLine(line v1beta1.ExecutionLog) string {
+	keys := make([]string, 0, len(line.Fields))
+	for k := range line.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %-7s %s", line.Time.Local().Format(time.TimeOnly), strings.ToUpper(line.Level), line.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, line.Fields[k])
+	}
+	return b.String()
+}
+
+// firstLine keeps table rows readable when a plugin returns multi-line output
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+func newTable(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+}
+
+func printJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,178 @@
+// cmd/config.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/config"
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = `Usage: expressops config <command> [flags]
+
+Commands:
+  schema [--config file] [--out file]
+         Print the JSON Schema of the configuration file, including the config
+         block of builtin plugins. With --config, the .so plugins declared in
+         that file contribute the schema of their config block too.
+  migrate <file> [--out file | --in-place]
+         Convert a config file to the newest apiVersion. Comments are not kept.
+`
+
+// runConfigCommands implements `expressops config` and returns the process exit code
+func runConfigCommands(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, configUsage)
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	var err error
+	switch args[0] {
+	case "schema":
+		err = runSchemaCommand(args[1:])
+	case "migrate":
+		err = runMigrateCommand(args[1:])
+	default:
+		err = fmt.Errorf("unknown config command '%s'", args[0])
+	}
+
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runSchemaCommand(args []string) error {
+	fs := flag.NewFlagSet("config schema", flag.ContinueOnError)
+	configPath := fs.String("config", "", "config file whose plugins contribute their config schema")
+	outPath := fs.String("out", "", "write the schema to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	builtinSchemas := make(map[string]*schema.Schema)
+	for _, name := range pluginManager.Builtins() {
+		instance, err := pluginManager.NewBuiltin(name)
+		if err != nil {
+			return err
+		}
+		builtinSchemas[name] = pluginManager.ManifestOf(instance).ConfigSchema
+	}
+
+	pluginSchemas := make(map[string]*schema.Schema)
+	if *configPath != "" {
+		cfg, err := config.ReadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		for _, p := range cfg.Plugins {
+			// builtin plugins are already described by builtinSchemas
+			if p.Name == "" || p.Path == "" {
+				continue
+			}
+			instance, err := pluginManager.InspectPlugin(p.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping schema of plugin '%s': %v\n", p.Name, err)
+				continue
+			}
+			if configSchema := pluginManager.ManifestOf(instance).ConfigSchema; configSchema != nil {
+				pluginSchemas[p.Name] = configSchema
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(schema.ForConfig(pluginSchemas, builtinSchemas), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *outPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*outPath, data, 0o644)
+}
+
+func runMigrateCommand(args []string) error {
+	fs := flag.NewFlagSet("config migrate", flag.ContinueOnError)
+	outPath := fs.String("out", "", "write the migrated config to this file instead of stdout")
+	inPlace := fs.Bool("in-place", false, "overwrite the input file")
+
+	// accept the file before or after the flags
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if path == "" && fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		return errors.New("usage: expressops config migrate <file> [--out file | --in-place]")
+	}
+	if *inPlace && *outPath != "" {
+		return errors.New("--out and --in-place are mutually exclusive")
+	}
+
+	// Environment variables are not expanded so that references like
+	// $SLACK_WEBHOOK_URL survive the migration
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	cfg, version, err := config.DecodeConfig(data)
+	if err != nil {
+		return fmt.Errorf("invalid config '%s': %w", path, err)
+	}
+	if version == v1beta1.GroupVersion && (*inPlace || *outPath == "") {
+		fmt.Fprintf(os.Stderr, "%s is already at %s\n", path, version)
+		if *inPlace {
+			return nil
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	switch {
+	case *inPlace:
+		*outPath = path
+	case *outPath == "":
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*outPath, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if version != v1beta1.GroupVersion {
+		fmt.Fprintf(os.Stderr, "Migrated %s from %s to %s\n", path, version, v1beta1.GroupVersion)
+	}
+	return nil
+}
@@ -3,40 +3,53 @@ package main
 
 import (
 	"context"
-	"expressops/internal/config" // imports the internal/config package
-	"expressops/internal/metrics"
+	"expressops/internal/config"  // imports the internal/config package
 	"expressops/internal/server"  // imports the server package
 	"expressops/internal/tracing" // Import the tracing package
 	"flag"
-	"runtime"
+	"os"
 	//logger
 )
 
-// Initialize metrics when package is loaded
-func init() {
-	// Register and initialize basic metrics
-	metrics.SetActivePlugins(0)
-
-	// Initialize resource metrics with initial values
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	metrics.RecordMemoryUsage(float64(m.Alloc))
-	metrics.RecordCpuUsage(0.0)
-	metrics.UpdateStorageUsage(0.0)
-	metrics.UpdateConcurrentPlugins(0)
-
-	// Log initialization
-	// This ensures all metrics are registered with the Prometheus registry
-}
-
 func main() {
+	// `expressops client ...` talks to a running server instead of starting one
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		os.Exit(runClient(os.Args[2:]))
+	}
+	// `expressops config ...` works on configuration files offline
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommands(os.Args[2:]))
+	}
+	// `expressops plugin ...` helps writing plugins
+	if len(os.Args) > 1 && os.Args[1] == "plugin" {
+		os.Exit(runPluginCommands(os.Args[2:]))
+	}
+	// `expressops secrets ...` manages the file of the encrypted secrets provider
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		os.Exit(runSecretsCommands(os.Args[2:]))
+	}
+	// `expressops audit verify` checks the hash chain of an audit file
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(runAuditCommands(os.Args[2:]))
+	}
 
 	logger := config.InitializeLogger()
 
 	ctx := context.Background() // crea un nuevo contexto
 
+	// Parse the command line flags to get the config file path
+	var configPath string
+	flag.StringVar(&configPath, "config", "docs/samples/config.yaml", "Path to YAML configuration file")
+	flag.Parse()
+
+	// The tracing section is read first so that loading the plugins is traced
+	tracingCfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		logger.Fatalf("Error loading configuration: %v", err)
+	}
+
 	// Inicializar OpenTelemetry TracerProvider
-	tp, err := tracing.InitTracerProvider("expressops-service") // Define el nombre de tu servicio
+	tp, err := tracing.InitTracerProvider(ctx, tracingCfg.Tracing)
 	if err != nil {
 		logger.Fatalf("Failed to initialize tracer provider: %v", err)
 	}
@@ -45,6 +58,9 @@ func main() {
 			logger.Printf("Error shutting down tracer provider: %v", err)
 		}
 	}() // Asegura que se llame a Shutdown
+	if exporter := tracingCfg.Tracing.Exporter; exporter != "" && exporter != "none" {
+		logger.Infof("Tracing enabled, exporting spans with %s (version %s)", exporter, tracing.Version())
+	}
 
 	// Obtener el tracer
 	tracer := tracing.GetTracer()
@@ -54,11 +70,6 @@ func main() {
 	ctxMain, mainSpan := tracer.Start(ctx, "main-execution")
 	defer mainSpan.End() // Asegura que el span principal se cierre al final de main
 
-	// Parse the command line flags to get the config file path
-	var configPath string
-	flag.StringVar(&configPath, "config", "docs/samples/config.yaml", "Path to YAML configuration file")
-	flag.Parse()
-
 	// 1º load the config from YAML
 	// Usa ctxMain aquí si quieres que la carga de config sea parte del span "main-execution"
 	cfg, err := config.LoadConfig(ctxMain, configPath, logger)
@@ -71,6 +82,15 @@ func main() {
 	// 2º configure logger based on loaded config
 	config.ConfigureLogger(cfg, logger) // Reconfigura el logger con la configuración cargada si es necesario
 
+	// Plugins can be reloaded from the config file while the server runs
+	server.ReloadPluginFunc = func(ctx context.Context, name string) error {
+		return config.ReloadPlugin(ctx, configPath, name, logger)
+	}
+	if cfg.Server.Reload.Watch {
+		interval, _ := cfg.Server.Reload.IntervalDuration() // checked by Validate
+		go config.WatchPlugins(ctx, configPath, interval, logger)
+	}
+
 	// 3º start the server
 	// Si StartServer toma un contexto, pasa ctxMain
 	server.StartServer(cfg, logger) // Eliminado ctx para que coincida con la firma actual
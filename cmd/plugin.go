@@ -0,0 +1,93 @@
+// cmd/plugin.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	scaffold "expressops/plugins/template"
+)
+
+const pluginUsage = `Usage: expressops plugin <command> [flags]
+
+Commands:
+  new <name> [--kind notification|check|action] [--dir path]
+         Generate a builtin plugin from plugins/template: its code with a
+         config decoding example, a test using pkg/plugintest, a Makefile
+         target and a config snippet. --dir is the repository root.
+`
+
+// runPluginCommands implements `expressops plugin` and returns the process exit code
+func runPluginCommands(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, pluginUsage)
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	var err error
+	switch args[0] {
+	case "new":
+		err = runPluginNewCommand(args[1:])
+	default:
+		err = fmt.Errorf("unknown plugin command '%s'", args[0])
+	}
+
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runPluginNewCommand(args []string) error {
+	fs := flag.NewFlagSet("plugin new", flag.ContinueOnError)
+	kind := fs.String("kind", scaffold.KindAction, "kind of plugin: "+strings.Join(scaffold.Kinds, ", "))
+	dir := fs.String("dir", ".", "root of the repository")
+
+	// accept the name before or after the flags
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		return errors.New("usage: expressops plugin new <name> [--kind notification|check|action] [--dir path]")
+	}
+
+	if _, err := os.Stat(filepath.Join(*dir, "go.mod")); err != nil {
+		return fmt.Errorf("%s is not the repository root: %w", *dir, err)
+	}
+
+	p := scaffold.Plugin{Name: name, Kind: *kind}
+	paths, err := scaffold.Generate(*dir, p)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		fmt.Printf("created %s\n", path)
+	}
+	fmt.Printf(`
+Next steps:
+  1. Add the plugin to the binary in cmd/builtins.go:
+         _ "expressops/plugins/%s"
+  2. Run its tests: make test-plugin-%s
+  3. Copy %s into your config file
+`, p.Package(), p.Name, filepath.Join(p.Dir(), "config.yaml"))
+	return nil
+}
@@ -0,0 +1,141 @@
+// cmd/secrets.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"expressops/internal/secrets"
+)
+
+const secretsUsage = `Usage: expressops secrets <command> [flags]
+
+Commands:
+  keygen [--out file]
+         Generate the key of the file read by the encrypted secrets provider.
+         Keep it out of the repository; the server reads it from
+         secrets.encrypted.keyFile or the EXPRESSOPS_SECRETS_KEY variable.
+  encrypt <name> [key] [--key-file file]
+         Encrypt the value read from stdin for the entry name (and key) of
+         the encrypted file, and print the line to paste into it.
+`
+
+// runSecretsCommands implements `expressops secrets` and returns the process exit code
+func runSecretsCommands(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stderr, secretsUsage)
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	var err error
+	switch args[0] {
+	case "keygen":
+		err = runKeygenCommand(args[1:])
+	case "encrypt":
+		err = runEncryptCommand(args[1:])
+	default:
+		err = fmt.Errorf("unknown secrets command '%s'", args[0])
+	}
+
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runKeygenCommand(args []string) error {
+	fs := flag.NewFlagSet("secrets keygen", flag.ContinueOnError)
+	outPath := fs.String("out", "", "write the key to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := secrets.NewKey()
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("# created: %s\n# expressops secrets key, keep it out of the repository\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), key)
+	if *outPath == "" {
+		_, err = fmt.Print(content)
+		return err
+	}
+	// Never overwrite a key: the values encrypted with it would be lost
+	f, err := os.OpenFile(*outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runEncryptCommand(args []string) error {
+	fs := flag.NewFlagSet("secrets encrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "file made by `expressops secrets keygen`, defaults to $"+secrets.KeyEnv)
+
+	// accept the name and key before or after the flags
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	positional = append(positional, fs.Args()...)
+	if len(positional) == 0 || len(positional) > 2 {
+		return errors.New("usage: expressops secrets encrypt <name> [key] [--key-file file] < value")
+	}
+	name, secretKey := positional[0], ""
+	if len(positional) == 2 {
+		secretKey = positional[1]
+	}
+
+	var text string
+	if *keyFile == "" {
+		text = os.Getenv(secrets.KeyEnv)
+		if text == "" {
+			return fmt.Errorf("no key: pass --key-file or set %s", secrets.KeyEnv)
+		}
+	} else {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	key, err := secrets.ParseKey(text)
+	if err != nil {
+		return err
+	}
+
+	// The value is read from stdin so that it stays out of the shell history
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	encrypted, err := secrets.EncryptValue(key, name, secretKey, strings.TrimRight(string(value), "\r\n"))
+	if err != nil {
+		return err
+	}
+	if secretKey == "" {
+		fmt.Printf("%s: %s\n", name, encrypted)
+	} else {
+		fmt.Printf("%s:\n  %s: %s\n", name, secretKey, encrypted)
+	}
+	return nil
+}
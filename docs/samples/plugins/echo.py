@@ -0,0 +1,64 @@
+#!/usr/bin/env python3
+"""Minimal ExpressOps out-of-process plugin.
+
+Speaks the JSON-RPC plugin protocol on stdin/stdout, one message per line.
+Select it in the config with:
+
+    plugins:
+      - name: echo
+        process:
+          command: python3
+          args: ["docs/samples/plugins/echo.py"]
+        config:
+          prefix: "echo"
+"""
+import json
+import sys
+
+PROTOCOL_VERSION = 1
+config = {}
+
+
+def send(message):
+    sys.stdout.write(json.dumps(message) + "\n")
+    sys.stdout.flush()
+
+
+def log(level, message, **fields):
+    send({"jsonrpc": "2.0", "method": "log",
+          "params": {"level": level, "message": message, "fields": fields}})
+
+
+def handle(method, params):
+    global config
+    if method == "handshake":
+        return {"protocolVersion": PROTOCOL_VERSION}
+    if method == "initialize":
+        config = params.get("config") or {}
+        log("info", "echo plugin initialized")
+        return None
+    if method == "execute":
+        shared = params.get("shared") or {}
+        shared["echo_calls"] = shared.get("echo_calls", 0) + 1
+        result = {
+            "message": "%s: %s" % (config.get("prefix", "echo"), params["request"]["url"]),
+            "calls": shared["echo_calls"],
+        }
+        return {"result": result, "shared": shared}
+    if method == "formatResult":
+        return {"formatted": json.dumps(params.get("result"))}
+    raise ValueError("unknown method '%s'" % method)
+
+
+for line in sys.stdin:
+    request = json.loads(line)
+    if request.get("method") == "shutdown":
+        break
+    if "id" not in request:
+        continue
+    response = {"jsonrpc": "2.0", "id": request["id"]}
+    try:
+        response["result"] = handle(request["method"], request.get("params") or {})
+    except Exception as exc:  # report plugin errors to the host
+        response["error"] = {"code": -32000, "message": str(exc)}
+    send(response)
@@ -0,0 +1,245 @@
+// Package audit keeps an append-only trail of who ran which flow, from
+// where and with what outcome, and of administrative actions such as
+// plugin reloads. Every record holds the hash of the previous one, so that
+// a record changed or removed from the middle of the trail shows when the
+// trail is verified.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"expressops/internal/redact"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Actions
+const (
+	ActionFlowRun         = "flow.run"
+	ActionExecutionCancel = "execution.cancel"
+	ActionPluginLoad      = "plugin.load"
+	ActionPluginReload    = "plugin.reload"
+	ActionConfigLoad      = "config.load"
+	ActionAuthDenied      = "auth.denied"
+)
+
+// Outcomes, along with the terminal statuses of an execution
+const (
+	OutcomeStarted   = "started"
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeDenied    = "denied"
+)
+
+// Actors that are not callers of the API
+const (
+	// ActorAnonymous made a request without an API token, when none are
+	// configured or on the /flow endpoint
+	ActorAnonymous = "anonymous"
+	// ActorSystem is the server itself, e.g. loading the config
+	ActorSystem = "system"
+	// ActorWatcher is the watcher reloading changed plugins
+	ActorWatcher = "watcher"
+)
+
+// Event is a record of the trail. Seq, Time, PrevHash and Hash are set when
+// it is recorded.
+type Event struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Outcome string    `json:"outcome"`
+	// Actor is who acted: token:<fingerprint> for a caller of the API, or
+	// one of the Actor constants
+	Actor        string                 `json:"actor"`
+	Source       string                 `json:"source,omitempty"`
+	ForwardedFor string                 `json:"forwardedFor,omitempty"`
+	UserAgent    string                 `json:"userAgent,omitempty"`
+	Flow         string                 `json:"flow,omitempty"`
+	Plugins      []string               `json:"plugins,omitempty"`
+	ExecutionID  string                 `json:"executionId,omitempty"`
+	Plugin       string                 `json:"plugin,omitempty"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	PrevHash     string                 `json:"prevHash"`
+	Hash         string                 `json:"hash"`
+}
+
+// Sink stores the records of a trail, one JSON document each
+type Sink interface {
+	WriteRecord(record []byte) error
+	Close() error
+}
+
+// resumer is implemented by the sinks that can read back the last record
+// they hold, so that the chain goes on across restarts
+type resumer interface {
+	Last() (seq uint64, hash string)
+}
+
+// Trail chains events and writes them to its sinks
+type Trail struct {
+	mu    sync.Mutex
+	sinks []Sink
+	seq   uint64
+	last  string
+	now   func() time.Time
+}
+
+// New returns a trail writing to sinks. It goes on from the last record of
+// the first sink that holds one. Without sinks nothing is recorded.
+func New(sinks ...Sink) *Trail {
+	t := &Trail{sinks: sinks, now: time.Now}
+	for _, sink := range sinks {
+		if r, ok := sink.(resumer); ok {
+			if seq, hash := r.Last(); seq > 0 {
+				t.seq, t.last = seq, hash
+				break
+			}
+		}
+	}
+	return t
+}
+
+var defaultTrail atomic.Pointer[Trail]
+
+func init() {
+	defaultTrail.Store(New())
+}
+
+// Default returns the trail of the process, which records nothing until
+// the config sets it up
+func Default() *Trail {
+	return defaultTrail.Load()
+}
+
+// SetDefault replaces the trail of the process and closes the previous one
+func SetDefault(t *Trail) error {
+	return defaultTrail.Swap(t).Close()
+}
+
+// Record records e on the default trail. A record that cannot be written
+// is logged and does not stop the action it describes.
+func Record(e Event) {
+	if err := Default().Record(e); err != nil {
+		logrus.Errorf("Error writing audit record %s of %s: %v", e.Action, e.Actor, err)
+	}
+}
+
+// Record chains e to the previous record and writes it to every sink.
+// Secrets are masked in its params and error.
+func (t *Trail) Record(e Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sinks) == 0 {
+		return nil
+	}
+
+	redactor := redact.Default()
+	e.Params = redactor.Map(e.Params)
+	e.Error = redactor.String(e.Error)
+	e.Seq = t.seq + 1
+	e.Time = t.now().UTC()
+	e.PrevHash = t.last
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	hash, err := hashRecord(data)
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+	if data, err = json.Marshal(e); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sink := range t.sinks {
+		errs = append(errs, sink.WriteRecord(data))
+	}
+	// The chain goes on even if a sink failed, so that the others stay
+	// verifiable
+	t.seq, t.last = e.Seq, hash
+	return errors.Join(errs...)
+}
+
+// Close closes the sinks of the trail
+func (t *Trail) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for _, sink := range t.sinks {
+		errs = append(errs, sink.Close())
+	}
+	t.sinks = nil
+	return errors.Join(errs...)
+}
+
+// hashRecord returns the hash of a record: the SHA-256 of its JSON
+// document with an empty hash and its keys sorted, which includes the hash
+// of the previous record
+func hashRecord(record []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+	fields["hash"] = ""
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Verify checks the chain of the records read from r, one per line, and
+// returns how many there are. The first record may follow records that
+// are no longer there, e.g. after the file was rotated.
+func Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var previous Event
+	n := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		n++
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return n - 1, fmt.Errorf("record %d: not an audit record: %w", n, err)
+		}
+		hash, err := hashRecord(line)
+		if err != nil {
+			return n - 1, fmt.Errorf("record %d: %w", n, err)
+		}
+		switch {
+		case hash != e.Hash:
+			return n - 1, fmt.Errorf("record %d (seq %d): hash mismatch, the record was changed", n, e.Seq)
+		case n > 1 && e.PrevHash != previous.Hash:
+			return n - 1, fmt.Errorf("record %d (seq %d): previous hash mismatch, a record before it was changed or removed", n, e.Seq)
+		case n > 1 && e.Seq != previous.Seq+1:
+			return n - 1, fmt.Errorf("record %d: seq %d follows %d, records were removed", n, e.Seq, previous.Seq)
+		}
+		previous = e
+	}
+	return n, scanner.Err()
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expressops/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestTrail(t *testing.T) {
+	redact.Default().Add("audit-secret-value")
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	file, err := OpenFile(path)
+	require.NoError(t, err)
+	trail := New(file)
+	require.NoError(t, trail.Record(Event{
+		Action: ActionFlowRun, Outcome: OutcomeStarted, Actor: "token:0123abcd", Source: "10.0.0.1:5000",
+		Flow: "create-user", ExecutionID: "e1",
+		Params: map[string]interface{}{"user": "alice", "password": "hunter2", "count": 3},
+	}))
+	require.NoError(t, trail.Record(Event{
+		Action: ActionFlowRun, Outcome: OutcomeFailed, Actor: "token:0123abcd",
+		Flow: "create-user", ExecutionID: "e1", Error: "rejected audit-secret-value",
+	}))
+	require.NoError(t, trail.Close())
+
+	// The chain goes on when the file is opened again
+	file, err = OpenFile(path)
+	require.NoError(t, err)
+	trail = New(file)
+	require.NoError(t, trail.Record(Event{Action: ActionPluginReload, Outcome: OutcomeSucceeded, Actor: ActorWatcher, Plugin: "slack"}))
+	require.NoError(t, trail.Close())
+
+	events := readEvents(t, path)
+	require.Len(t, events, 3)
+	assert.Equal(t, []uint64{1, 2, 3}, []uint64{events[0].Seq, events[1].Seq, events[2].Seq})
+	assert.Empty(t, events[0].PrevHash)
+	assert.Equal(t, events[0].Hash, events[1].PrevHash)
+	assert.Equal(t, events[1].Hash, events[2].PrevHash)
+	assert.Equal(t, redact.Mask, events[0].Params["password"])
+	assert.Equal(t, "alice", events[0].Params["user"])
+	assert.Equal(t, "rejected [REDACTED]", events[1].Error)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	n, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	lines := strings.SplitAfter(string(data), "\n")
+	// A changed record
+	changed := strings.Replace(string(data), `"user":"alice"`, `"user":"mallory"`, 1)
+	n, err = Verify(strings.NewReader(changed))
+	assert.ErrorContains(t, err, "record 1 (seq 1): hash mismatch")
+	assert.Equal(t, 0, n)
+	// A removed record
+	_, err = Verify(strings.NewReader(lines[0] + lines[2]))
+	assert.ErrorContains(t, err, "record 2 (seq 3): previous hash mismatch")
+	// The first records rotated away
+	n, err = Verify(strings.NewReader(lines[1] + lines[2]))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// A file that does not end with a record is not appended to
+	require.NoError(t, os.WriteFile(path, append(data, "edited by hand\n"...), 0o600))
+	_, err = OpenFile(path)
+	assert.ErrorContains(t, err, "does not end with an audit record")
+}
+
+func TestTrailWithoutSinks(t *testing.T) {
+	trail := New()
+	assert.NoError(t, trail.Record(Event{Action: ActionFlowRun}))
+	assert.NoError(t, trail.Close())
+}
+
+func TestSyslog(t *testing.T) {
+	// Unix socket paths are limited in length, so avoid the long TempDir
+	dir, err := os.MkdirTemp("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := DialSyslog("unixgram", socket, "expressops-audit")
+	require.NoError(t, err)
+	trail := New(sink)
+	require.NoError(t, trail.Record(Event{Action: ActionConfigLoad, Outcome: OutcomeSucceeded, Actor: ActorSystem}))
+	require.NoError(t, trail.Close())
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	message := string(buf[:n])
+	// facility auth (4) * 8 + severity notice (5)
+	assert.True(t, strings.HasPrefix(message, "<37>"), message)
+	assert.Contains(t, message, "expressops-audit")
+	assert.Contains(t, message, `"action":"config.load"`)
+}
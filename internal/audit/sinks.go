@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+)
+
+// tailSize is how much of the end of an audit file is read to find its
+// last record
+const tailSize = 64 << 10
+
+// File appends records to a file, one per line
+type File struct {
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	hash string
+}
+
+// OpenFile opens the audit file at path for appending, creating it when
+// missing. A file that does not end with an audit record is refused, since
+// the chain could not go on from it.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	last, err := lastLine(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading audit file '%s': %w", path, err)
+	}
+	file := &File{f: f}
+	if len(last) > 0 {
+		var e Event
+		if err := json.Unmarshal(last, &e); err != nil || e.Hash == "" {
+			f.Close()
+			return nil, fmt.Errorf("audit file '%s' does not end with an audit record, move it away to start a new trail", path)
+		}
+		file.seq, file.hash = e.Seq, e.Hash
+	}
+	return file, nil
+}
+
+// lastLine returns the last non-empty line of f
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := info.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	tail = bytes.TrimRight(tail, "\r\n")
+	if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	return tail, nil
+}
+
+// Last returns the seq and hash of the last record of the file when it
+// was opened
+func (f *File) Last() (uint64, string) {
+	return f.seq, f.hash
+}
+
+// WriteRecord appends a record and syncs it to disk
+func (f *File) WriteRecord(record []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.f.Write(append(record, '\n')); err != nil {
+		return err
+	}
+	return f.f.Sync()
+}
+
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// syslogSink sends records to a syslog daemon with the auth facility
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// DialSyslog connects to the syslog daemon at address over network, or to
+// the local daemon when both are empty. Records are sent with tag.
+func DialSyslog(network, address, tag string) (Sink, error) {
+	w, err := syslog.Dial(network, address, syslog.LOG_NOTICE|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return syslogSink{w: w}, nil
+}
+
+func (s syslogSink) WriteRecord(record []byte) error {
+	return s.w.Notice(string(record))
+}
+
+func (s syslogSink) Close() error {
+	return s.w.Close()
+}
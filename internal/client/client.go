@@ -0,0 +1,297 @@
+// Package client provides an HTTP client for the ExpressOps REST API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"expressops/api/v1beta1"
+)
+
+// Client talks to the /api/v1 endpoints of a running ExpressOps server
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// APIError is returned when the server answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// New creates a client for the server at baseURL, authenticating with token if set
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// ListFlows returns a summary of every flow registered in the server
+func (c *Client) ListFlows(ctx context.Context) ([]v1beta1.FlowSummary, error) {
+	var flows []v1beta1.FlowSummary
+	err := c.do(ctx, http.MethodGet, "/api/v1/flows", nil, &flows)
+	return flows, err
+}
+
+// ListPlugins returns the plugins loaded in the server with their manifest
+func (c *Client) ListPlugins(ctx context.Context) ([]v1beta1.PluginSummary, error) {
+	var plugins []v1beta1.PluginSummary
+	err := c.do(ctx, http.MethodGet, "/api/v1/plugins", nil, &plugins)
+	return plugins, err
+}
+
+// ReloadPlugin makes the server load a new instance of a plugin from its
+// config file and returns the plugin as now loaded
+func (c *Client) ReloadPlugin(ctx context.Context, name string) (*v1beta1.PluginSummary, error) {
+	var plugin v1beta1.PluginSummary
+	if err := c.do(ctx, http.MethodPost, "/api/v1/plugins/"+url.PathEscape(name)+"/reload", nil, &plugin); err != nil {
+		return nil, err
+	}
+	return &plugin, nil
+}
+
+// GetFlow returns the full definition of a flow
+func (c *Client) GetFlow(ctx context.Context, name string) (*v1beta1.Flow, error) {
+	var flow v1beta1.Flow
+	if err := c.do(ctx, http.MethodGet, "/api/v1/flows/"+url.PathEscape(name), nil, &flow); err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// Run starts a flow. If wait is true the call blocks until the flow finishes.
+func (c *Client) Run(ctx context.Context, flow string, params map[string]interface{}, wait bool) (*v1beta1.Execution, error) {
+	path := "/api/v1/executions"
+	if wait {
+		path += "?wait=true"
+	}
+
+	// A waited run takes as long as the flow, which ctx and the server
+	// timeout bound rather than the client timeout
+	client := c
+	if wait {
+		client = c.withoutTimeout()
+	}
+
+	var exec v1beta1.Execution
+	body := v1beta1.ExecutionRequest{Flow: flow, Params: params}
+	if err := client.do(ctx, http.MethodPost, path, body, &exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
+
+// GetFlowGraph returns the execution plan of a flow rendered as "mermaid" or "dot".
+// If executionID is set the steps are colored by the outcome of that run.
+func (c *Client) GetFlowGraph(ctx context.Context, name, format, executionID string) (string, error) {
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	if executionID != "" {
+		query.Set("execution", executionID)
+	}
+
+	path := "/api/v1/flows/" + url.PathEscape(name) + "/graph"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	data, err := c.send(ctx, http.MethodGet, path, nil)
+	return string(data), err
+}
+
+// ListExecutions returns the executions kept by the server, newest first
+func (c *Client) ListExecutions(ctx context.Context) ([]v1beta1.Execution, error) {
+	var list []v1beta1.Execution
+	err := c.do(ctx, http.MethodGet, "/api/v1/executions", nil, &list)
+	return list, err
+}
+
+// GetExecution returns the current state of an execution
+func (c *Client) GetExecution(ctx context.Context, id string) (*v1beta1.Execution, error) {
+	var exec v1beta1.Execution
+	if err := c.do(ctx, http.MethodGet, "/api/v1/executions/"+url.PathEscape(id), nil, &exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
+
+// CancelExecution asks the server to cancel a running execution
+func (c *Client) CancelExecution(ctx context.Context, id string) (*v1beta1.Execution, error) {
+	var exec v1beta1.Execution
+	if err := c.do(ctx, http.MethodPost, "/api/v1/executions/"+url.PathEscape(id)+"/cancel", nil, &exec); err != nil {
+		return nil, err
+	}
+	return &exec, nil
+}
+
+// ExecutionLogs calls onLine with each line logged by an execution. With
+// follow it keeps reading new lines until the execution finishes or ctx is
+// done.
+func (c *Client) ExecutionLogs(ctx context.Context, id string, follow bool, onLine func(v1beta1.ExecutionLog)) error {
+	path := "/api/v1/executions/" + url.PathEscape(id) + "/logs"
+	if follow {
+		path += "?follow=true"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	// A followed execution may run for longer than the client timeout
+	httpClient := c.httpClient
+	if follow {
+		httpClient = c.withoutTimeout().httpClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var line v1beta1.ExecutionLog
+		if err := dec.Decode(&line); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error decoding log line: %w", err)
+		}
+		onLine(line)
+	}
+}
+
+// WatchExecution polls an execution every interval, calling onUpdate whenever
+// its status changes, until it reaches a terminal state or ctx is done
+func (c *Client) WatchExecution(ctx context.Context, id string, interval time.Duration, onUpdate func(*v1beta1.Execution)) (*v1beta1.Execution, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastStatus v1beta1.ExecutionStatus
+	for {
+		exec, err := c.GetExecution(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if exec.Status != lastStatus {
+			lastStatus = exec.Status
+			if onUpdate != nil {
+				onUpdate(exec)
+			}
+		}
+		if exec.Status.Finished() {
+			return exec, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return exec, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// withoutTimeout returns a copy of the client whose requests are only
+// bounded by their context
+func (c *Client) withoutTimeout() *Client {
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	return &Client{baseURL: c.baseURL, token: c.token, httpClient: &httpClient}
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	data, err := c.send(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// send performs the request and returns the raw response body of a 2xx answer
+func (c *Client) send(ctx context.Context, method, path string, in interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// apiError reads the body of a non-2xx response into an APIError
+func apiError(resp *http.Response) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return newAPIError(resp.StatusCode, data)
+}
+
+func newAPIError(status int, body []byte) *APIError {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+		msg = apiErr.Error
+	}
+	return &APIError{StatusCode: status, Message: msg}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"expressops/api/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSendsTokenAndDecodesFlows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		assert.Equal(t, "/api/v1/flows", r.URL.Path)
+		_ = json.NewEncoder(w).Encode([]v1beta1.FlowSummary{{Name: "alert-flow", Plugins: []string{"a", "b"}}})
+	}))
+	defer srv.Close()
+
+	flows, err := New(srv.URL+"/", "abc").ListFlows(context.Background())
+	require.NoError(t, err)
+	require.Len(t, flows, 1)
+	assert.Equal(t, "alert-flow", flows[0].Name)
+}
+
+func TestClientReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"Flow 'x' not found"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL, "").GetFlow(context.Background(), "x")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Flow 'x' not found", apiErr.Message)
+}
+
+func TestExecutionLogs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/executions/e1/logs", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("follow"))
+		enc := json.NewEncoder(w)
+		_ = enc.Encode(v1beta1.ExecutionLog{Level: "info", Message: "first"})
+		_ = enc.Encode(v1beta1.ExecutionLog{Level: "error", Message: "second", Fields: map[string]interface{}{"step": "a"}})
+	}))
+	defer srv.Close()
+
+	var lines []v1beta1.ExecutionLog
+	err := New(srv.URL, "").ExecutionLogs(context.Background(), "e1", true, func(line v1beta1.ExecutionLog) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "second", lines[1].Message)
+	assert.Equal(t, "a", lines[1].Fields["step"])
+}
+
+func TestRunWaitOutlastsClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(v1beta1.Execution{ID: "e1", Status: v1beta1.ExecutionSucceeded})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "")
+	c.httpClient.Timeout = 20 * time.Millisecond
+
+	exec, err := c.Run(context.Background(), "slow", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, v1beta1.ExecutionSucceeded, exec.Status)
+
+	// Other calls keep the client timeout
+	_, err = c.Run(context.Background(), "slow", nil, false)
+	assert.ErrorContains(t, err, "Client.Timeout exceeded")
+}
+
+func TestWatchExecutionStopsWhenFinished(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := v1beta1.ExecutionRunning
+		if atomic.AddInt32(&calls, 1) >= 3 {
+			status = v1beta1.ExecutionSucceeded
+		}
+		_ = json.NewEncoder(w).Encode(v1beta1.Execution{ID: "e1", Status: status})
+	}))
+	defer srv.Close()
+
+	var updates []v1beta1.ExecutionStatus
+	exec, err := New(srv.URL, "").WatchExecution(context.Background(), "e1", time.Millisecond, func(e *v1beta1.Execution) {
+		updates = append(updates, e.Status)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, v1beta1.ExecutionSucceeded, exec.Status)
+	assert.Equal(t, []v1beta1.ExecutionStatus{v1beta1.ExecutionRunning, v1beta1.ExecutionSucceeded}, updates)
+}
+
+func TestConfigContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	_, err = cfg.Lookup("")
+	assert.Error(t, err)
+
+	cfg.SetContext(Context{Name: "prod", Server: "https://prod", Token: "t1"})
+	cfg.SetContext(Context{Name: "dev", Server: "http://localhost:8080"})
+	cfg.SetContext(Context{Name: "prod", Token: "t2"})
+	cfg.CurrentContext = "dev"
+	require.NoError(t, cfg.Save(path))
+
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	current, err := loaded.Lookup("")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", current.Server)
+
+	prod, err := loaded.Lookup("prod")
+	require.NoError(t, err)
+	assert.Equal(t, "https://prod", prod.Server)
+	assert.Equal(t, "t2", prod.Token)
+}
@@ -0,0 +1,100 @@
+// internal/client/contexts.go
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnvVar overrides the location of the client context file
+const ConfigEnvVar = "EXPRESSOPS_CLIENT_CONFIG"
+
+// Context is a named ExpressOps server the client can talk to
+type Context struct {
+	Name   string `yaml:"name"`
+	Server string `yaml:"server"`
+	Token  string `yaml:"token,omitempty"`
+}
+
+// Config is the kubeconfig-like file listing known servers
+type Config struct {
+	CurrentContext string    `yaml:"currentContext"`
+	Contexts       []Context `yaml:"contexts"`
+}
+
+// DefaultConfigPath returns $EXPRESSOPS_CLIENT_CONFIG or ~/.expressops/config
+func DefaultConfigPath() string {
+	if path := os.Getenv(ConfigEnvVar); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".expressops", "config")
+	}
+	return filepath.Join(home, ".expressops", "config")
+}
+
+// LoadConfig reads the context file. A missing file yields an empty config.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing client config '%s': %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes the context file, creating its directory if needed.
+// The file may contain tokens so it is only readable by the owner.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Lookup returns the context with the given name, or the current context if name is empty
+func (c *Config) Lookup(name string) (*Context, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no context selected, use --server or `expressops client config use-context`")
+	}
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("context '%s' not found", name)
+}
+
+// SetContext adds a context or updates the existing one with the same name
+func (c *Config) SetContext(ctx Context) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == ctx.Name {
+			if ctx.Server != "" {
+				c.Contexts[i].Server = ctx.Server
+			}
+			if ctx.Token != "" {
+				c.Contexts[i].Token = ctx.Token
+			}
+			return
+		}
+	}
+	c.Contexts = append(c.Contexts, ctx)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+)
+
+// defaultSyslogTag is the tag of the audit records sent to syslog when the
+// config sets none
+const defaultSyslogTag = "expressops"
+
+// configureAudit opens the sinks of the audit trail of cfg. Without a file
+// or syslog nothing is recorded.
+func configureAudit(cfg *v1beta1.Config) error {
+	var sinks []audit.Sink
+	if cfg.Audit.File != "" {
+		file, err := audit.OpenFile(cfg.Audit.File)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, file)
+	}
+	if s := cfg.Audit.Syslog; s != nil {
+		tag := s.Tag
+		if tag == "" {
+			tag = defaultSyslogTag
+		}
+		sink, err := audit.DialSyslog(s.Network, s.Address, tag)
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return err
+		}
+		sinks = append(sinks, sink)
+	}
+	return audit.SetDefault(audit.New(sinks...))
+}
+
+// auditPlugin records the outcome of loading or reloading a plugin
+func auditPlugin(action, actor, name string, err error) {
+	e := audit.Event{Action: action, Outcome: audit.OutcomeSucceeded, Actor: actor, Plugin: name}
+	if err != nil {
+		e.Outcome, e.Error = audit.OutcomeFailed, err.Error()
+	}
+	audit.Record(e)
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expressops/internal/audit"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigAudit(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	auditPath := filepath.Join(dir, "audit.log")
+	t.Cleanup(func() { _ = audit.SetDefault(audit.New()) })
+
+	write := func(greeting, builtin string) {
+		data := "apiVersion: expressops/v1beta1\naudit:\n  file: " + auditPath +
+			"\nplugins:\n  - name: greeter\n    builtin: " + builtin + "\n    config:\n      greeting: " + greeting + "\n"
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	write("hello", "reload-test")
+	_, err := LoadConfig(ctx, path, logger)
+	require.NoError(t, err)
+
+	w := &pluginWatcher{path: path, logger: logger}
+	require.NoError(t, w.scan(ctx, false))
+	write("hi", "reload-test")
+	require.NoError(t, w.scan(ctx, true))
+
+	write("hello", "missing")
+	_, err = LoadConfig(ctx, path, logger)
+	require.Error(t, err)
+
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	var events []audit.Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		events = append(events, e)
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, audit.ActionConfigLoad, events[0].Action)
+	assert.Equal(t, audit.ActorSystem, events[0].Actor)
+	assert.Equal(t, audit.ActionPluginReload, events[1].Action)
+	assert.Equal(t, audit.ActorWatcher, events[1].Actor)
+	assert.Equal(t, audit.OutcomeSucceeded, events[1].Outcome)
+	assert.Equal(t, "greeter", events[1].Plugin)
+	assert.Equal(t, audit.ActionPluginLoad, events[2].Action)
+	assert.Equal(t, audit.OutcomeFailed, events[2].Outcome)
+	assert.NotEmpty(t, events[2].Error)
+
+	// Loading the config again went on with the same chain
+	n, err := audit.Verify(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
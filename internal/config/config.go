@@ -2,14 +2,21 @@
 package config
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect" // used for setting default values via struct tags
 	"strconv"
 
 	"expressops/api/v1alpha1"
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
 	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/plugin/rpc"
+	"expressops/internal/redact"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
@@ -26,32 +33,116 @@ func InitializeLogger() *logrus.Logger {
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
 
+	// Mask secrets in every line, including those logged through the
+	// standard logger
+	logger.AddHook(redact.Default().Hook())
+	logrus.AddHook(redact.Default().Hook())
+
 	return logger
 }
 
-// LoadConfig loads the configuration from the specified YAML file
-// Load the configuration from YAML
+// ReadConfig reads and strictly decodes the YAML file without loading plugins.
+// Unknown or misspelled keys are reported with their line number. Files of an
+// older apiVersion are converted to the newest one.
+func ReadConfig(path string) (*v1beta1.Config, error) {
+	cfg, _, err := readConfig(path)
+	return cfg, err
+}
 
-func LoadConfig(ctx context.Context, path string, logger *logrus.Logger) (*v1alpha1.Config, error) {
+// readConfig is ReadConfig that also returns the apiVersion of the file
+func readConfig(path string) (*v1beta1.Config, string, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	// Expand environment variables in the config file
 	expandedData := os.ExpandEnv(string(data))
 
-	// Unmarshal YAML data into Config struct
-	var cfg v1alpha1.Config
-	if err := yaml.Unmarshal([]byte(expandedData), &cfg); err != nil {
-		return nil, fmt.Errorf("error unmarshaling YAML: %w", err)
+	cfg, version, err := DecodeConfig([]byte(expandedData))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config '%s': %w", path, err)
+	}
+	return cfg, version, nil
+}
+
+// DecodeConfig strictly decodes a YAML document of any supported apiVersion,
+// converts it to v1beta1 and validates it. It also returns the apiVersion the
+// document was written in; documents without one are v1alpha1.
+func DecodeConfig(data []byte) (*v1beta1.Config, string, error) {
+	var meta struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, "", fmt.Errorf("error unmarshaling YAML: %w", err)
+	}
+	if meta.Kind != "" && meta.Kind != v1beta1.Kind {
+		return nil, "", fmt.Errorf("unsupported kind '%s' (expected %s)", meta.Kind, v1beta1.Kind)
+	}
+
+	cfg := &v1beta1.Config{}
+	version := meta.APIVersion
+	switch version {
+	case "", v1alpha1.GroupVersion:
+		version = v1alpha1.GroupVersion
+		var old v1alpha1.Config
+		if err := decodeStrict(data, &old); err != nil {
+			return nil, "", err
+		}
+		old.ConvertTo(cfg)
+	case v1beta1.GroupVersion:
+		if err := decodeStrict(data, cfg); err != nil {
+			return nil, "", err
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported apiVersion '%s' (supported: %s, %s)",
+			meta.APIVersion, v1alpha1.GroupVersion, v1beta1.GroupVersion)
+	}
+
+	v1beta1.SetDefaults(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, "", err
+	}
+	return cfg, version, nil
+}
+
+// decodeStrict decodes YAML into out, failing on unknown fields
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error unmarshaling YAML: %w", err)
+	}
+	return nil
+}
+
+// LoadConfig loads the configuration from the specified YAML file
+// and loads every plugin it declares
+func LoadConfig(ctx context.Context, path string, logger *logrus.Logger) (*v1beta1.Config, error) {
+	cfg, version, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if version != v1beta1.GroupVersion {
+		logger.Warnf("Config '%s' uses apiVersion %s, converted to %s. Run `expressops config migrate %s` to update it.",
+			path, version, v1beta1.GroupVersion, path)
 	}
 
 	// Apply defaults from struct tags
-	applyDefaults(&cfg, logger)
+	applyDefaults(cfg, logger)
 
 	// Override with environment variables if they exist
-	ApplyEnvironmentOverrides(&cfg, logger)
+	ApplyEnvironmentOverrides(cfg, logger)
+
+	// Secrets must be known before plugins log or return them
+	if err := configureRedaction(cfg); err != nil {
+		return nil, err
+	}
+	configureSecrets(cfg)
+	if err := configureAudit(cfg); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
 
 	logger.Info("Base configuration loaded. Processing plugins...")
 
@@ -65,25 +156,71 @@ func LoadConfig(ctx context.Context, path string, logger *logrus.Logger) (*v1alp
 			continue
 		}
 
-		logger.Debugf("Loading plugin code: %s (Path: %s)", pluginCfg.Name, pluginCfg.Path)
-		if err := pluginManager.LoadPlugin(ctx, pluginCfg.Path, pluginCfg.Name, pluginCfg.Config, logger); err != nil {
-			// Detailed error message
-			return nil, fmt.Errorf("error loading plugin '%s' from '%s': %w\n"+
-				"Please check:\n"+
-				"- The plugin file exists\n"+
-				"- The plugin was built for the correct architecture\n"+
-				"- You have the necessary permissions to access the file",
-				pluginCfg.Name, pluginCfg.Path, err)
+		if err := loadPlugin(ctx, pluginCfg, logger); err != nil {
+			auditPlugin(audit.ActionPluginLoad, audit.ActorSystem, pluginCfg.Name, err)
+			return nil, err
 		}
 		logger.Infof("Plugin '%s' processed successfully.", pluginCfg.Name)
 	}
 
 	logger.Info("All plugins processed. Final configuration ready.")
-	return &cfg, nil
+	audit.Record(audit.Event{Action: audit.ActionConfigLoad, Outcome: audit.OutcomeSucceeded, Actor: audit.ActorSystem})
+	return cfg, nil
+}
+
+// loadPlugin loads a plugin entry from its source: builtin, process or .so
+// file, with the secretRefs of its config resolved
+func loadPlugin(ctx context.Context, pluginCfg *v1beta1.Plugin, logger *logrus.Logger) error {
+	config, err := pluginConfig(ctx, pluginCfg)
+	if err != nil {
+		return fmt.Errorf("plugin '%s': %w", pluginCfg.Name, err)
+	}
+
+	if builtin := pluginCfg.BuiltinName(); builtin != "" {
+		logger.Debugf("Loading builtin plugin: %s (Builtin: %s)", pluginCfg.Name, builtin)
+		if err := pluginManager.LoadBuiltin(ctx, builtin, pluginCfg.Name, config, logger); err != nil {
+			return fmt.Errorf("error loading builtin plugin '%s': %w", pluginCfg.Name, err)
+		}
+		return nil
+	}
+
+	if proc := pluginCfg.Process; proc != nil {
+		logger.Debugf("Starting plugin process: %s (Command: %s)", pluginCfg.Name, proc.Command)
+		instance := newProcess(pluginCfg)
+		if err := pluginManager.LoadInstance(ctx, instance, pluginCfg.Name, config, logger); err != nil {
+			_ = instance.Close()
+			return fmt.Errorf("error starting plugin process '%s': %w", pluginCfg.Name, err)
+		}
+		return nil
+	}
+
+	logger.Debugf("Loading plugin code: %s (Path: %s)", pluginCfg.Name, pluginCfg.Path)
+	if err := pluginManager.LoadPlugin(ctx, pluginCfg.Path, pluginCfg.Name, config, logger); err != nil {
+		// Detailed error message
+		return fmt.Errorf("error loading plugin '%s' from '%s': %w\n"+
+			"Please check:\n"+
+			"- The plugin file exists\n"+
+			"- The plugin was built for the correct architecture\n"+
+			"- You have the necessary permissions to access the file",
+			pluginCfg.Name, pluginCfg.Path, err)
+	}
+	return nil
+}
+
+func newProcess(pluginCfg *v1beta1.Plugin) *rpc.Process {
+	proc := pluginCfg.Process
+	timeout, _ := proc.TimeoutDuration() // checked by Validate
+	return rpc.NewProcess(pluginCfg.Name, rpc.Spec{
+		Command:     proc.Command,
+		Args:        proc.Args,
+		Env:         proc.Env,
+		Dir:         proc.Dir,
+		CallTimeout: timeout,
+	})
 }
 
 // applyDefaults applies default values from struct tags if not set
-func applyDefaults(cfg *v1alpha1.Config, logger *logrus.Logger) {
+func applyDefaults(cfg *v1beta1.Config, logger *logrus.Logger) {
 	// Apply server defaults if not set
 	serverType := reflect.TypeOf(cfg.Server)
 	serverValue := reflect.ValueOf(&cfg.Server).Elem()
@@ -111,7 +248,7 @@ func applyDefaults(cfg *v1alpha1.Config, logger *logrus.Logger) {
 }
 
 // ApplyEnvironmentOverrides overrides configuration with environment variables
-func ApplyEnvironmentOverrides(cfg *v1alpha1.Config, logger *logrus.Logger) {
+func ApplyEnvironmentOverrides(cfg *v1beta1.Config, logger *logrus.Logger) {
 	// Server configuration
 	if portStr := os.Getenv("SERVER_PORT"); portStr != "" {
 		if port, err := strconv.Atoi(portStr); err == nil {
@@ -150,7 +287,7 @@ func ApplyEnvironmentOverrides(cfg *v1alpha1.Config, logger *logrus.Logger) {
 
 // ConfigureLogger sets up the logger based on the provided configuration
 
-func ConfigureLogger(cfg *v1alpha1.Config, logger *logrus.Logger) {
+func ConfigureLogger(cfg *v1beta1.Config, logger *logrus.Logger) {
 	// Configure based on config
 	var formatter logrus.Formatter
 	switch cfg.Logging.Format {
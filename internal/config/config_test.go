@@ -0,0 +1,231 @@
+package config
+
+import (
+	"testing"
+
+	"expressops/api/v1alpha1"
+	"expressops/api/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeConfigRejectsUnknownFields(t *testing.T) {
+	yamlConfig := `
+flows:
+  - name: test-flow
+    pipeline:
+      - pluginRef: a
+      - pluginRef: b
+        dependOn:
+          - a
+`
+	_, _, err := DecodeConfig([]byte(yamlConfig))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 7")
+	assert.Contains(t, err.Error(), "field dependOn not found")
+}
+
+func TestDecodeConfigKeepsDescription(t *testing.T) {
+	yamlConfig := `
+flows:
+  - name: test-flow
+    description: "Notify the team"
+    pipeline:
+      - pluginRef: a
+`
+	cfg, _, err := DecodeConfig([]byte(yamlConfig))
+	require.NoError(t, err)
+	require.Len(t, cfg.Flows, 1)
+	assert.Equal(t, "Notify the team", cfg.Flows[0].Description)
+}
+
+func TestDecodeConfigEmptyDocument(t *testing.T) {
+	cfg, _, err := DecodeConfig([]byte(""))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Flows)
+}
+
+func TestDecodeConfigConvertsLegacyVersion(t *testing.T) {
+	yamlConfig := `
+flows:
+  - name: onboarding
+    pipeline:
+      - pluginRef: create-user
+      - pluginRef: slack
+        dependsOn: [create-user]
+      - pluginRef: slack
+        dependsOn: [slack]
+`
+	cfg, version, err := DecodeConfig([]byte(yamlConfig))
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.GroupVersion, version)
+	assert.Equal(t, v1beta1.GroupVersion, cfg.APIVersion)
+	assert.Equal(t, v1beta1.Kind, cfg.Kind)
+
+	pipeline := cfg.Flows[0].Pipeline
+	assert.Equal(t, "slack", pipeline[1].ID)
+	assert.Equal(t, "slack-2", pipeline[2].ID)
+	// v1alpha1 resolved a plugin name to its last step
+	assert.Equal(t, []string{"slack-2"}, pipeline[2].DependsOn)
+}
+
+func TestDecodeConfigV1beta1(t *testing.T) {
+	yamlConfig := `
+apiVersion: expressops/v1beta1
+kind: Config
+flows:
+  - name: alert
+    pipeline:
+      - id: check
+        pluginRef: health-check-plugin
+        timeout: 10s
+        retry:
+          attempts: 3
+          backoff: 1s
+      - pluginRef: slack-notifier
+        dependsOn: [check]
+        condition: on_failure
+`
+	cfg, version, err := DecodeConfig([]byte(yamlConfig))
+	require.NoError(t, err)
+	assert.Equal(t, v1beta1.GroupVersion, version)
+
+	pipeline := cfg.Flows[0].Pipeline
+	assert.Equal(t, "10s", pipeline[0].Timeout)
+	assert.Equal(t, 3, pipeline[0].Retry.Attempts)
+	assert.Equal(t, "slack-notifier", pipeline[1].ID)
+	assert.Equal(t, v1beta1.ConditionOnFailure, pipeline[1].Condition)
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{
+			name:     "unknown apiVersion",
+			yaml:     "apiVersion: expressops/v2\n",
+			expected: "unsupported apiVersion 'expressops/v2'",
+		},
+		{
+			name:     "unknown kind",
+			yaml:     "apiVersion: expressops/v1beta1\nkind: Flow\n",
+			expected: "unsupported kind 'Flow'",
+		},
+		{
+			name:     "v1beta1 field in a v1alpha1 file",
+			yaml:     "flows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        timeout: 1s\n",
+			expected: "field timeout not found",
+		},
+		{
+			name:     "duplicate step id",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n      - pluginRef: a\n",
+			expected: "duplicate step id 'a'",
+		},
+		{
+			name:     "invalid timeout",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        timeout: soon\n",
+			expected: "invalid timeout",
+		},
+		{
+			name:     "unknown dependency",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        dependsOn: [b]\n",
+			expected: "dependsOn 'b' matches no step",
+		},
+		{
+			name:     "invalid redaction pattern",
+			yaml:     "apiVersion: expressops/v1beta1\nredaction:\n  patterns: ['xox(']\n",
+			expected: "redaction: invalid patterns expression 'xox('",
+		},
+		{
+			name:     "invalid secret ref in a plugin config",
+			yaml:     "apiVersion: expressops/v1beta1\nplugins:\n  - name: p\n    builtin: slack\n    config:\n      webhook_url:\n        secretRef: {provider: kubernetes, name: slack}\n",
+			expected: "plugin 'p': config.webhook_url: secretRef: the kubernetes provider requires the key of the Secret",
+		},
+		{
+			name:     "encrypted secret ref without a file",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        parameters:\n          password:\n            secretRef: {provider: encrypted, name: db}\n",
+			expected: "flow 'f', step 'a': parameters.password: the encrypted provider requires secrets.encrypted.path",
+		},
+		{
+			name:     "invalid secrets cache ttl",
+			yaml:     "apiVersion: expressops/v1beta1\nsecrets:\n  cacheTTL: forever\n",
+			expected: "secrets: invalid cacheTTL",
+		},
+		{
+			name:     "syslog address without network",
+			yaml:     "apiVersion: expressops/v1beta1\naudit:\n  syslog:\n    address: logs:514\n",
+			expected: "audit: syslog network and address must be set together",
+		},
+		{
+			name:     "flow concurrency without max",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    concurrency:\n      policy: queue\n    pipeline:\n      - pluginRef: a\n",
+			expected: "flow 'f': concurrency max must be at least 1",
+		},
+		{
+			name:     "unknown concurrency policy",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    concurrency:\n      max: 1\n      policy: drop\n    pipeline:\n      - pluginRef: a\n",
+			expected: "unknown concurrency policy 'drop'",
+		},
+		{
+			name:     "invalid rate limit period",
+			yaml:     "apiVersion: expressops/v1beta1\nserver:\n  auth:\n    rateLimit:\n      requests: 10\n      period: hourly\n",
+			expected: "server: auth: invalid rateLimit period",
+		},
+		{
+			name:     "plugin with two sources",
+			yaml:     "apiVersion: expressops/v1beta1\nplugins:\n  - name: p\n    builtin: sleep\n    process:\n      command: ./p\n",
+			expected: "set only one of path, builtin or process",
+		},
+		{
+			name:     "input referring to a later step",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        inputs:\n          message: '{{ steps.b.result }}'\n      - pluginRef: b\n",
+			expected: "step 'b' does not run before this step",
+		},
+		{
+			name:     "input referring to a parallel sibling",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n      - pluginRef: b\n        parallel: true\n        inputs:\n          message: '{{ steps.a.result }}'\n",
+			expected: "step 'a' does not run before this step",
+		},
+		{
+			name:     "output referring to steps",
+			yaml:     "apiVersion: expressops/v1beta1\nflows:\n  - name: f\n    pipeline:\n      - pluginRef: a\n        outputs:\n          id: '{{ steps.a.result }}'\n",
+			expected: "outputs may only refer to result and params",
+		},
+		{
+			name:     "process without command",
+			yaml:     "apiVersion: expressops/v1beta1\nplugins:\n  - name: p\n    process:\n      timeout: 1s\n",
+			expected: "process command is required",
+		},
+		{
+			name:     "invalid reload interval",
+			yaml:     "apiVersion: expressops/v1beta1\nserver:\n  reload:\n    watch: true\n    interval: often\n",
+			expected: "invalid reload interval",
+		},
+		{
+			name:     "ratio sampler without ratio",
+			yaml:     "apiVersion: expressops/v1beta1\ntracing:\n  exporter: stdout\n  sampler:\n    type: traceidratio\n",
+			expected: "sampler traceidratio requires a ratio",
+		},
+		{
+			name:     "sampler ratio out of range",
+			yaml:     "apiVersion: expressops/v1beta1\ntracing:\n  sampler:\n    type: parentbased_traceidratio\n    ratio: 1.5\n",
+			expected: "sampler ratio 1.5 is not between 0 and 1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := DecodeConfig([]byte(tc.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
+func TestSampleConfigIsStrictlyValid(t *testing.T) {
+	_, err := ReadConfig("../../docs/samples/config.yaml")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"expressops/api/v1beta1"
+	"expressops/internal/redact"
+)
+
+// configureRedaction sets up the masking of the secrets of cfg: the keys and
+// patterns of its redaction section, the API tokens, the headers sent to the
+// trace collector, and the step parameters and process environment named
+// like a secret. The config of each plugin is registered as it is loaded.
+func configureRedaction(cfg *v1beta1.Config) error {
+	redactor := redact.Default()
+	if err := redactor.AddKeys(cfg.Redaction.Keys...); err != nil {
+		return err
+	}
+	if err := redactor.AddPatterns(cfg.Redaction.Patterns...); err != nil {
+		return err
+	}
+
+	redactor.Add(cfg.Server.Auth.Tokens...)
+	for _, value := range cfg.Tracing.Headers {
+		redactor.Add(value)
+	}
+	for _, p := range cfg.Plugins {
+		if p.Process == nil {
+			continue
+		}
+		for key, value := range p.Process.Env {
+			if redactor.SecretKey(key) {
+				redactor.Add(value)
+			}
+		}
+	}
+	for _, flow := range cfg.Flows {
+		for _, step := range flow.Pipeline {
+			redactor.AddFrom(step.Parameters)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureRedaction(t *testing.T) {
+	cfg := &v1beta1.Config{
+		Server:    v1beta1.ServerConfig{Auth: v1beta1.AuthConfig{Tokens: []string{"api-token-123"}}},
+		Tracing:   v1beta1.TracingConfig{Headers: map[string]string{"x-honeycomb-team": "collector-key"}},
+		Redaction: v1beta1.RedactionConfig{Keys: []string{"^pin$"}, Patterns: []string{`ghp_[0-9A-Za-z]+`}},
+		Plugins: []v1beta1.Plugin{{
+			Name:    "proc",
+			Process: &v1beta1.ProcessConfig{Command: "./proc", Env: map[string]string{"DB_PASSWORD": "db-pass-456", "MODE": "production"}},
+		}},
+		Flows: []v1beta1.Flow{{
+			Name:     "f",
+			Pipeline: []v1beta1.Step{{PluginRef: "a", Parameters: map[string]interface{}{"pin": "9876", "user": "alice"}}},
+		}},
+	}
+	require.NoError(t, configureRedaction(cfg))
+
+	redactor := redact.Default()
+	for _, secret := range []string{"api-token-123", "collector-key", "db-pass-456", "9876", "ghp_abc123"} {
+		assert.Equal(t, redact.Mask, redactor.String(secret), secret)
+	}
+	for _, plain := range []string{"production", "alice"} {
+		assert.Equal(t, plain, redactor.String(plain))
+	}
+}
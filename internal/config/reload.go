@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/secrets"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultWatchInterval is how often WatchPlugins looks for changes when the
+// config sets no interval
+const DefaultWatchInterval = 2 * time.Second
+
+// ReloadPlugin reads the config file at path again and reloads the plugin
+// name from its entry. Steps already running keep the previous instance;
+// if the new one fails to load the previous one stays in place.
+func ReloadPlugin(ctx context.Context, path string, name string, logger *logrus.Logger) error {
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Plugins {
+		if cfg.Plugins[i].Name == name {
+			return reloadPlugin(ctx, &cfg.Plugins[i], logger)
+		}
+	}
+	return fmt.Errorf("plugin '%s' is not declared in '%s': %w", name, path, pluginManager.ErrNotLoaded)
+}
+
+// reloadPlugin is loadPlugin swapping a loaded plugin for a new instance.
+// The secrets of its config are read again rather than taken from the
+// cache, so that a reload picks up rotated values.
+func reloadPlugin(ctx context.Context, pluginCfg *v1beta1.Plugin, logger *logrus.Logger) error {
+	secrets.Default().Forget(pluginCfg.Config)
+	config, err := pluginConfig(ctx, pluginCfg)
+	if err != nil {
+		return fmt.Errorf("plugin '%s': %w", pluginCfg.Name, err)
+	}
+
+	if builtin := pluginCfg.BuiltinName(); builtin != "" {
+		logger.Debugf("Reloading builtin plugin: %s (Builtin: %s)", pluginCfg.Name, builtin)
+		return pluginManager.ReloadBuiltin(ctx, builtin, pluginCfg.Name, config, logger)
+	}
+
+	if pluginCfg.Process != nil {
+		logger.Debugf("Restarting plugin process: %s (Command: %s)", pluginCfg.Name, pluginCfg.Process.Command)
+		instance := newProcess(pluginCfg)
+		if err := pluginManager.Reload(ctx, instance, pluginCfg.Name, config, logger); err != nil {
+			_ = instance.Close()
+			return err
+		}
+		return nil
+	}
+
+	logger.Debugf("Reloading plugin code: %s (Path: %s)", pluginCfg.Name, pluginCfg.Path)
+	return pluginManager.ReloadPlugin(ctx, pluginCfg.Path, pluginCfg.Name, config, logger)
+}
+
+// WatchPlugins polls the config file at path and the files of every plugin
+// it declares until ctx is done. A plugin is reloaded when its entry in the
+// config changes or when one of its files does: the .so file, the command
+// of a process, a script given in its arguments or a file holding one of
+// its secrets. Plugins added to or removed from the config need a restart.
+func WatchPlugins(ctx context.Context, path string, interval time.Duration, logger *logrus.Logger) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w := &pluginWatcher{path: path, logger: logger}
+	if err := w.scan(ctx, false); err != nil {
+		logger.Errorf("Plugin watcher disabled: %v", err)
+		return
+	}
+	logger.Infof("Watching '%s' and its plugins for changes every %s", path, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.scan(ctx, true); err != nil {
+				logger.Errorf("Plugin watcher: %v", err)
+			}
+		}
+	}
+}
+
+// pluginWatcher remembers the plugin entries and file versions seen last
+type pluginWatcher struct {
+	path    string
+	logger  *logrus.Logger
+	config  fileVersion
+	entries map[string]v1beta1.Plugin
+	files   map[string]map[string]fileVersion
+}
+
+// fileVersion identifies the content of a file without reading it
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statVersion(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}
+}
+
+// scan records the current state and, when reload is set, reloads the
+// plugins that changed since the previous scan
+func (w *pluginWatcher) scan(ctx context.Context, reload bool) error {
+	entries := w.entries
+	if version := statVersion(w.path); version != w.config || entries == nil {
+		cfg, err := ReadConfig(w.path)
+		if err != nil {
+			return err
+		}
+		w.config = version
+		entries = make(map[string]v1beta1.Plugin, len(cfg.Plugins))
+		for _, p := range cfg.Plugins {
+			if p.Name != "" {
+				entries[p.Name] = p
+			}
+		}
+	}
+
+	files := make(map[string]map[string]fileVersion, len(entries))
+	for name, p := range entries {
+		files[name] = make(map[string]fileVersion)
+		for _, file := range pluginFiles(p) {
+			files[name][file] = statVersion(file)
+		}
+	}
+
+	if reload {
+		for name := range w.entries {
+			if _, ok := entries[name]; !ok {
+				w.logger.Warnf("Plugin '%s' was removed from '%s', restart the server to unload it", name, w.path)
+			}
+		}
+		for name, p := range entries {
+			previous, ok := w.entries[name]
+			switch {
+			case !ok:
+				w.logger.Warnf("Plugin '%s' was added to '%s', restart the server to load it", name, w.path)
+			case !reflect.DeepEqual(previous, p) || !reflect.DeepEqual(w.files[name], files[name]):
+				w.logger.Infof("Plugin '%s' changed, reloading it", name)
+				p := p
+				err := reloadPlugin(ctx, &p, w.logger)
+				auditPlugin(audit.ActionPluginReload, audit.ActorWatcher, name, err)
+				if err != nil {
+					w.logger.Errorf("Error reloading plugin '%s', keeping the running version: %v", name, err)
+				}
+			}
+		}
+	}
+
+	w.entries = entries
+	w.files = files
+	return nil
+}
+
+// pluginFiles returns the files whose change means a new version of a
+// plugin or of its secrets. The code of builtin plugins is part of the
+// binary, so only their secrets count.
+func pluginFiles(p v1beta1.Plugin) []string {
+	// A mounted secret is rotated by replacing its file
+	files := secrets.Default().Files(p.Config)
+	if p.BuiltinName() != "" {
+		return files
+	}
+	proc := p.Process
+	if proc == nil {
+		return append(files, p.Path)
+	}
+
+	// A command with a slash is relative to Dir, others are looked up in PATH
+	if strings.ContainsRune(proc.Command, filepath.Separator) {
+		files = append(files, inDir(proc.Dir, proc.Command))
+	} else if path, err := exec.LookPath(proc.Command); err == nil {
+		files = append(files, path)
+	}
+	for _, arg := range proc.Args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		path := inDir(proc.Dir, arg)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+func inDir(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
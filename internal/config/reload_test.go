@@ -0,0 +1,125 @@
+package config
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"expressops/api/v1beta1"
+	pluginManager "expressops/internal/plugin/loader"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// configuredPlugin remembers the config it was initialized with
+type configuredPlugin struct {
+	config map[string]interface{}
+}
+
+func (p *configuredPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	p.config = config
+	return nil
+}
+
+func (p *configuredPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	return p.config["greeting"], nil
+}
+
+func (p *configuredPlugin) FormatResult(result interface{}) (string, error) {
+	return "", nil
+}
+
+func init() {
+	pluginManager.RegisterBuiltin("reload-test", func() pluginManager.Plugin { return &configuredPlugin{} })
+}
+
+func writeReloadConfig(t *testing.T, path, greeting string) {
+	t.Helper()
+	data := "apiVersion: expressops/v1beta1\nplugins:\n  - name: greeter\n    builtin: reload-test\n    config:\n      greeting: " + greeting + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+}
+
+func greeting(t *testing.T) interface{} {
+	t.Helper()
+	p, err := pluginManager.GetPlugin("greeter")
+	require.NoError(t, err)
+	return p.(*configuredPlugin).config["greeting"]
+}
+
+func TestReloadPlugin(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	writeReloadConfig(t, path, "hello")
+	_, err := LoadConfig(ctx, path, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", greeting(t))
+
+	writeReloadConfig(t, path, "hi")
+	require.NoError(t, ReloadPlugin(ctx, path, "greeter", logger))
+	assert.Equal(t, "hi", greeting(t))
+
+	err = ReloadPlugin(ctx, path, "unknown", logger)
+	assert.ErrorIs(t, err, pluginManager.ErrNotLoaded)
+
+	// The watcher reloads the plugins whose entry changed
+	w := &pluginWatcher{path: path, logger: logger}
+	require.NoError(t, w.scan(ctx, false))
+	writeReloadConfig(t, path, "howdy-doo")
+	require.NoError(t, w.scan(ctx, true))
+	assert.Equal(t, "howdy-doo", greeting(t))
+}
+
+func TestPluginFiles(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.py")
+	require.NoError(t, os.WriteFile(script, []byte("print()"), 0o644))
+
+	assert.Empty(t, pluginFiles(v1beta1.Plugin{Name: "b", Builtin: "sleep"}))
+	assert.Equal(t, []string{"plugins/slack.so"}, pluginFiles(v1beta1.Plugin{Name: "s", Path: "plugins/slack.so"}))
+	assert.Equal(t, []string{filepath.Join(dir, "bin/plugin"), script}, pluginFiles(v1beta1.Plugin{
+		Name:    "p",
+		Process: &v1beta1.ProcessConfig{Command: "./bin/plugin", Args: []string{"--verbose", "plugin.py", "missing.py"}, Dir: dir},
+	}))
+}
+
+func TestReloadRotatesSecrets(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "greeter", "greeting")
+	require.NoError(t, os.MkdirAll(filepath.Dir(secretFile), 0o755))
+	require.NoError(t, os.WriteFile(secretFile, []byte("hello\n"), 0o600))
+
+	path := filepath.Join(dir, "config.yaml")
+	data := "apiVersion: expressops/v1beta1\nsecrets:\n  file:\n    dir: " + dir + "\n" +
+		"plugins:\n  - name: greeter\n    builtin: reload-test\n    config:\n" +
+		"      greeting:\n        secretRef: {provider: file, name: greeter, key: greeting}\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+
+	cfg, err := LoadConfig(ctx, path, logger)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", greeting(t))
+	assert.Equal(t, []string{secretFile}, pluginFiles(cfg.Plugins[0]))
+
+	// The watcher reloads the plugin when its secret is rotated
+	w := &pluginWatcher{path: path, logger: logger}
+	require.NoError(t, w.scan(ctx, false))
+	require.NoError(t, os.WriteFile(secretFile, []byte("good morning\n"), 0o600))
+	require.NoError(t, w.scan(ctx, true))
+	assert.Equal(t, "good morning", greeting(t))
+
+	// A secret that cannot be read keeps the running plugin
+	require.NoError(t, os.Remove(secretFile))
+	err = ReloadPlugin(ctx, path, "greeter", logger)
+	assert.ErrorContains(t, err, "plugin 'greeter': greeting: secret file:greeter/greeting: no file")
+	assert.Equal(t, "good morning", greeting(t))
+}
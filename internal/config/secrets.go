@@ -0,0 +1,43 @@
+package config
+
+import (
+	"context"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/secrets"
+)
+
+// configureSecrets sets up the providers resolving the secretRefs of cfg.
+// Nothing is read until a plugin or a step needs a secret.
+func configureSecrets(cfg *v1beta1.Config) {
+	resolver := secrets.Default()
+	ttl, _ := cfg.Secrets.CacheTTLDuration() // checked by Validate
+	if ttl == 0 {
+		ttl = secrets.DefaultCacheTTL
+	}
+	resolver.SetCacheTTL(ttl)
+
+	dir := cfg.Secrets.File.Dir
+	if dir == "" {
+		dir = secrets.DefaultDir
+	}
+	resolver.SetProvider(secrets.ProviderFile, secrets.NewFileProvider(dir))
+
+	k8s := cfg.Secrets.Kubernetes
+	resolver.SetProvider(secrets.ProviderKubernetes, secrets.NewKubernetesProvider(secrets.KubernetesOptions{
+		Namespace: k8s.Namespace,
+		APIServer: k8s.APIServer,
+		TokenFile: k8s.TokenFile,
+		CAFile:    k8s.CAFile,
+	}))
+
+	if enc := cfg.Secrets.Encrypted; enc.Path != "" {
+		resolver.SetProvider(secrets.ProviderEncrypted, secrets.NewEncryptedFileProvider(enc.Path, enc.KeyFile))
+	}
+}
+
+// pluginConfig returns the config of a plugin entry with its secretRefs
+// resolved
+func pluginConfig(ctx context.Context, pluginCfg *v1beta1.Plugin) (map[string]interface{}, error) {
+	return secrets.Default().ResolveMap(ctx, pluginCfg.Config)
+}
@@ -0,0 +1,170 @@
+// Package expr evaluates the `{{ path }}` expressions of step inputs and
+// outputs, e.g. "{{ steps.formatter.result }}" or "user {{ params.username }}".
+// A path is a dotted list of map keys and list indexes. A template made of a
+// single expression evaluates to the referenced value itself; any other
+// template evaluates to a string.
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Template is a parsed input or output template
+type Template struct {
+	raw   string
+	parts []part
+}
+
+// part is either a literal string or a path to look up
+type part struct {
+	literal string
+	path    []string
+}
+
+// Parse parses a template
+func Parse(s string) (*Template, error) {
+	t := &Template{raw: s}
+	rest := s
+	for rest != "" {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			t.parts = append(t.parts, part{literal: rest})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, part{literal: rest[:start]})
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{{' in %q", s)
+		}
+		inner := strings.TrimSpace(rest[start+2 : start+end])
+		path, err := parsePath(inner)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression %q: %w", inner, err)
+		}
+		t.parts = append(t.parts, part{path: path})
+		rest = rest[start+end+2:]
+	}
+	return t, nil
+}
+
+func parsePath(s string) ([]string, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty expression")
+	}
+	path := strings.Split(s, ".")
+	for _, segment := range path {
+		if segment == "" || strings.ContainsAny(segment, " \t{}") {
+			return nil, fmt.Errorf("expected a dotted path such as steps.<id>.result")
+		}
+	}
+	return path, nil
+}
+
+// String returns the template as written
+func (t *Template) String() string {
+	return t.raw
+}
+
+// References returns the paths the template refers to
+func (t *Template) References() [][]string {
+	var refs [][]string
+	for _, p := range t.parts {
+		if p.path != nil {
+			refs = append(refs, p.path)
+		}
+	}
+	return refs
+}
+
+// Eval evaluates the template against data. Referencing a missing value is
+// an error.
+func (t *Template) Eval(data map[string]interface{}) (interface{}, error) {
+	if len(t.parts) == 1 && t.parts[0].path != nil {
+		return Lookup(data, t.parts[0].path)
+	}
+
+	var sb strings.Builder
+	for _, p := range t.parts {
+		if p.path == nil {
+			sb.WriteString(p.literal)
+			continue
+		}
+		value, err := Lookup(data, p.path)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(toString(value))
+	}
+	return sb.String(), nil
+}
+
+// Lookup follows path in data. Values that are neither maps nor lists, such
+// as structs returned by plugins, are looked into through their JSON form.
+func Lookup(data interface{}, path []string) (interface{}, error) {
+	current := data
+	for i, segment := range path {
+		node := current
+		switch node.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			node = generic(node)
+		}
+
+		switch n := node.(type) {
+		case map[string]interface{}:
+			value, ok := n[segment]
+			if !ok {
+				return nil, unresolved(path, i)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(n) {
+				return nil, unresolved(path, i)
+			}
+			current = n[index]
+		default:
+			return nil, unresolved(path, i)
+		}
+	}
+	return current, nil
+}
+
+func unresolved(path []string, i int) error {
+	return fmt.Errorf("unresolved reference '%s': '%s' not found", strings.Join(path, "."), strings.Join(path[:i+1], "."))
+}
+
+// generic returns the JSON form of v, decoded into maps and lists
+func generic(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// toString renders a value interpolated in a string: strings as is, other
+// scalars with fmt and maps and lists as JSON
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return value.String()
+	}
+	if data, err := json.Marshal(v); err == nil && len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,62 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type podStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func TestEval(t *testing.T) {
+	data := map[string]interface{}{
+		"params": map[string]interface{}{"user": "ada", "count": 3},
+		"steps": map[string]interface{}{
+			"check": map[string]interface{}{
+				"result": []podStatus{{Name: "api", Status: "Running"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		template string
+		expected interface{}
+	}{
+		{"plain text", "plain text"},
+		{"{{ params.user }}", "ada"},
+		{"{{params.count}}", 3},
+		{"count={{ params.count }}", "count=3"},
+		{"{{ steps.check.result.0.status }}", "Running"},
+		{"{{ params }}", map[string]interface{}{"user": "ada", "count": 3}},
+		{"pods: {{ steps.check.result }}", `pods: [{"name":"api","status":"Running"}]`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.template, func(t *testing.T) {
+			tmpl, err := Parse(tc.template)
+			require.NoError(t, err)
+			value, err := tmpl.Eval(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+
+	tmpl, err := Parse("{{ steps.check.result.1.name }}")
+	require.NoError(t, err)
+	_, err = tmpl.Eval(data)
+	assert.EqualError(t, err, "unresolved reference 'steps.check.result.1.name': 'steps.check.result.1' not found")
+}
+
+func TestParse(t *testing.T) {
+	tmpl, err := Parse("{{ steps.a.result }} and {{ params.b }}")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"steps", "a", "result"}, {"params", "b"}}, tmpl.References())
+
+	for _, invalid := range []string{"{{ steps.a", "{{ }}", "{{ steps..a }}", "{{ len steps }}"} {
+		_, err := Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
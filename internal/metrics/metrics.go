@@ -1,253 +1,226 @@
-// Package metrics provides Prometheus metrics for monitoring the application
+// Package metrics provides Prometheus metrics for monitoring the application.
+//
+// All metrics are registered on a single registry, served by MetricsHandler,
+// and named expressops_<subject>_<unit> with snake_case labels. The engine
+// records flows and steps through StartFlow and StartStep, and HTTP requests
+// through Handler. Plugins register metrics of their own through the loader;
+// the counters of the bundled plugins are in plugins.go.
 package metrics
 
 import (
-	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	// Counter for the total number of executed flows, labeled 'flowName'
-	flowsExecutedTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_flows_executed_total", // Metric name
-			Help: "Total number of flows executed.", // Descriptive help
-		},
-		[]string{"flowName", "status"}, // Labels that the metric will have
-	)
-
-	// Histogram for flow execution duration
-	flowExecutionDurationSeconds = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "expressops_flow_execution_duration_seconds",
-			Help:    "Duration of flow executions in seconds.",
-			Buckets: prometheus.DefBuckets, // Puedes usar prometheus.ExponentialBuckets(0.001, 2, 15) para más granularidad
-		},
-		[]string{"flowName", "status"},
-	)
-
-	// Counter for total plugin executions, with 'pluginRef' and 'status' tags (success/error)
-	pluginsExecutedTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_plugins_executed_total",
-			Help: "Total number of plugin executions attempted.",
-		},
-		[]string{"pluginRef", "status"},
-	)
-
-	// Histogram for plugin execution duration
-	pluginExecutionDurationSeconds = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "expressops_plugin_execution_duration_seconds",
-			Help:    "Duration of plugin executions in seconds.",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"pluginRef", "status"},
-	)
-
-	// Counter for Slack notifications
-	slackNotificationsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_slack_notifications_total",
-			Help: "Total number of Slack notifications sent.",
-		},
-		[]string{"status", "channel"}, // 'status' could be "success" or "error"
-	)
-
-	// Counter for individuals health checks
-	healthChecksPerformedTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_health_checks_performed_total",
-			Help: "Total number of individual health checks performed.",
-		},
-		[]string{"check_type", "status"}, // 'check_type' (cpu, mem, disk), 'status' (ok, fail)
-	)
-
-	// Gauge for resource usage (CPU, Memory, Disk most critical)
-	// GaugeVec to be able to have different types of resources with labels
-	resourceUsageGauge = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "expressops_resource_usage_percent",
-			Help: "Current resource usage percentage.",
-		},
-		[]string{"resource_type", "mount_point"}, // mount_point will be "" for cpu/mem
-	)
-
-	// Counter for Kubernetes probes (liveness/readiness)
-	kubernetesProbesTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_kubernetes_probes_total",
-			Help: "Total number of Kubernetes liveness/readiness probes received.",
-		},
-		[]string{"probe_type", "path"}, // "liveness/readiness", "/healthz"
-	)
-
-	// Counter for user creation operations
-	userCreationTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_user_creation_total",
-			Help: "Total number of user creation operations.",
-		},
-		[]string{"username", "status"}, // status: success, error, simulation
-	)
-
-	// Counter for permission changes
-	permissionsChangesTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_permissions_changes_total",
-			Help: "Total number of permission changes.",
-		},
-		[]string{"path", "username", "status"}, // status: success, error, simulation
-	)
-
-	// Counter for message formatting operations
-	formattingOperationsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_formatting_operations_total",
-			Help: "Total number of message formatting operations.",
-		},
-		[]string{"format_type", "status"}, // format_type: health_alert, etc.
-	)
-
-	// Histogram for sleep plugin duration
-	sleepDurationSeconds = promauto.NewHistogram(
-		prometheus.HistogramOpts{
-			Name:    "expressops_sleep_duration_seconds",
-			Help:    "Duration of sleep operations in seconds.",
-			Buckets: []float64{1, 2, 5, 10, 30, 60}, // buckets for different sleep durations
-		},
-	)
-
-	// Counter for test print operations
-	testPrintTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_test_print_total",
-			Help: "Total number of test print operations.",
-		},
-		[]string{"status"}, // status: success, error
-	)
-	// Histogram for HTTP request duration
-	httpRequestDurationSeconds = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "expressops_http_request_duration_seconds",
-			Help:    "Duration of HTTP requests.",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"path", "method", "code"},
-	)
-
-	// Counter for total HTTP requests
-	httpRequestsTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "expressops_http_requests_total",
-			Help: "Total number of HTTP requests.",
-		},
-		[]string{"path", "method", "code"},
-	)
-
-	// Gauge for active flow handlers
-	activeFlowHandlersGauge = promauto.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "expressops_active_flow_handlers_gauge",
-			Help: "Number of currently active flow handlers.",
-		},
-	)
+// Status label values of flows and steps
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
 )
 
-// --- PUBLIC FUNCTIONS TO ACCESS METRICS FROM OTHER PACKAGES ---
-
-// IncFlowExecution records the execution attempt of a flow.
-func IncFlowExecution(flowName, status string) {
-	flowsExecutedTotal.WithLabelValues(flowName, status).Inc()
-}
-
-// IncFlowExecuted records the execution of a flow.
-func IncFlowExecuted(flowName, status string) {
-	flowsExecutedTotal.WithLabelValues(flowName, status).Inc()
-}
+// durationBuckets covers steps of a few milliseconds up to flows of minutes
+var durationBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30, 60, 120}
 
-// ObserveFlowDuration records the duration of a flow execution with its status
-func ObserveFlowDuration(flowName, status string, durationSeconds float64) {
-	flowExecutionDurationSeconds.WithLabelValues(flowName, status).Observe(durationSeconds)
-}
+// registry holds every ExpressOps metric along with the Go and process collectors
+var registry = prometheus.NewRegistry()
 
-// IncPluginExecuted records the execution of a plugin.
-func IncPluginExecuted(pluginRef, status string) {
-	pluginsExecutedTotal.WithLabelValues(pluginRef, status).Inc()
-}
+var (
+	flowsExecutedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_flows_executed_total",
+		Help: "Total number of flows executed.",
+	}, []string{"flow", "status"})
+
+	flowDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "expressops_flow_duration_seconds",
+		Help:    "Duration of flow executions in seconds.",
+		Buckets: durationBuckets,
+	}, []string{"flow", "status"})
+
+	flowsInProgress = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "expressops_flows_in_progress",
+		Help: "Number of flows currently executing.",
+	}, []string{"flow"})
+
+	flowsRejectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_flows_rejected_total",
+		Help: "Total number of flow runs refused by a rate or concurrency limit, by reason.",
+	}, []string{"flow", "reason"})
+
+	flowsQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "expressops_flows_queued",
+		Help: "Number of flow runs waiting for a run of the same flow to finish.",
+	}, []string{"flow"})
+
+	stepsExecutedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_steps_executed_total",
+		Help: "Total number of flow steps executed.",
+	}, []string{"flow", "step", "plugin", "status"})
+
+	stepDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "expressops_step_duration_seconds",
+		Help:    "Duration of flow steps in seconds, once their dependencies are done.",
+		Buckets: durationBuckets,
+	}, []string{"flow", "step", "plugin", "status"})
+
+	stepsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "expressops_steps_in_progress",
+		Help: "Number of flow steps currently executing.",
+	})
+
+	pluginErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_plugin_errors_total",
+		Help: "Total number of failed steps, by plugin and reason.",
+	}, []string{"plugin", "reason"})
+
+	pluginMetricDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_plugin_metric_dropped_total",
+		Help: "Total number of observations of plugin metrics dropped for exceeding their limits.",
+	}, []string{"plugin", "metric"})
+
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_http_requests_total",
+		Help: "Total number of HTTP requests.",
+	}, []string{"handler", "method", "code"})
+
+	httpRequestDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "expressops_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler", "method", "code"})
+
+	healthProbesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_health_probes_total",
+		Help: "Total number of requests to /healthz, by source.",
+	}, []string{"source"})
+
+	cpuUsagePercent = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "expressops_cpu_usage_percent",
+		Help: "Current CPU usage of the host in percent.",
+	})
+
+	memoryUsageBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "expressops_memory_usage_bytes",
+		Help: "Current memory usage of the host in bytes.",
+	})
+
+	storageUsageBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "expressops_storage_usage_bytes",
+		Help: "Current disk usage of the host in bytes, summed over partitions.",
+	})
+)
 
-// ObservePluginDuration records the duration of a plugin execution
-func ObservePluginDuration(pluginRef, status string, durationSeconds float64) {
-	pluginExecutionDurationSeconds.WithLabelValues(pluginRef, status).Observe(durationSeconds)
+func init() {
+	registry.MustRegister(
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewGoCollector(),
+		flowsExecutedTotal, flowDurationSeconds, flowsInProgress, flowsRejectedTotal, flowsQueued,
+		stepsExecutedTotal, stepDurationSeconds, stepsInProgress, pluginErrorsTotal, pluginMetricDroppedTotal,
+		httpRequestsTotal, httpRequestDurationSeconds, healthProbesTotal,
+		cpuUsagePercent, memoryUsageBytes, storageUsageBytes,
+	)
 }
 
-// IncSlackNotification records a Slack notification.
-func IncSlackNotification(status, channel string) {
-	slackNotificationsTotal.WithLabelValues(status, channel).Inc()
+// Registry returns the registry holding all ExpressOps metrics
+func Registry() *prometheus.Registry {
+	return registry
 }
 
-// IncHealthCheckPerformed registers an individual health check.
-func IncHealthCheckPerformed(checkType, status string) {
-	healthChecksPerformedTotal.WithLabelValues(checkType, status).Inc()
+// MetricsHandler returns an HTTP handler for Prometheus metrics
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
 }
 
-// SetResourceUsage records the percentage of usage of a resource.
-func SetResourceUsage(resourceType, mountPoint string, usagePercent float64) {
-	if mountPoint == "" && (resourceType == "cpu" || resourceType == "memory") {
-		resourceUsageGauge.WithLabelValues(resourceType, "").Set(usagePercent)
-	} else if resourceType == "disk" && mountPoint != "" {
-		resourceUsageGauge.WithLabelValues(resourceType, mountPoint).Set(usagePercent)
+// StartFlow records the start of a flow execution. The returned function
+// records its end with the given status.
+func StartFlow(flow string) func(status string) {
+	start := time.Now()
+	inProgress := flowsInProgress.WithLabelValues(flow)
+	inProgress.Inc()
+	return func(status string) {
+		inProgress.Dec()
+		flowsExecutedTotal.WithLabelValues(flow, status).Inc()
+		flowDurationSeconds.WithLabelValues(flow, status).Observe(time.Since(start).Seconds())
 	}
 }
 
-// IncKubernetesProbe is a convenience function for liveness probes.
-func IncKubernetesProbe(probeType, path string) {
-	kubernetesProbesTotal.WithLabelValues(probeType, path).Inc()
-}
-
-// IncUserCreation records a user creation operation.
-func IncUserCreation(username, status string) {
-	userCreationTotal.WithLabelValues(username, status).Inc()
-}
+// Reasons for refusing a flow run
+const (
+	RejectedCallerRateLimit = "caller_rate_limit"
+	RejectedRateLimit       = "rate_limit"
+	RejectedConcurrency     = "concurrency"
+	RejectedQueueFull       = "queue_full"
+	RejectedQueueTimeout    = "queue_timeout"
+)
 
-// IncPermissionsChange records a permission change operation.
-func IncPermissionsChange(path, username, status string) {
-	permissionsChangesTotal.WithLabelValues(path, username, status).Inc()
+// RecordFlowRejected records a run of flow refused for reason, one of the
+// Rejected constants
+func RecordFlowRejected(flow, reason string) {
+	flowsRejectedTotal.WithLabelValues(flow, reason).Inc()
+}
+
+// StartQueued records a run of flow waiting for a slot. The returned
+// function records the end of the wait.
+func StartQueued(flow string) func() {
+	queued := flowsQueued.WithLabelValues(flow)
+	queued.Inc()
+	return queued.Dec
+}
+
+// StartStep records the start of a step of a flow. The returned function
+// records its end with the given status.
+func StartStep(flow, step, plugin string) func(status string) {
+	start := time.Now()
+	stepsInProgress.Inc()
+	return func(status string) {
+		stepsInProgress.Dec()
+		stepsExecutedTotal.WithLabelValues(flow, step, plugin, status).Inc()
+		stepDurationSeconds.WithLabelValues(flow, step, plugin, status).Observe(time.Since(start).Seconds())
+	}
 }
 
-// IncFormattingOperation records a formatting operation.
-func IncFormattingOperation(formatType, status string) {
-	formattingOperationsTotal.WithLabelValues(formatType, status).Inc()
+// RecordPluginError records a step failed by plugin for the given reason,
+// such as plugin_not_found or execution_error
+func RecordPluginError(plugin, reason string) {
+	pluginErrorsTotal.WithLabelValues(plugin, reason).Inc()
 }
 
-// ObserveSleepDuration records the duration of a sleep operation.
-func ObserveSleepDuration(seconds float64) {
-	sleepDurationSeconds.Observe(seconds)
+// Handler wraps next to count its requests and observe their duration.
+// Requests are labeled with handler rather than their path, which keeps the
+// number of series bounded.
+func Handler(handler string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": handler}
+	return promhttp.InstrumentHandlerDuration(httpRequestDurationSeconds.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(httpRequestsTotal.MustCurryWith(labels), next))
 }
 
-// IncTestPrint records a test print operation.
-func IncTestPrint(status string) {
-	testPrintTotal.WithLabelValues(status).Inc()
+// IncHealthProbe records a request to /healthz from source, such as
+// kubernetes_probe
+func IncHealthProbe(source string) {
+	healthProbesTotal.WithLabelValues(source).Inc()
 }
 
-// ObserveHTTPRequestDuration records the duration of an HTTP request
-func ObserveHTTPRequestDuration(path, method string, code int, durationSeconds float64) {
-	httpRequestDurationSeconds.WithLabelValues(path, method, fmt.Sprintf("%d", code)).Observe(durationSeconds)
+// SetCPUUsage sets the CPU usage of the host in percent
+func SetCPUUsage(percent float64) {
+	cpuUsagePercent.Set(percent)
 }
 
-// IncHTTPRequestsTotal increments the counter for HTTP requests
-func IncHTTPRequestsTotal(path, method string, code int) {
-	httpRequestsTotal.WithLabelValues(path, method, fmt.Sprintf("%d", code)).Inc()
+// SetMemoryUsage sets the memory usage of the host in bytes
+func SetMemoryUsage(bytes float64) {
+	memoryUsageBytes.Set(bytes)
 }
 
-// IncActiveFlowHandlers increments the gauge for active flow handlers.
-func IncActiveFlowHandlers() {
-	activeFlowHandlersGauge.Inc()
+// SetStorageUsage sets the disk usage of the host in bytes
+func SetStorageUsage(bytes float64) {
+	storageUsageBytes.Set(bytes)
 }
 
-// DecActiveFlowHandlers decrements the gauge for active flow handlers.
-func DecActiveFlowHandlers() {
-	activeFlowHandlersGauge.Dec()
+// RecordPluginMetricDropped records an observation of a plugin metric that
+// was dropped, e.g. because it would have exceeded the series limit
+func RecordPluginMetricDropped(plugin, metric string) {
+	pluginMetricDroppedTotal.WithLabelValues(plugin, metric).Inc()
 }
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartFlow(t *testing.T) {
+	done := StartFlow("metrics-test-flow")
+	assert.Equal(t, 1.0, testutil.ToFloat64(flowsInProgress.WithLabelValues("metrics-test-flow")))
+
+	done(StatusFailed)
+	assert.Equal(t, 0.0, testutil.ToFloat64(flowsInProgress.WithLabelValues("metrics-test-flow")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(flowsExecutedTotal.WithLabelValues("metrics-test-flow", StatusFailed)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(flowsExecutedTotal.WithLabelValues("metrics-test-flow", StatusSucceeded)))
+}
+
+func TestStartStep(t *testing.T) {
+	before := testutil.ToFloat64(stepsInProgress)
+	done := StartStep("metrics-test-flow", "notify", "slack-plugin")
+	assert.Equal(t, before+1, testutil.ToFloat64(stepsInProgress))
+
+	done(StatusSkipped)
+	assert.Equal(t, before, testutil.ToFloat64(stepsInProgress))
+	assert.Equal(t, 1.0, testutil.ToFloat64(stepsExecutedTotal.WithLabelValues("metrics-test-flow", "notify", "slack-plugin", StatusSkipped)))
+}
+
+func TestFlowLimits(t *testing.T) {
+	done := StartQueued("metrics-test-flow")
+	assert.Equal(t, 1.0, testutil.ToFloat64(flowsQueued.WithLabelValues("metrics-test-flow")))
+	done()
+	assert.Equal(t, 0.0, testutil.ToFloat64(flowsQueued.WithLabelValues("metrics-test-flow")))
+
+	RecordFlowRejected("metrics-test-flow", RejectedQueueFull)
+	assert.Equal(t, 1.0, testutil.ToFloat64(flowsRejectedTotal.WithLabelValues("metrics-test-flow", RejectedQueueFull)))
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler("/metrics-test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics-test?flowName=a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics-test?flowName=b", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/metrics-test", "get", "404")))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	IncSlackNotification("success", "#metrics-test")
+	RecordPluginError("metrics-test-plugin", "execution_error")
+
+	// Every metric is registered once, with no conflicting descriptors
+	_, err := Registry().Gather()
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	for _, want := range []string{
+		"go_goroutines",
+		"process_start_time_seconds",
+		"expressops_steps_in_progress",
+		`expressops_plugin_errors_total{plugin="metrics-test-plugin",reason="execution_error"} 1`,
+		`expressops_slack_notifications_total{channel="#metrics-test",status="success"} 1`,
+	} {
+		assert.True(t, strings.Contains(string(body), want), "missing %s", want)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Counters of the bundled plugins. They predate the step metrics of the
+// engine and are kept, on the same registry, so existing dashboards and
+// plugins built against these functions keep working.
+var (
+	slackNotificationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_slack_notifications_total",
+		Help: "Total number of Slack notifications sent.",
+	}, []string{"status", "channel"})
+
+	healthChecksPerformedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_health_checks_performed_total",
+		Help: "Total number of individual health checks performed.",
+	}, []string{"check_type", "status"})
+
+	resourceUsagePercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "expressops_resource_usage_percent",
+		Help: "Current resource usage percentage.",
+	}, []string{"resource_type", "mount_point"})
+
+	userCreationTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_user_creation_total",
+		Help: "Total number of user creation operations.",
+	}, []string{"username", "status"})
+
+	permissionsChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_permissions_changes_total",
+		Help: "Total number of permission changes.",
+	}, []string{"path", "username", "status"})
+
+	formattingOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_formatting_operations_total",
+		Help: "Total number of message formatting operations.",
+	}, []string{"format_type", "status"})
+
+	sleepDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "expressops_sleep_duration_seconds",
+		Help:    "Duration of sleep operations in seconds.",
+		Buckets: []float64{1, 2, 5, 10, 30, 60},
+	})
+
+	testPrintTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "expressops_test_print_total",
+		Help: "Total number of test print operations.",
+	}, []string{"status"})
+)
+
+func init() {
+	registry.MustRegister(
+		slackNotificationsTotal, healthChecksPerformedTotal, resourceUsagePercent,
+		userCreationTotal, permissionsChangesTotal, formattingOperationsTotal,
+		sleepDurationSeconds, testPrintTotal,
+	)
+}
+
+// IncSlackNotification records a Slack notification.
+func IncSlackNotification(status, channel string) {
+	slackNotificationsTotal.WithLabelValues(status, channel).Inc()
+}
+
+// IncHealthCheckPerformed registers an individual health check.
+func IncHealthCheckPerformed(checkType, status string) {
+	healthChecksPerformedTotal.WithLabelValues(checkType, status).Inc()
+}
+
+// SetResourceUsage records the percentage of usage of a resource.
+func SetResourceUsage(resourceType, mountPoint string, usagePercent float64) {
+	if mountPoint == "" && (resourceType == "cpu" || resourceType == "memory") {
+		resourceUsagePercent.WithLabelValues(resourceType, "").Set(usagePercent)
+	} else if resourceType == "disk" && mountPoint != "" {
+		resourceUsagePercent.WithLabelValues(resourceType, mountPoint).Set(usagePercent)
+	}
+}
+
+// IncUserCreation records a user creation operation.
+func IncUserCreation(username, status string) {
+	userCreationTotal.WithLabelValues(username, status).Inc()
+}
+
+// IncPermissionsChange records a permission change operation.
+func IncPermissionsChange(path, username, status string) {
+	permissionsChangesTotal.WithLabelValues(path, username, status).Inc()
+}
+
+// IncFormattingOperation records a formatting operation.
+func IncFormattingOperation(formatType, status string) {
+	formattingOperationsTotal.WithLabelValues(formatType, status).Inc()
+}
+
+// ObserveSleepDuration records the duration of a sleep operation.
+func ObserveSleepDuration(seconds float64) {
+	sleepDurationSeconds.Observe(seconds)
+}
+
+// IncTestPrint records a test print operation.
+func IncTestPrint(status string) {
+	testPrintTotal.WithLabelValues(status).Inc()
+}
@@ -0,0 +1,72 @@
+package pluginconf
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Factory creates a new, uninitialized instance of a builtin plugin
+type Factory func() Plugin
+
+var (
+	builtins   = make(map[string]Factory)
+	builtinsMu sync.RWMutex
+)
+
+// RegisterBuiltin makes a plugin compiled into the binary available under
+// name, to be selected in the config with `builtin: <name>`. It is meant to
+// be called from the init function of the plugin package and panics if the
+// name is registered twice.
+func RegisterBuiltin(name string, factory Factory) {
+	builtinsMu.Lock()
+	defer builtinsMu.Unlock()
+
+	if factory == nil {
+		panic("pluginconf: RegisterBuiltin factory is nil for " + name)
+	}
+	if _, dup := builtins[name]; dup {
+		panic("pluginconf: RegisterBuiltin called twice for " + name)
+	}
+	builtins[name] = factory
+}
+
+// Builtins returns the sorted names of the registered builtin plugins
+func Builtins() []string {
+	builtinsMu.RLock()
+	defer builtinsMu.RUnlock()
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBuiltin returns a new instance of a builtin plugin without initializing
+// or registering it, e.g. to read its config schema
+func NewBuiltin(builtin string) (Plugin, error) {
+	builtinsMu.RLock()
+	factory, ok := builtins[builtin]
+	builtinsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown builtin plugin '%s' (available: %v)", builtin, Builtins())
+	}
+	return factory(), nil
+}
+
+// LoadBuiltin creates an instance of a builtin plugin, initializes it with
+// config and registers it under name
+func LoadBuiltin(ctx context.Context, builtin string, name string, config map[string]interface{}, logger *logrus.Logger) error {
+	pluginInstance, err := NewBuiltin(builtin)
+	if err != nil {
+		return err
+	}
+
+	return LoadInstance(ctx, pluginInstance, name, config, logger)
+}
@@ -0,0 +1,60 @@
+package pluginconf
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinRegistry(t *testing.T) {
+	created := 0
+	RegisterBuiltin("test-builtin", func() Plugin {
+		created++
+		return &TestPlugin{}
+	})
+	defer func() {
+		builtinsMu.Lock()
+		delete(builtins, "test-builtin")
+		builtinsMu.Unlock()
+	}()
+
+	assert.Contains(t, Builtins(), "test-builtin")
+	assert.Panics(t, func() {
+		RegisterBuiltin("test-builtin", func() Plugin { return &TestPlugin{} })
+	})
+
+	for i := 0; i < 2; i++ {
+		p, err := NewBuiltin("test-builtin")
+		require.NoError(t, err)
+		assert.IsType(t, &TestPlugin{}, p)
+	}
+	assert.Equal(t, 2, created, "each call must create a new instance")
+
+	_, err := NewBuiltin("missing")
+	assert.ErrorContains(t, err, "unknown builtin plugin 'missing'")
+}
+
+func TestLoadBuiltin(t *testing.T) {
+	RegisterBuiltin("test-builtin-load", func() Plugin { return &TestPlugin{} })
+	defer func() {
+		builtinsMu.Lock()
+		delete(builtins, "test-builtin-load")
+		builtinsMu.Unlock()
+	}()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+
+	require.NoError(t, LoadBuiltin(ctx, "test-builtin-load", "my-plugin", nil, logger))
+	p, err := GetPlugin("my-plugin")
+	require.NoError(t, err)
+	assert.IsType(t, &TestPlugin{}, p)
+
+	err = LoadBuiltin(ctx, "test-builtin-load", "broken", map[string]interface{}{"fail": true}, logger)
+	assert.ErrorContains(t, err, "error initializing plugin: 'broken'")
+}
@@ -0,0 +1,340 @@
+package pluginconf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"expressops/internal/redact"
+	"expressops/internal/schema"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode decodes the config block of a plugin into out, a pointer to a
+// struct. Fields are matched by their `yaml` tag and may carry the tags
+// understood by schema.FromType:
+//
+//	type Config struct {
+//		URL     string        `yaml:"url" required:"true"`
+//		Retries int           `yaml:"retries" default:"3"`
+//		Timeout time.Duration `yaml:"timeout" default:"10s"`
+//		Format  string        `yaml:"format" enum:"text,json"`
+//		Token   string        `yaml:"token" secret:"true"`
+//	}
+//
+// Numbers are converted to the type of the field whatever the decoder made
+// of them, as long as the value fits: YAML gives an int for `500` and a
+// float64 for `500.0`, JSON always a float64. Durations are parsed from
+// strings. A field whose key is missing or null gets its default, or keeps
+// its value when it has none. Unknown keys are errors. Every error is reported,
+// each prefixed with the path of the offending key. The values of secret
+// fields are masked in logs, responses and traces.
+func Decode(config map[string]interface{}, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode needs a pointer to a struct, got %T", out)
+	}
+	var value interface{} = map[string]interface{}{}
+	if config != nil {
+		value = config
+	}
+	return errors.Join(decodeValue("", value, v.Elem())...)
+}
+
+func decodeValue(path string, in interface{}, out reflect.Value) []error {
+	if in == nil {
+		return nil
+	}
+
+	if out.Type() == durationType {
+		s, ok := in.(string)
+		if !ok {
+			return []error{typeError(path, "duration", in)}
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return []error{fmt.Errorf("%s: invalid duration '%s'", displayPath(path), s)}
+		}
+		out.SetInt(int64(d))
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Interface:
+		out.Set(reflect.ValueOf(in))
+	case reflect.Ptr:
+		elem := reflect.New(out.Type().Elem())
+		if errs := decodeValue(path, in, elem.Elem()); len(errs) > 0 {
+			return errs
+		}
+		out.Set(elem)
+	case reflect.Struct:
+		m, ok := in.(map[string]interface{})
+		if !ok {
+			return []error{typeError(path, "object", in)}
+		}
+		return decodeStruct(path, m, out)
+	case reflect.Map:
+		return decodeMap(path, in, out)
+	case reflect.Slice:
+		return decodeSlice(path, in, out)
+	case reflect.String:
+		s, ok := in.(string)
+		if !ok {
+			return []error{typeError(path, "string", in)}
+		}
+		out.SetString(s)
+	case reflect.Bool:
+		b, ok := in.(bool)
+		if !ok {
+			return []error{typeError(path, "boolean", in)}
+		}
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toFloat(in)
+		if !ok || n != math.Trunc(n) {
+			return []error{typeError(path, "integer", in)}
+		}
+		if out.OverflowInt(int64(n)) {
+			return []error{fmt.Errorf("%s: %v is out of range", displayPath(path), in)}
+		}
+		out.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := toFloat(in)
+		if !ok || n != math.Trunc(n) || n < 0 {
+			return []error{typeError(path, "non-negative integer", in)}
+		}
+		if out.OverflowUint(uint64(n)) {
+			return []error{fmt.Errorf("%s: %v is out of range", displayPath(path), in)}
+		}
+		out.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(in)
+		if !ok {
+			return []error{typeError(path, "number", in)}
+		}
+		out.SetFloat(n)
+	default:
+		return []error{fmt.Errorf("%s: cannot decode into %s", displayPath(path), out.Type())}
+	}
+	return nil
+}
+
+func decodeStruct(path string, in map[string]interface{}, out reflect.Value) []error {
+	var errs []error
+	known := make(map[string]bool)
+
+	var visit func(v reflect.Value)
+	visit = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, inline := schema.FieldName(field)
+			if inline {
+				visit(v.Field(i))
+				continue
+			}
+			if name == "-" {
+				continue
+			}
+			known[name] = true
+			child := joinPath(path, name)
+
+			// A key without a value, e.g. `url:` in YAML, counts as missing
+			raw, ok := in[name]
+			if !ok || raw == nil {
+				if field.Tag.Get("required") == "true" {
+					errs = append(errs, fmt.Errorf("%s: missing required property '%s'", displayPath(path), name))
+				} else if def, ok := field.Tag.Lookup("default"); ok {
+					errs = append(errs, decodeDefault(child, def, v.Field(i))...)
+				}
+				continue
+			}
+
+			fieldErrs := decodeValue(child, raw, v.Field(i))
+			if len(fieldErrs) == 0 {
+				fieldErrs = checkEnum(child, field.Tag.Get("enum"), v.Field(i))
+			}
+			if field.Tag.Get("secret") == "true" {
+				if s, ok := raw.(string); ok {
+					redact.Default().Add(s)
+				}
+			}
+			errs = append(errs, fieldErrs...)
+		}
+	}
+	visit(out)
+
+	var unknown []string
+	for k := range in {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown property%s", joinPath(path, k), knownList(known)))
+	}
+	return errs
+}
+
+// decodeDefault decodes the `default` tag of a field, written as in YAML
+func decodeDefault(path, def string, out reflect.Value) []error {
+	var value interface{} = def
+	switch out.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return []error{fmt.Errorf("%s: invalid default '%s'", path, def)}
+		}
+		value = b
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if out.Type() == durationType {
+			break
+		}
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return []error{fmt.Errorf("%s: invalid default '%s'", path, def)}
+		}
+		value = f
+	case reflect.Slice:
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(def, ",") {
+			items = append(items, item)
+		}
+		value = items
+	}
+	return decodeValue(path, value, out)
+}
+
+func checkEnum(path, enum string, v reflect.Value) []error {
+	if enum == "" {
+		return nil
+	}
+	values := strings.Split(enum, ",")
+	actual := fmt.Sprint(v.Interface())
+	for _, allowed := range values {
+		if actual == allowed {
+			return nil
+		}
+	}
+	return []error{fmt.Errorf("%s: must be one of %v", path, values)}
+}
+
+func decodeMap(path string, in interface{}, out reflect.Value) []error {
+	if out.Type().Key().Kind() != reflect.String {
+		return []error{fmt.Errorf("%s: cannot decode into %s", displayPath(path), out.Type())}
+	}
+	src := reflect.ValueOf(in)
+	if src.Kind() != reflect.Map || src.Type().Key().Kind() != reflect.String {
+		return []error{typeError(path, "object", in)}
+	}
+
+	keys := make([]string, 0, src.Len())
+	for _, k := range src.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	m := reflect.MakeMapWithSize(out.Type(), len(keys))
+	for _, k := range keys {
+		elem := reflect.New(out.Type().Elem()).Elem()
+		itemErrs := decodeValue(joinPath(path, k), src.MapIndex(reflect.ValueOf(k)).Interface(), elem)
+		errs = append(errs, itemErrs...)
+		m.SetMapIndex(reflect.ValueOf(k).Convert(out.Type().Key()), elem)
+	}
+	if len(errs) == 0 {
+		out.Set(m)
+	}
+	return errs
+}
+
+func decodeSlice(path string, in interface{}, out reflect.Value) []error {
+	src := reflect.ValueOf(in)
+	if src.Kind() != reflect.Slice && src.Kind() != reflect.Array {
+		return []error{typeError(path, "array", in)}
+	}
+
+	var errs []error
+	s := reflect.MakeSlice(out.Type(), src.Len(), src.Len())
+	for i := 0; i < src.Len(); i++ {
+		errs = append(errs, decodeValue(fmt.Sprintf("%s[%d]", path, i), src.Index(i).Interface(), s.Index(i))...)
+	}
+	if len(errs) == 0 {
+		out.Set(s)
+	}
+	return errs
+}
+
+func typeError(path, expected string, value interface{}) error {
+	return fmt.Errorf("%s: expected %s, got %s", displayPath(path), expected, typeName(value))
+}
+
+// toFloat converts the numeric types produced by the YAML and JSON decoders
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if n, ok := toFloat(value); ok {
+		return fmt.Sprintf("number %v", n)
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func knownList(known map[string]bool) string {
+	if len(known) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" (known: %s)", strings.Join(names, ", "))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,93 @@
+package pluginconf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodeTarget struct {
+	URL        string             `yaml:"url" required:"true"`
+	Retries    int                `yaml:"retries" default:"3"`
+	Ratio      float64            `yaml:"ratio"`
+	Timeout    time.Duration      `yaml:"timeout" default:"10s"`
+	Format     string             `yaml:"format" enum:"text,json" default:"text"`
+	DryRun     bool               `yaml:"dry_run"`
+	Patterns   []string           `yaml:"patterns" default:"*.tmp,*.log"`
+	Thresholds map[string]float64 `yaml:"thresholds"`
+	Auth       *struct {
+		Token string `yaml:"token" required:"true"`
+	} `yaml:"auth"`
+	Extra interface{} `yaml:"extra"`
+}
+
+func TestDecode(t *testing.T) {
+	var cfg decodeTarget
+	err := Decode(map[string]interface{}{
+		"url":        "http://example.com",
+		"ratio":      1,
+		"thresholds": map[string]interface{}{"cpu": 80, "disk": 92.5},
+		"auth":       map[string]interface{}{"token": "secret"},
+		"extra":      []interface{}{"anything"},
+	}, &cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "http://example.com", cfg.URL)
+	assert.Equal(t, 3, cfg.Retries)
+	assert.Equal(t, 1.0, cfg.Ratio, "an int is accepted for a float")
+	assert.Equal(t, 10*time.Second, cfg.Timeout)
+	assert.Equal(t, "text", cfg.Format)
+	assert.Equal(t, []string{"*.tmp", "*.log"}, cfg.Patterns)
+	assert.Equal(t, map[string]float64{"cpu": 80, "disk": 92.5}, cfg.Thresholds)
+	require.NotNil(t, cfg.Auth)
+	assert.Equal(t, "secret", cfg.Auth.Token)
+	assert.Equal(t, []interface{}{"anything"}, cfg.Extra)
+}
+
+func TestDecodeKeepsPresetValues(t *testing.T) {
+	cfg := decodeTarget{Ratio: 0.5}
+	require.NoError(t, Decode(map[string]interface{}{"url": "u", "retries": 5.0}, &cfg))
+	assert.Equal(t, 0.5, cfg.Ratio)
+	assert.Equal(t, 5, cfg.Retries, "an integral float is accepted for an int")
+}
+
+func TestDecodeNullValues(t *testing.T) {
+	var cfg decodeTarget
+	err := Decode(map[string]interface{}{"url": nil, "retries": nil, "patterns": nil}, &cfg)
+	assert.EqualError(t, err, "(root): missing required property 'url'")
+	assert.Equal(t, 3, cfg.Retries)
+	assert.Equal(t, []string{"*.tmp", "*.log"}, cfg.Patterns)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var cfg decodeTarget
+	err := Decode(map[string]interface{}{
+		"retries":    1.5,
+		"timeout":    "soon",
+		"format":     "xml",
+		"dry_run":    "yes",
+		"patterns":   []interface{}{"*.tmp", 3},
+		"thresholds": map[string]interface{}{"cpu": "high"},
+		"auth":       map[string]interface{}{},
+		"urll":       "typo",
+	}, &cfg)
+	require.Error(t, err)
+
+	for _, expected := range []string{
+		"(root): missing required property 'url'",
+		"retries: expected integer, got number 1.5",
+		"timeout: invalid duration 'soon'",
+		"format: must be one of [text json]",
+		"dry_run: expected boolean, got string",
+		"patterns[1]: expected string, got number 3",
+		"thresholds.cpu: expected number, got string",
+		"auth: missing required property 'token'",
+		"urll: unknown property (known: auth, dry_run, extra, format, patterns, ratio, retries, thresholds, timeout, url)",
+	} {
+		assert.ErrorContains(t, err, expected)
+	}
+
+	assert.ErrorContains(t, Decode(nil, cfg), "decode needs a pointer to a struct")
+}
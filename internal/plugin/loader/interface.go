@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 
+	"expressops/internal/schema"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -13,3 +15,10 @@ type Plugin interface {
 	Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error)
 	FormatResult(result interface{}) (string, error)
 }
+
+// ConfigSchemaProvider is optionally implemented by plugins to describe their
+// `config` block. The schema is merged into `expressops config schema`.
+// ConfigSchema must not depend on Initialize having been called.
+type ConfigSchemaProvider interface {
+	ConfigSchema() *schema.Schema
+}
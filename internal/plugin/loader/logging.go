@@ -0,0 +1,35 @@
+package pluginconf
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns ctx carrying the logger of an execution, to be passed
+// to Execute
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger of the execution ctx belongs to. Its
+// lines carry the execution_id, flow and step fields and are kept with the
+// result of the execution:
+//
+//	func (p *MyPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+//		logger := pluginconf.LoggerFromContext(ctx, p.logger)
+//		logger.Info("sending message")
+//
+// Outside an execution, e.g. in Initialize, it returns an entry of fallback,
+// or of the standard logger when fallback is nil.
+func LoggerFromContext(ctx context.Context, fallback *logrus.Logger) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	if fallback == nil {
+		fallback = logrus.StandardLogger()
+	}
+	return logrus.NewEntry(fallback)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"plugin"
+	"strings"
 	"sync"
 
 	"github.com/sirupsen/logrus"
@@ -14,35 +15,49 @@ var (
 	registry = make(map[string]Plugin)
 	mu       sync.Mutex
 
+	// singletons maps the path of each .so plugin without a factory to the
+	// first entry that loaded it
+	singletons = make(map[string]string)
+
 	// GetPluginFunc is a variable that allows mocking the GetPlugin function in tests
 	GetPluginFunc = defaultGetPlugin
 )
 
-// LoadPlugin loads a plugin into memory from a .so file
+// LoadPlugin loads a plugin into memory from a .so file. A plugin exporting
+// a `NewPlugin` factory gets a new instance for every configured entry;
+// older plugins only export a single `PluginInstance`, which is shared by
+// every entry loaded from the same file.
 func LoadPlugin(ctx context.Context, path string, name string, config map[string]interface{}, logger *logrus.Logger) error {
-	// Check if plugin file exists before attempting to load
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("plugin file '%s' does not exist", path)
-	}
-
-	p, err := plugin.Open(path)
+	pluginInstance, shared, err := openPlugin(path, name)
 	if err != nil {
-		return fmt.Errorf("error opening plugin '%s': %w", path, err)
+		return err
 	}
 
-	// Look up the symbol "PluginInstance" in the plugin
-	sym, err := p.Lookup("PluginInstance")
-	if err != nil {
-		return fmt.Errorf("error looking up symbol 'PluginInstance' in plugin '%s': %w", name, err)
-	}
-	// Verify the type of the symbol
-	pluginPtr, ok := sym.(*Plugin)
-	if !ok {
-		return fmt.Errorf("type %T does not implement Plugin interface", sym)
+	if shared {
+		mu.Lock()
+		previous, loaded := singletons[path]
+		if !loaded {
+			singletons[path] = name
+		}
+		mu.Unlock()
+		if loaded && previous != name {
+			logger.Warnf("Plugin '%s' shares its instance with '%s': '%s' exports no NewPlugin factory, so the last config loaded wins for both", name, previous, path)
+		}
 	}
+	return LoadInstance(ctx, pluginInstance, name, config, logger)
+}
 
-	pluginInstance := *pluginPtr
+// LoadInstance checks config against the manifest of a plugin instance,
+// initializes the instance and registers it under name, whatever its origin
+func LoadInstance(ctx context.Context, pluginInstance Plugin, name string, config map[string]interface{}, logger *logrus.Logger) error {
+	manifest := ManifestOf(pluginInstance)
+	// Before anything may log or return the values
+	registerSecrets(manifest, config)
+	if err := manifest.Check(config); err != nil {
+		return fmt.Errorf("plugin '%s': %w", name, err)
+	}
 
+	ctx = WithMetrics(ctx, metricsFor(name, logger))
 	if err := pluginInstance.Initialize(ctx, config, logger); err != nil {
 		return fmt.Errorf("error initializing plugin: '%s': %w", name, err)
 	}
@@ -50,11 +65,104 @@ func LoadPlugin(ctx context.Context, path string, name string, config map[string
 	// Register the plugin in our list of plugins (Registry)
 	mu.Lock()
 	registry[name] = pluginInstance
+	manifests[name] = manifest
 	mu.Unlock()
 
 	return nil
 }
 
+// InspectPlugin opens a .so file and returns its plugin without initializing
+// or registering it, e.g. to read its config schema
+func InspectPlugin(path string) (Plugin, error) {
+	p, _, err := openPlugin(path, path)
+	return p, err
+}
+
+// openPlugin opens a .so file and returns the plugin it exports. shared
+// reports whether the plugin is the `PluginInstance` singleton rather than
+// a new instance made by the `NewPlugin` factory.
+func openPlugin(path string, name string) (instance Plugin, shared bool, err error) {
+	// Check if plugin file exists before attempting to load
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("plugin file '%s' does not exist", path)
+	}
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		// plugin.Open is a stub in binaries built without CGO
+		if strings.Contains(err.Error(), "not implemented") {
+			return nil, false, fmt.Errorf("error opening plugin '%s': this server was built without CGO and cannot load .so plugins, "+
+				"use a builtin or a process plugin or rebuild it with CGO_ENABLED=1: %w", path, err)
+		}
+		return nil, false, fmt.Errorf("error opening plugin '%s': %w", path, err)
+	}
+	mu.Lock()
+	opened[path] = true
+	mu.Unlock()
+
+	instance, shared, err = lookupInstance(p, name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The manifest is optional
+	if sym, err := p.Lookup("PluginManifest"); err == nil {
+		manifest, ok := sym.(*Manifest)
+		if !ok {
+			return nil, false, fmt.Errorf("symbol 'PluginManifest' in plugin '%s' has type %T, expected Manifest", name, sym)
+		}
+		m := *manifest
+		if m.ConfigSchema == nil {
+			m.ConfigSchema = ManifestOf(instance).ConfigSchema
+		}
+		instance = &withManifest{Plugin: instance, manifest: m}
+	}
+	return instance, shared, nil
+}
+
+// symbolLookup is implemented by *plugin.Plugin
+type symbolLookup interface {
+	Lookup(symName string) (plugin.Symbol, error)
+}
+
+// lookupInstance prefers the `NewPlugin` factory of a plugin and falls back
+// to its `PluginInstance` variable
+func lookupInstance(p symbolLookup, name string) (Plugin, bool, error) {
+	if sym, err := p.Lookup("NewPlugin"); err == nil {
+		var factory func() Plugin
+		switch f := sym.(type) {
+		case func() Plugin:
+			factory = f
+		case *func() Plugin:
+			factory = *f
+		case *Factory:
+			factory = *f
+		default:
+			return nil, false, fmt.Errorf("symbol 'NewPlugin' in plugin '%s' has type %T, expected func() Plugin", name, sym)
+		}
+		if factory == nil {
+			return nil, false, fmt.Errorf("symbol 'NewPlugin' in plugin '%s' is nil", name)
+		}
+		instance := factory()
+		if instance == nil {
+			return nil, false, fmt.Errorf("NewPlugin of plugin '%s' returned nil", name)
+		}
+		return instance, false, nil
+	}
+
+	// Look up the symbol "PluginInstance" in the plugin
+	sym, err := p.Lookup("PluginInstance")
+	if err != nil {
+		return nil, false, fmt.Errorf("plugin '%s' exports neither 'NewPlugin' nor 'PluginInstance': %w", name, err)
+	}
+	// Verify the type of the symbol
+	pluginPtr, ok := sym.(*Plugin)
+	if !ok {
+		return nil, false, fmt.Errorf("type %T does not implement Plugin interface", sym)
+	}
+	return *pluginPtr, true, nil
+}
+
 // Implementación por defecto de GetPlugin
 func defaultGetPlugin(name string) (Plugin, error) {
 	mu.Lock()
@@ -72,13 +180,3 @@ func defaultGetPlugin(name string) (Plugin, error) {
 func GetPlugin(name string) (Plugin, error) {
 	return GetPluginFunc(name)
 }
-
-// GetMetricsFunc checks if a metrics function exists by name
-func GetMetricsFunc(funcName string) (interface{}, error) {
-	return nil, fmt.Errorf("metrics function not accessible")
-}
-
-// UpdateMetric updates a metric by name with a value
-func UpdateMetric(funcName string, value float64) error {
-	return fmt.Errorf("metrics update not implemented")
-}
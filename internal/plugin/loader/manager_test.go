@@ -3,8 +3,10 @@ package pluginconf
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"net/http"
+	"plugin"
 	
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -118,4 +120,37 @@ func TestGetPlugin(t *testing.T) {
 
 // For `LoadPlugin`, we would need a different approach due to the complexity
 // of dynamically compiling Go plugins in tests. One option is to use mocks
-// to simulate the behavior of `plugin.Open` and its dependencies.
\ No newline at end of file
+// to simulate the behavior of `plugin.Open` and its dependencies.
+type fakeSymbols map[string]plugin.Symbol
+
+func (f fakeSymbols) Lookup(name string) (plugin.Symbol, error) {
+	if sym, ok := f[name]; ok {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("symbol %s not found", name)
+}
+
+func TestLookupInstancePrefersFactory(t *testing.T) {
+	// manifestPlugin is not zero-sized, so distinct instances have distinct addresses
+	singleton := Plugin(&manifestPlugin{})
+	newPlugin := func() Plugin { return &manifestPlugin{} }
+
+	first, shared, err := lookupInstance(fakeSymbols{"NewPlugin": newPlugin, "PluginInstance": &singleton}, "p")
+	assert.NoError(t, err)
+	assert.False(t, shared)
+	second, _, err := lookupInstance(fakeSymbols{"NewPlugin": newPlugin, "PluginInstance": &singleton}, "p")
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second, "each entry must get its own instance")
+	assert.NotSame(t, singleton, first)
+
+	instance, shared, err := lookupInstance(fakeSymbols{"PluginInstance": &singleton}, "p")
+	assert.NoError(t, err)
+	assert.True(t, shared)
+	assert.Same(t, singleton, instance)
+
+	_, _, err = lookupInstance(fakeSymbols{"NewPlugin": "not a factory"}, "p")
+	assert.ErrorContains(t, err, "symbol 'NewPlugin' in plugin 'p' has type string")
+
+	_, _, err = lookupInstance(fakeSymbols{}, "p")
+	assert.ErrorContains(t, err, "plugin 'p' exports neither 'NewPlugin' nor 'PluginInstance'")
+}
@@ -0,0 +1,115 @@
+package pluginconf
+
+import (
+	"fmt"
+	"sort"
+
+	"expressops/internal/schema"
+)
+
+// APIVersion is the version of the plugin API implemented by this server.
+// Plugins whose manifest requires another version are refused.
+const APIVersion = "v1"
+
+// Manifest describes a plugin. A `.so` plugin can export it as a
+// `PluginManifest` variable; other plugins implement ManifestProvider.
+// Every field is optional.
+type Manifest struct {
+	Name        string `json:"name,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	// APIVersion is the plugin API the plugin was written for
+	APIVersion string `json:"apiVersion,omitempty"`
+	// ConfigSchema describes the `config` block, which is validated against
+	// it before Initialize is called
+	ConfigSchema *schema.Schema `json:"configSchema,omitempty"`
+	// Inputs and Outputs are the keys of the shared context the plugin reads
+	// and writes
+	Inputs  []string `json:"inputs,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+// ManifestProvider is optionally implemented by plugins to describe
+// themselves. Manifest must not depend on Initialize having been called.
+type ManifestProvider interface {
+	Manifest() Manifest
+}
+
+// LoadedPlugin is a registered plugin with its manifest
+type LoadedPlugin struct {
+	Name     string
+	Manifest Manifest
+}
+
+var manifests = make(map[string]Manifest)
+
+// ManifestOf returns the manifest of a plugin. Plugins that only implement
+// ConfigSchemaProvider get a manifest holding their config schema.
+func ManifestOf(p Plugin) Manifest {
+	var m Manifest
+	if provider, ok := p.(ManifestProvider); ok {
+		m = provider.Manifest()
+	}
+	if m.ConfigSchema == nil {
+		if provider, ok := p.(ConfigSchemaProvider); ok {
+			m.ConfigSchema = provider.ConfigSchema()
+		}
+	}
+	return m
+}
+
+// Check refuses a plugin written for another plugin API and validates config
+// against the config schema
+func (m Manifest) Check(config map[string]interface{}) error {
+	if m.APIVersion != "" && m.APIVersion != APIVersion {
+		return fmt.Errorf("plugin requires API version %s, this server implements %s", m.APIVersion, APIVersion)
+	}
+	if m.ConfigSchema == nil {
+		return nil
+	}
+	var value interface{} = map[string]interface{}{}
+	if config != nil {
+		value = config
+	}
+	if err := m.ConfigSchema.Validate(value); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// GetManifest returns the manifest of a registered plugin
+func GetManifest(name string) (Manifest, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	m, ok := manifests[name]
+	return m, ok
+}
+
+// Loaded returns the registered plugins sorted by name
+func Loaded() []LoadedPlugin {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loaded := make([]LoadedPlugin, 0, len(registry))
+	for name := range registry {
+		loaded = append(loaded, LoadedPlugin{Name: name, Manifest: manifests[name]})
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Name < loaded[j].Name })
+	return loaded
+}
+
+// withManifest attaches the manifest exported by a `.so` file to its plugin
+type withManifest struct {
+	Plugin
+	manifest Manifest
+}
+
+func (p *withManifest) Manifest() Manifest {
+	return p.manifest
+}
+
+func (p *withManifest) ConfigSchema() *schema.Schema {
+	return p.manifest.ConfigSchema
+}
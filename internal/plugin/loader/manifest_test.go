@@ -0,0 +1,63 @@
+package pluginconf
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"expressops/internal/schema"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type manifestPlugin struct {
+	TestPlugin
+	manifest Manifest
+}
+
+func (p *manifestPlugin) Manifest() Manifest {
+	return p.manifest
+}
+
+func TestLoadInstanceChecksManifest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	defer func() {
+		mu.Lock()
+		for _, name := range []string{"described", "bad-config", "too-new"} {
+			delete(registry, name)
+			delete(manifests, name)
+		}
+		mu.Unlock()
+	}()
+
+	manifest := Manifest{
+		Name:         "described",
+		Version:      "1.2.0",
+		APIVersion:   APIVersion,
+		ConfigSchema: schema.Object(map[string]*schema.Schema{"channel": schema.String("")}, "channel"),
+		Inputs:       []string{"message"},
+	}
+
+	p := &manifestPlugin{manifest: manifest}
+	require.NoError(t, LoadInstance(ctx, p, "described", map[string]interface{}{"channel": "#ops"}, logger))
+	got, ok := GetManifest("described")
+	require.True(t, ok)
+	assert.Equal(t, manifest, got)
+	assert.Contains(t, Loaded(), LoadedPlugin{Name: "described", Manifest: manifest})
+
+	err := LoadInstance(ctx, p, "bad-config", map[string]interface{}{"chanel": "#ops"}, logger)
+	assert.ErrorContains(t, err, "plugin 'bad-config': invalid config")
+	assert.ErrorContains(t, err, "missing required property 'channel'")
+	assert.ErrorContains(t, err, "chanel: unknown property (known: channel)")
+
+	tooNew := &manifestPlugin{manifest: Manifest{APIVersion: "v2"}}
+	err = LoadInstance(ctx, tooNew, "too-new", nil, logger)
+	assert.ErrorContains(t, err, "plugin requires API version v2, this server implements v1")
+
+	_, ok = GetManifest("bad-config")
+	assert.False(t, ok)
+}
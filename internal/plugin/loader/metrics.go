@@ -0,0 +1,302 @@
+package pluginconf
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"expressops/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Limits on the metrics of a plugin. A label value is often taken from a
+// request, and every distinct combination is a series Prometheus keeps
+// forever, so observations beyond MaxSeries are dropped rather than
+// recorded.
+const (
+	MaxLabels = 5
+	MaxSeries = 100
+)
+
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Metrics registers the metrics of one plugin. Plugins get it in Initialize
+// with MetricsFromContext:
+//
+//	m := pluginconf.MetricsFromContext(ctx)
+//	p.sent, err = m.Counter("messages_sent_total", "Messages sent.", "status")
+//	...
+//	p.sent.Inc("success")
+//
+// Names are prefixed with expressops_plugin_<plugin name>_, so plugins can
+// neither clash with each other nor with the metrics of the engine.
+// Registering a metric again, e.g. when the plugin is reloaded, returns the
+// existing one as long as its kind and labels did not change.
+type Metrics struct {
+	plugin     string
+	registerer prometheus.Registerer
+	logger     *logrus.Logger
+
+	mu      sync.Mutex
+	metrics map[string]*pluginMetric
+}
+
+// NewMetrics returns the metrics of plugin, registered with registerer.
+// The loader registers them with the /metrics registry; tests may pass a
+// registry of their own.
+func NewMetrics(plugin string, registerer prometheus.Registerer, logger *logrus.Logger) *Metrics {
+	return &Metrics{
+		plugin:     plugin,
+		registerer: registerer,
+		logger:     logger,
+		metrics:    make(map[string]*pluginMetric),
+	}
+}
+
+type metricsKey struct{}
+
+// WithMetrics returns ctx carrying m, to be passed to Initialize
+func WithMetrics(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, metricsKey{}, m)
+}
+
+// MetricsFromContext returns the metrics given to the plugin being
+// initialized. Without any, e.g. in a test, it returns metrics registered
+// nowhere, so that plugins never have to check.
+func MetricsFromContext(ctx context.Context) *Metrics {
+	if m, ok := ctx.Value(metricsKey{}).(*Metrics); ok {
+		return m
+	}
+	return NewMetrics("unregistered", prometheus.NewRegistry(), logrus.StandardLogger())
+}
+
+var (
+	pluginMetricsMu sync.Mutex
+	pluginMetrics   = make(map[string]*Metrics)
+)
+
+// metricsFor returns the metrics of the plugin registered under name,
+// shared by every instance the name is loaded or reloaded with
+func metricsFor(name string, logger *logrus.Logger) *Metrics {
+	pluginMetricsMu.Lock()
+	defer pluginMetricsMu.Unlock()
+	m, ok := pluginMetrics[name]
+	if !ok {
+		m = NewMetrics(name, metrics.Registry(), logger)
+		pluginMetrics[name] = m
+	}
+	return m
+}
+
+// Counter registers a counter with the given label names
+func (m *Metrics) Counter(name, help string, labels ...string) (*Counter, error) {
+	pm, err := m.register(kindCounter, name, help, labels, func(opts prometheus.Opts) prometheus.Collector {
+		return prometheus.NewCounterVec(prometheus.CounterOpts(opts), labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{pm}, nil
+}
+
+// Gauge registers a gauge with the given label names
+func (m *Metrics) Gauge(name, help string, labels ...string) (*Gauge, error) {
+	pm, err := m.register(kindGauge, name, help, labels, func(opts prometheus.Opts) prometheus.Collector {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts(opts), labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Gauge{pm}, nil
+}
+
+// Histogram registers a histogram with the given buckets, the Prometheus
+// defaults when nil, and label names
+func (m *Metrics) Histogram(name, help string, buckets []float64, labels ...string) (*Histogram, error) {
+	for _, label := range labels {
+		if label == "le" {
+			return nil, fmt.Errorf("metric '%s': label 'le' is reserved for histogram buckets", name)
+		}
+	}
+	pm, err := m.register(kindHistogram, name, help, labels, func(opts prometheus.Opts) prometheus.Collector {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    opts.Name,
+			Help:    opts.Help,
+			Buckets: buckets,
+		}, labels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Histogram{pm}, nil
+}
+
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+func (m *Metrics) register(kind metricKind, name, help string, labels []string, build func(prometheus.Opts) prometheus.Collector) (*pluginMetric, error) {
+	if !metricNameRE.MatchString(name) {
+		return nil, fmt.Errorf("metric '%s': invalid name, use letters, digits and underscores", name)
+	}
+	if len(labels) > MaxLabels {
+		return nil, fmt.Errorf("metric '%s': %d labels, at most %d are allowed", name, len(labels), MaxLabels)
+	}
+	for _, label := range labels {
+		if !metricNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+			return nil, fmt.Errorf("metric '%s': invalid label name '%s'", name, label)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.metrics[name]; ok {
+		if existing.kind != kind || strings.Join(existing.labels, ",") != strings.Join(labels, ",") {
+			return nil, fmt.Errorf("metric '%s' is already registered as a %s with labels %v", name, existing.kind, existing.labels)
+		}
+		return existing, nil
+	}
+
+	if help == "" {
+		help = "Metric of plugin " + m.plugin + "."
+	}
+	collector := build(prometheus.Opts{Name: MetricName(m.plugin, name), Help: help})
+	if err := m.registerer.Register(collector); err != nil {
+		return nil, fmt.Errorf("metric '%s': %w", name, err)
+	}
+
+	pm := &pluginMetric{
+		metrics:   m,
+		name:      name,
+		kind:      kind,
+		labels:    append([]string(nil), labels...),
+		collector: collector,
+		series:    make(map[string]bool),
+	}
+	m.metrics[name] = pm
+	return pm, nil
+}
+
+// pluginMetric is a metric registered by a plugin, with the series it has
+// recorded so far
+type pluginMetric struct {
+	metrics   *Metrics
+	name      string
+	kind      metricKind
+	labels    []string
+	collector prometheus.Collector
+
+	mu      sync.Mutex
+	series  map[string]bool
+	dropped bool
+}
+
+// admit reports whether labelValues may be recorded: they must match the
+// label names, and either be a known series or leave room for a new one.
+// Dropped observations are counted and logged once per metric.
+func (pm *pluginMetric) admit(labelValues []string) bool {
+	if len(labelValues) != len(pm.labels) {
+		pm.drop(fmt.Sprintf("got %d label values for labels %v", len(labelValues), pm.labels))
+		return false
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	pm.mu.Lock()
+	known := pm.series[key]
+	if !known && len(pm.series) < MaxSeries {
+		pm.series[key] = true
+		known = true
+	}
+	pm.mu.Unlock()
+
+	if !known {
+		pm.drop(fmt.Sprintf("more than %d series, dropping %v", MaxSeries, labelValues))
+	}
+	return known
+}
+
+func (pm *pluginMetric) drop(reason string) {
+	metrics.RecordPluginMetricDropped(pm.metrics.plugin, pm.name)
+
+	pm.mu.Lock()
+	first := !pm.dropped
+	pm.dropped = true
+	pm.mu.Unlock()
+	if first {
+		pm.metrics.logger.Warnf("Plugin '%s' metric '%s': %s. Further drops are only counted in expressops_plugin_metric_dropped_total.", pm.metrics.plugin, pm.name, reason)
+	}
+}
+
+// Counter is a counter registered by a plugin
+type Counter struct{ m *pluginMetric }
+
+// Inc increments the counter of the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter of the series with
+// the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		c.m.drop(fmt.Sprintf("counters cannot decrease, got %v", v))
+		return
+	}
+	if c.m.admit(labelValues) {
+		c.m.collector.(*prometheus.CounterVec).WithLabelValues(labelValues...).Add(v)
+	}
+}
+
+// Gauge is a gauge registered by a plugin
+type Gauge struct{ m *pluginMetric }
+
+// Set sets the gauge of the series with the given label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	if g.m.admit(labelValues) {
+		g.m.collector.(*prometheus.GaugeVec).WithLabelValues(labelValues...).Set(v)
+	}
+}
+
+// Add adds v, possibly negative, to the gauge of the series with the given
+// label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	if g.m.admit(labelValues) {
+		g.m.collector.(*prometheus.GaugeVec).WithLabelValues(labelValues...).Add(v)
+	}
+}
+
+// Histogram is a histogram registered by a plugin
+type Histogram struct{ m *pluginMetric }
+
+// Observe records v in the histogram of the series with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if h.m.admit(labelValues) {
+		h.m.collector.(*prometheus.HistogramVec).WithLabelValues(labelValues...).Observe(v)
+	}
+}
+
+// MetricName returns the full name of the metric name of plugin, e.g.
+// expressops_plugin_slack_plugin_messages_sent_total for messages_sent_total
+// of slack-plugin
+func MetricName(plugin, name string) string {
+	return "expressops_plugin_" + sanitizeMetricName(plugin) + "_" + name
+}
+
+// sanitizeMetricName turns a plugin name such as slack-plugin into slack_plugin
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,161 @@
+package pluginconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"expressops/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewMetrics("ticket-notifier", registry, discardLogger())
+
+	sent, err := m.Counter("messages_sent_total", "Messages sent.", "status")
+	require.NoError(t, err)
+	sent.Inc("success")
+	sent.Add(2, "success")
+	sent.Inc("error")
+
+	queue, err := m.Gauge("queue_length", "")
+	require.NoError(t, err)
+	queue.Set(4)
+	queue.Add(-1)
+
+	latency, err := m.Histogram("latency_seconds", "Webhook latency.", []float64{0.1, 1})
+	require.NoError(t, err)
+	latency.Observe(0.5)
+
+	assert.Equal(t, 4, testutil.CollectAndCount(registry))
+	assert.Equal(t, 3.0, testutil.ToFloat64(sent.m.collector.(*prometheus.CounterVec).WithLabelValues("success")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(queue.m.collector))
+
+	names, err := testutil.GatherAndCount(registry,
+		"expressops_plugin_ticket_notifier_messages_sent_total",
+		"expressops_plugin_ticket_notifier_queue_length",
+		"expressops_plugin_ticket_notifier_latency_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 4, names)
+
+	// Registering again, as a reloaded plugin does, returns the same metric
+	again, err := m.Counter("messages_sent_total", "Messages sent.", "status")
+	require.NoError(t, err)
+	assert.Same(t, sent.m, again.m)
+
+	_, err = m.Gauge("messages_sent_total", "", "status")
+	assert.ErrorContains(t, err, "already registered as a counter")
+	_, err = m.Counter("messages_sent_total", "", "status", "channel")
+	assert.ErrorContains(t, err, "already registered")
+}
+
+func TestMetricsValidation(t *testing.T) {
+	m := NewMetrics("validation", prometheus.NewRegistry(), discardLogger())
+
+	_, err := m.Counter("bad-name", "")
+	assert.ErrorContains(t, err, "invalid name")
+	_, err = m.Counter("labels_total", "", "a", "b", "c", "d", "e", "f")
+	assert.ErrorContains(t, err, "at most 5")
+	_, err = m.Counter("reserved_total", "", "__name")
+	assert.ErrorContains(t, err, "invalid label name")
+	_, err = m.Histogram("le_seconds", "", nil, "le")
+	assert.ErrorContains(t, err, "reserved")
+}
+
+func TestMetricsCardinality(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := NewMetrics("cardinality", registry, discardLogger())
+
+	requests, err := m.Counter("requests_total", "", "user")
+	require.NoError(t, err)
+	for i := 0; i < MaxSeries+10; i++ {
+		requests.Inc(fmt.Sprintf("user-%d", i))
+	}
+	// A known series is still recorded once the limit is reached
+	requests.Inc("user-0")
+	// Wrong label values and decreasing a counter are dropped too
+	requests.Inc()
+	requests.Add(-1, "user-0")
+
+	assert.Equal(t, MaxSeries, testutil.CollectAndCount(registry))
+	assert.Equal(t, 2.0, testutil.ToFloat64(requests.m.collector.(*prometheus.CounterVec).WithLabelValues("user-0")))
+
+	dropped, err := metrics.Registry().Gather()
+	require.NoError(t, err)
+	var count float64
+	for _, family := range dropped {
+		if family.GetName() != "expressops_plugin_metric_dropped_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() == "requests_total" && metric.GetLabel()[1].GetValue() == "cardinality" {
+				count = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, 12.0, count)
+}
+
+// countingPlugin registers a counter in Initialize
+type countingPlugin struct {
+	runs *Counter
+}
+
+func (p *countingPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	var err error
+	p.runs, err = MetricsFromContext(ctx).Counter("runs_total", "Runs of the plugin.")
+	return err
+}
+
+func (p *countingPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	p.runs.Inc()
+	return nil, nil
+}
+
+func (p *countingPlugin) FormatResult(result interface{}) (string, error) {
+	return "", nil
+}
+
+func TestLoadInstancePassesMetrics(t *testing.T) {
+	ctx := context.Background()
+	logger := discardLogger()
+	defer func() {
+		mu.Lock()
+		delete(registry, "metrics-test-plugin")
+		mu.Unlock()
+	}()
+
+	first := &countingPlugin{}
+	require.NoError(t, LoadInstance(ctx, first, "metrics-test-plugin", nil, logger))
+	_, _ = first.Execute(ctx, nil, nil)
+
+	// The reloaded instance keeps counting in the same series
+	second := &countingPlugin{}
+	require.NoError(t, Reload(ctx, second, "metrics-test-plugin", nil, logger))
+	_, _ = second.Execute(ctx, nil, nil)
+
+	count, err := testutil.GatherAndCount(metrics.Registry(), "expressops_plugin_metrics_test_plugin_runs_total")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 2.0, testutil.ToFloat64(second.runs.m.collector))
+}
+
+func TestMetricsFromContextWithoutMetrics(t *testing.T) {
+	counter, err := MetricsFromContext(context.Background()).Counter("runs_total", "")
+	require.NoError(t, err)
+	counter.Inc()
+}
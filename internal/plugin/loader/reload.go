@@ -0,0 +1,169 @@
+package pluginconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotLoaded is returned when reloading a plugin that is not loaded
+var ErrNotLoaded = errors.New("plugin is not loaded")
+
+var (
+	// useMu guards inUse. Acquire looks plugins up and Reload swaps them
+	// while holding it, so an instance is either counted before its swap or
+	// never handed out after it.
+	useMu sync.Mutex
+	// inUse counts the steps running on each plugin instance
+	inUse = make(map[Plugin]int)
+	// drained is signaled whenever the count of an instance drops to zero
+	drained = sync.NewCond(&useMu)
+
+	// opened holds the paths of the .so files opened so far
+	opened = make(map[string]bool)
+)
+
+// Acquire returns the plugin registered under name for one execution. The
+// instance stays valid until release is called, even if the plugin is
+// reloaded in the meantime: steps started before a reload finish on the old
+// instance while new ones get the new instance.
+func Acquire(name string) (Plugin, func(), error) {
+	useMu.Lock()
+	defer useMu.Unlock()
+	p, err := GetPluginFunc(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == nil || !reflect.TypeOf(p).Comparable() {
+		return p, func() {}, nil
+	}
+	inUse[p]++
+
+	var once sync.Once
+	return p, func() { once.Do(func() { releaseInstance(p) }) }, nil
+}
+
+func releaseInstance(p Plugin) {
+	useMu.Lock()
+	defer useMu.Unlock()
+	inUse[p]--
+	if inUse[p] <= 0 {
+		delete(inUse, p)
+		drained.Broadcast()
+	}
+}
+
+// Reload checks config against the manifest of a new instance of the plugin
+// registered under name, initializes it and swaps it into the registry. The
+// old instance keeps serving the steps already running on it and, once they
+// are done, is closed if it implements io.Closer, e.g. a plugin process.
+// When the new instance fails to initialize the old one stays registered.
+func Reload(ctx context.Context, pluginInstance Plugin, name string, config map[string]interface{}, logger *logrus.Logger) error {
+	mu.Lock()
+	_, loaded := registry[name]
+	mu.Unlock()
+	if !loaded {
+		return fmt.Errorf("plugin '%s': %w", name, ErrNotLoaded)
+	}
+
+	manifest := ManifestOf(pluginInstance)
+	// Before anything may log or return the values
+	registerSecrets(manifest, config)
+	if err := manifest.Check(config); err != nil {
+		return fmt.Errorf("plugin '%s': %w", name, err)
+	}
+	ctx = WithMetrics(ctx, metricsFor(name, logger))
+	if err := pluginInstance.Initialize(ctx, config, logger); err != nil {
+		return fmt.Errorf("error initializing plugin: '%s': %w", name, err)
+	}
+
+	useMu.Lock()
+	mu.Lock()
+	old := registry[name]
+	registry[name] = pluginInstance
+	manifests[name] = manifest
+	mu.Unlock()
+	useMu.Unlock()
+
+	if old != pluginInstance {
+		go retire(old, name, logger)
+	}
+	logger.Infof("Plugin '%s' reloaded (version %s)", name, orUnknown(manifest.Version))
+	return nil
+}
+
+// ReloadBuiltin reloads the plugin registered under name with a new
+// instance of a builtin plugin, e.g. to apply a new config
+func ReloadBuiltin(ctx context.Context, builtin string, name string, config map[string]interface{}, logger *logrus.Logger) error {
+	pluginInstance, err := NewBuiltin(builtin)
+	if err != nil {
+		return err
+	}
+	return Reload(ctx, pluginInstance, name, config, logger)
+}
+
+// ReloadPlugin reloads the plugin registered under name from a .so file.
+// Go never unloads a plugin and opening the same file again returns the
+// code already loaded, so a new version must be built to a new path, with a
+// plugin path of its own (-ldflags=-pluginpath=...).
+func ReloadPlugin(ctx context.Context, path string, name string, config map[string]interface{}, logger *logrus.Logger) error {
+	mu.Lock()
+	reopened := opened[path]
+	mu.Unlock()
+	if reopened {
+		logger.Warnf("Plugin '%s': '%s' is already loaded, reloading it applies its config but not new code. Build new versions to a new path.", name, path)
+	}
+
+	pluginInstance, shared, err := openPlugin(path, name)
+	if err != nil {
+		return err
+	}
+	if shared && reopened {
+		logger.Warnf("Plugin '%s': '%s' exports no NewPlugin factory, its running instance is initialized again", name, path)
+	}
+	return Reload(ctx, pluginInstance, name, config, logger)
+}
+
+// retire waits for the steps running on a replaced instance to finish and
+// closes it. Acquire no longer hands it out once it is swapped.
+func retire(old Plugin, name string, logger *logrus.Logger) {
+	if old == nil || !reflect.TypeOf(old).Comparable() {
+		return
+	}
+	closer, ok := unwrap(old).(io.Closer)
+	if !ok {
+		return
+	}
+
+	useMu.Lock()
+	for inUse[old] > 0 {
+		drained.Wait()
+	}
+	useMu.Unlock()
+
+	if err := closer.Close(); err != nil {
+		logger.Warnf("Error closing previous instance of plugin '%s': %v", name, err)
+		return
+	}
+	logger.Debugf("Previous instance of plugin '%s' closed", name)
+}
+
+// unwrap returns the plugin under a manifest added by openPlugin
+func unwrap(p Plugin) Plugin {
+	if w, ok := p.(*withManifest); ok {
+		return w.Plugin
+	}
+	return p
+}
+
+func orUnknown(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
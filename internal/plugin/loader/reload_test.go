@@ -0,0 +1,151 @@
+package pluginconf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closablePlugin stands for a plugin process
+type closablePlugin struct {
+	manifestPlugin
+	closed atomic.Bool
+}
+
+func (p *closablePlugin) Close() error {
+	p.closed.Store(true)
+	return nil
+}
+
+func TestReloadSwapsInstance(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	defer func() {
+		mu.Lock()
+		delete(registry, "hot")
+		delete(manifests, "hot")
+		mu.Unlock()
+	}()
+
+	err := Reload(ctx, &closablePlugin{}, "hot", nil, logger)
+	assert.ErrorIs(t, err, ErrNotLoaded)
+
+	v1 := &closablePlugin{manifestPlugin: manifestPlugin{manifest: Manifest{Version: "1.0.0"}}}
+	require.NoError(t, LoadInstance(ctx, v1, "hot", nil, logger))
+
+	running, release, err := Acquire("hot")
+	require.NoError(t, err)
+	assert.Same(t, v1, running)
+
+	// A failed reload keeps the running version
+	err = Reload(ctx, &closablePlugin{}, "hot", map[string]interface{}{"fail": true}, logger)
+	assert.ErrorContains(t, err, "initialization failed as requested")
+	current, err := GetPlugin("hot")
+	require.NoError(t, err)
+	assert.Same(t, v1, current)
+
+	v2 := &closablePlugin{manifestPlugin: manifestPlugin{manifest: Manifest{Version: "2.0.0"}}}
+	require.NoError(t, Reload(ctx, v2, "hot", nil, logger))
+
+	next, releaseNext, err := Acquire("hot")
+	require.NoError(t, err)
+	assert.Same(t, v2, next, "new executions get the new instance")
+	manifest, _ := GetManifest("hot")
+	assert.Equal(t, "2.0.0", manifest.Version)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, v1.closed.Load(), "the old instance is closed only once its steps are done")
+
+	release()
+	release() // releasing twice is harmless
+	assert.Eventually(t, v1.closed.Load, time.Second, 5*time.Millisecond)
+	releaseNext()
+	assert.False(t, v2.closed.Load())
+}
+
+// usedPlugin fails a step run on it once it is closed
+type usedPlugin struct {
+	closablePlugin
+}
+
+func (p *usedPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	if p.closed.Load() {
+		return nil, fmt.Errorf("ran on a closed instance")
+	}
+	return nil, nil
+}
+
+func TestAcquireDuringReload(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ctx := context.Background()
+	defer func() {
+		mu.Lock()
+		delete(registry, "busy")
+		delete(manifests, "busy")
+		mu.Unlock()
+	}()
+
+	// A slow lookup leaves time for a reload between the lookup of an
+	// instance and its use
+	getPlugin := GetPluginFunc
+	GetPluginFunc = func(name string) (Plugin, error) {
+		p, err := getPlugin(name)
+		time.Sleep(time.Millisecond)
+		return p, err
+	}
+	defer func() { GetPluginFunc = getPlugin }()
+
+	instances := []*usedPlugin{{}}
+	require.NoError(t, LoadInstance(ctx, instances[0], "busy", nil, logger))
+
+	var steps sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		steps.Add(1)
+		go func() {
+			defer steps.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				p, release, err := Acquire("busy")
+				if !assert.NoError(t, err) {
+					return
+				}
+				_, err = p.Execute(ctx, nil, nil)
+				release()
+				if !assert.NoError(t, err) {
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		time.Sleep(time.Millisecond)
+		next := &usedPlugin{}
+		require.NoError(t, Reload(ctx, next, "busy", nil, logger))
+		instances = append(instances, next)
+	}
+	close(stop)
+	steps.Wait()
+
+	// Every replaced instance is closed, the current one is not
+	for _, p := range instances[:len(instances)-1] {
+		assert.Eventually(t, p.closed.Load, time.Second, 5*time.Millisecond)
+	}
+	assert.False(t, instances[len(instances)-1].closed.Load())
+}
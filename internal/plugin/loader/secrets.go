@@ -0,0 +1,46 @@
+package pluginconf
+
+import (
+	"expressops/internal/redact"
+	"expressops/internal/schema"
+)
+
+// RegisterSecret masks values in logs, flow responses, stored executions and
+// traces. Config values are registered by the loader when they are named
+// like a secret or marked `secret:"true"`; plugins register the secrets they
+// obtain otherwise, e.g. read from a file or returned by a token endpoint.
+func RegisterSecret(values ...string) {
+	redact.Default().Add(values...)
+}
+
+// registerSecrets registers the secrets of the config of a plugin: the
+// values of keys named like a secret and of the properties its config
+// schema marks writeOnly
+func registerSecrets(manifest Manifest, config map[string]interface{}) {
+	redact.Default().AddFrom(config)
+	registerSchemaSecrets(manifest.ConfigSchema, config)
+}
+
+func registerSchemaSecrets(s *schema.Schema, value interface{}) {
+	if s == nil {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		if s.WriteOnly {
+			redact.Default().Add(v)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if prop, ok := s.Properties[key]; ok {
+				registerSchemaSecrets(prop, item)
+			} else if values, ok := s.AdditionalProperties.(*schema.Schema); ok {
+				registerSchemaSecrets(values, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			registerSchemaSecrets(s.Items, item)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pluginconf
+
+import (
+	"context"
+	"testing"
+
+	"expressops/internal/redact"
+	"expressops/internal/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadInstanceRegistersSecrets(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		delete(registry, "secret-config")
+		delete(manifests, "secret-config")
+		mu.Unlock()
+	}()
+
+	p := &manifestPlugin{manifest: Manifest{
+		ConfigSchema: schema.Object(map[string]*schema.Schema{
+			"endpoint":    schema.String("").Secret(),
+			"webhook_url": schema.String(""),
+			"channel":     schema.String(""),
+			"headers":     schema.MapOf(schema.String(""), ""),
+		}),
+	}}
+	config := map[string]interface{}{
+		"endpoint":    "https://hooks.example.com/T000/B000",
+		"webhook_url": "https://hooks.slack.com/services/T000/B000/XXXX",
+		"channel":     "#ops-alerts",
+		"headers":     map[string]interface{}{"Authorization": "Bearer abcdef"},
+	}
+	require.NoError(t, LoadInstance(context.Background(), p, "secret-config", config, discardLogger()))
+
+	redactor := redact.Default()
+	assert.Equal(t, redact.Mask, redactor.String("https://hooks.example.com/T000/B000"))
+	assert.Equal(t, redact.Mask, redactor.String("https://hooks.slack.com/services/T000/B000/XXXX"))
+	assert.Equal(t, redact.Mask, redactor.String("Bearer abcdef"))
+	assert.Equal(t, "#ops-alerts", redactor.String("#ops-alerts"))
+}
+
+func TestDecodeRegistersSecrets(t *testing.T) {
+	var cfg struct {
+		User     string `yaml:"user"`
+		Passcode string `yaml:"passcode" secret:"true"`
+	}
+	require.NoError(t, Decode(map[string]interface{}{"user": "deploy-bot", "passcode": "decoded-passcode"}, &cfg))
+
+	assert.Equal(t, redact.Mask, redact.Default().String("decoded-passcode"))
+	assert.Equal(t, "deploy-bot", redact.Default().String("deploy-bot"))
+}
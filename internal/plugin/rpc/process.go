@@ -0,0 +1,473 @@
+// internal/plugin/rpc/process.go
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultCallTimeout bounds a call when the spec sets no timeout
+	defaultCallTimeout = 30 * time.Second
+	// maxRestartBackoff caps the delay between restarts of a crashing process
+	maxRestartBackoff = 30 * time.Second
+	// shutdownGracePeriod is how long Close waits before killing the process
+	shutdownGracePeriod = 2 * time.Second
+)
+
+// Spec describes how to launch a plugin process
+type Spec struct {
+	Command string
+	Args    []string
+	Env     map[string]string
+	Dir     string
+	// CallTimeout bounds every call to the process. Execute is also bounded
+	// by the context of the step.
+	CallTimeout time.Duration
+}
+
+// Process is a plugin running in a child process. It implements the plugin
+// interface: calls are sent one at a time, a process that does not answer in
+// time or whose context is cancelled is killed, and a process that exits is
+// started again, with its config, on the next call.
+type Process struct {
+	name   string
+	spec   Spec
+	logger *logrus.Logger
+	config map[string]interface{}
+
+	// calls holds a token while a call is in flight
+	calls  chan struct{}
+	nextID uint64
+
+	mu       sync.Mutex
+	child    *child
+	restarts int
+	diedAt   time.Time
+	// callLogger receives the lines the process writes during the call in
+	// flight, so that those of an execute call are kept with the execution
+	callLogger *logrus.Entry
+}
+
+// child is one run of the plugin executable
+type child struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	enc       *json.Encoder
+	responses chan *message
+	exited    chan struct{}
+	err       error
+}
+
+// NewProcess returns a plugin that runs spec under name. The process is
+// started by Initialize.
+func NewProcess(name string, spec Spec) *Process {
+	if spec.CallTimeout <= 0 {
+		spec.CallTimeout = defaultCallTimeout
+	}
+	return &Process{
+		name:   name,
+		spec:   spec,
+		logger: logrus.StandardLogger(),
+		calls:  make(chan struct{}, 1),
+	}
+}
+
+// Initialize starts the process, checks its protocol version and sends it
+// the plugin config
+func (p *Process) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	p.logger = logger
+	p.config = config
+
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	defer p.release()
+
+	_, err := p.start(ctx)
+	return err
+}
+
+// Execute sends the request and the shared context to the process and
+// applies the changes it made to the shared context
+func (p *Process) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	params := ExecuteParams{Shared: p.encodableShared(*shared)}
+	if request != nil {
+		params.Request = Request{Method: request.Method, URL: request.URL.String(), Headers: request.Header.Clone()}
+		// the API token is not the plugin's business
+		delete(params.Request.Headers, "Authorization")
+	}
+	// The process continues the trace of the step from a traceparent header
+	if params.Request.Headers == nil {
+		params.Request.Headers = make(map[string][]string)
+	}
+	tracing.Inject(ctx, params.Request.Headers)
+
+	var res ExecuteResult
+	if err := p.call(ctx, MethodExecute, params, &res); err != nil {
+		return nil, err
+	}
+
+	if res.Shared != nil {
+		for k := range params.Shared {
+			if _, kept := res.Shared[k]; !kept {
+				delete(*shared, k)
+			}
+		}
+		for k, v := range res.Shared {
+			(*shared)[k] = v
+		}
+	}
+	return res.Result, nil
+}
+
+// FormatResult asks the process to format a step result
+func (p *Process) FormatResult(result interface{}) (string, error) {
+	var res FormatResultResult
+	if err := p.call(context.Background(), MethodFormatResult, FormatResultParams{Result: result}, &res); err != nil {
+		return "", err
+	}
+	return res.Formatted, nil
+}
+
+// Close asks the process to shut down and kills it if it does not exit in time
+func (p *Process) Close() error {
+	p.mu.Lock()
+	c := p.child
+	p.child = nil
+	p.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	if msg, err := newNotification(MethodShutdown, nil); err == nil {
+		_ = c.enc.Encode(msg)
+	}
+	_ = c.stdin.Close()
+
+	select {
+	case <-c.exited:
+	case <-time.After(shutdownGracePeriod):
+		_ = c.cmd.Process.Kill()
+		<-c.exited
+	}
+	return nil
+}
+
+func (p *Process) acquire(ctx context.Context) error {
+	select {
+	case p.calls <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Process) release() {
+	<-p.calls
+}
+
+// call sends a request to the process, starting it first if needed. What
+// the process logs meanwhile goes to the logger of ctx.
+func (p *Process) call(ctx context.Context, method string, params, out interface{}) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	defer p.release()
+	p.setCallLogger(pluginManager.LoggerFromContext(ctx, p.logger))
+	defer p.setCallLogger(nil)
+
+	c, err := p.running(ctx)
+	if err != nil {
+		return err
+	}
+	if err := p.roundTrip(ctx, c, method, params, out); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.restarts = 0
+	p.mu.Unlock()
+	return nil
+}
+
+// running returns the live child, restarting the process if it died and its
+// restart backoff has elapsed
+func (p *Process) running(ctx context.Context) (*child, error) {
+	p.mu.Lock()
+	c, restarts, diedAt := p.child, p.restarts, p.diedAt
+	p.mu.Unlock()
+
+	if c != nil {
+		select {
+		case <-c.exited:
+			p.died(c, fmt.Sprintf("exited: %v", c.err))
+			restarts++
+		default:
+			return c, nil
+		}
+	}
+
+	if wait := restartBackoff(restarts) - time.Since(diedAt); wait > 0 {
+		return nil, fmt.Errorf("plugin process '%s' is down, next restart in %s", p.name, wait.Round(time.Millisecond))
+	}
+	p.logger.Warnf("Restarting plugin process '%s'", p.name)
+	return p.start(ctx)
+}
+
+// restartBackoff is the delay before the given restart: none for the first
+// one, then doubling from one second
+func restartBackoff(restarts int) time.Duration {
+	if restarts <= 1 {
+		return 0
+	}
+	backoff := time.Second << (restarts - 2)
+	if backoff <= 0 || backoff > maxRestartBackoff {
+		return maxRestartBackoff
+	}
+	return backoff
+}
+
+// start launches the executable, then performs the handshake and initialize calls
+func (p *Process) start(ctx context.Context) (*child, error) {
+	cmd := exec.Command(p.spec.Command, p.spec.Args...)
+	cmd.Dir = p.spec.Dir
+	cmd.Env = os.Environ()
+	for k, v := range p.spec.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		p.died(nil, err.Error())
+		return nil, fmt.Errorf("error starting plugin process '%s': %w", p.name, err)
+	}
+
+	c := &child{
+		cmd:       cmd,
+		stdin:     stdin,
+		enc:       json.NewEncoder(stdin),
+		responses: make(chan *message, 1),
+		exited:    make(chan struct{}),
+	}
+
+	// Wait must only be called once both pipes have been drained
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		p.readMessages(c, stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		p.forwardStderr(stderr)
+	}()
+	go func() {
+		readers.Wait()
+		c.err = cmd.Wait()
+		close(c.exited)
+	}()
+
+	p.mu.Lock()
+	p.child = c
+	p.mu.Unlock()
+
+	var hs HandshakeResult
+	if err := p.roundTrip(ctx, c, MethodHandshake, HandshakeParams{ProtocolVersion: ProtocolVersion}, &hs); err != nil {
+		return nil, fmt.Errorf("handshake with plugin process '%s' failed: %w", p.name, err)
+	}
+	if hs.ProtocolVersion != ProtocolVersion {
+		p.kill(c, "incompatible protocol")
+		return nil, fmt.Errorf("plugin process '%s' speaks protocol version %d, expected %d", p.name, hs.ProtocolVersion, ProtocolVersion)
+	}
+	if err := p.roundTrip(ctx, c, MethodInitialize, InitializeParams{Config: p.config}, nil); err != nil {
+		p.kill(c, "initialize failed")
+		return nil, err
+	}
+
+	p.logger.Infof("Plugin process '%s' started (pid %d)", p.name, cmd.Process.Pid)
+	return c, nil
+}
+
+// roundTrip sends one request and waits for its response
+func (p *Process) roundTrip(ctx context.Context, c *child, method string, params, out interface{}) error {
+	p.nextID++
+	id := p.nextID
+	req, err := newRequest(id, method, params)
+	if err != nil {
+		return err
+	}
+	if err := c.enc.Encode(req); err != nil {
+		p.kill(c, "write failed")
+		return fmt.Errorf("error writing to plugin process '%s': %w", p.name, err)
+	}
+
+	timer := time.NewTimer(p.spec.CallTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-c.responses:
+			if *resp.ID != id {
+				continue // answer to an abandoned call
+			}
+			if resp.Error != nil {
+				return resp.Error
+			}
+			if out != nil && len(resp.Result) > 0 {
+				if err := json.Unmarshal(resp.Result, out); err != nil {
+					return fmt.Errorf("invalid %s response from plugin process '%s': %w", method, p.name, err)
+				}
+			}
+			return nil
+
+		case <-c.exited:
+			p.died(c, fmt.Sprintf("exited: %v", c.err))
+			return fmt.Errorf("plugin process '%s' exited during %s: %v", p.name, method, c.err)
+
+		case <-ctx.Done():
+			p.kill(c, "context cancelled")
+			return ctx.Err()
+
+		case <-timer.C:
+			p.kill(c, "call timed out")
+			return fmt.Errorf("plugin process '%s' did not answer %s within %s", p.name, method, p.spec.CallTimeout)
+		}
+	}
+}
+
+// kill stops a misbehaving child; it is restarted on the next call
+func (p *Process) kill(c *child, reason string) {
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	p.died(c, reason)
+}
+
+// died records that the child is gone so that the next call restarts it
+func (p *Process) died(c *child, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c != nil && p.child != c {
+		return // already recorded
+	}
+	p.child = nil
+	p.restarts++
+	p.diedAt = time.Now()
+	p.logger.Warnf("Plugin process '%s' stopped: %s", p.name, reason)
+}
+
+func (p *Process) setCallLogger(logger *logrus.Entry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.callLogger = logger
+}
+
+// pluginLogger returns the logger of the lines written by the process: that
+// of the call in flight, or the one given to Initialize between calls
+func (p *Process) pluginLogger() *logrus.Entry {
+	p.mu.Lock()
+	logger := p.callLogger
+	p.mu.Unlock()
+	if logger == nil {
+		logger = logrus.NewEntry(p.logger)
+	}
+	return logger.WithField("plugin", p.name)
+}
+
+// readMessages dispatches the responses and log notifications of the child
+func (p *Process) readMessages(c *child, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// stray output, e.g. a script printing to stdout
+			p.pluginLogger().Info(scanner.Text())
+			continue
+		}
+
+		switch {
+		case msg.ID != nil:
+			select {
+			case c.responses <- &msg:
+			default:
+				p.logger.Warnf("Plugin process '%s' sent an unexpected response (id %d)", p.name, *msg.ID)
+			}
+		case msg.Method == MethodLog:
+			p.logNotification(msg.Params)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		p.logger.Warnf("Error reading from plugin process '%s': %v", p.name, err)
+	}
+}
+
+func (p *Process) logNotification(raw json.RawMessage) {
+	var params LogParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return
+	}
+	p.pluginLogger().WithFields(params.Fields).Log(notificationLevel(params.Level), params.Message)
+}
+
+// notificationLevel parses the level of a log line sent by a plugin. Panic
+// and fatal are logged as errors, since logrus would panic or exit the host.
+func notificationLevel(name string) logrus.Level {
+	level, err := logrus.ParseLevel(name)
+	switch {
+	case err != nil:
+		return logrus.InfoLevel
+	case level <= logrus.FatalLevel:
+		return logrus.ErrorLevel
+	}
+	return level
+}
+
+func (p *Process) forwardStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		p.pluginLogger().Warn(scanner.Text())
+	}
+}
+
+// encodableShared returns the entries of the shared context that can be sent
+// as JSON. The others stay in the host and are left untouched.
+func (p *Process) encodableShared(shared map[string]any) map[string]interface{} {
+	out := make(map[string]interface{}, len(shared))
+	for k, v := range shared {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			p.logger.Debugf("Not sending shared key '%s' to plugin process '%s': %v", k, p.name, err)
+			continue
+		}
+		out[k] = json.RawMessage(raw)
+	}
+	return out
+}
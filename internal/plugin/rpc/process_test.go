@@ -0,0 +1,228 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	pluginManager "expressops/internal/plugin/loader"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const childEnv = "EXPRESSOPS_RPC_TEST_PLUGIN"
+
+// TestMain runs the test binary as a plugin process when re-executed by the tests
+func TestMain(m *testing.M) {
+	if os.Getenv(childEnv) == "1" {
+		if err := Serve(os.Stdin, os.Stdout, &echoPlugin{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// echoPlugin behaves according to the `action` query parameter of the request
+type echoPlugin struct {
+	logger   *logrus.Logger
+	greeting string
+}
+
+func (p *echoPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	p.logger = logger
+	p.greeting, _ = config["greeting"].(string)
+	logger.WithField("pid", os.Getpid()).Info("echo initialized")
+	return nil
+}
+
+func (p *echoPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	switch request.URL.Query().Get("action") {
+	case "crash":
+		os.Exit(3)
+	case "hang":
+		select {}
+	case "fail":
+		return nil, errors.New("asked to fail")
+	case "log":
+		p.logger.WithField("user", (*shared)["user"]).Info("creating user")
+	}
+
+	(*shared)["seen"] = true
+	delete(*shared, "drop")
+	return map[string]interface{}{
+		"greeting":      p.greeting,
+		"count":         (*shared)["count"],
+		"authorization": request.Header.Get("Authorization"),
+		"pid":           os.Getpid(),
+	}, nil
+}
+
+func (p *echoPlugin) FormatResult(result interface{}) (string, error) {
+	return fmt.Sprintf("formatted: %v", result), nil
+}
+
+func startEcho(t *testing.T, timeout time.Duration) (*Process, *logtest.Hook) {
+	t.Helper()
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	p := NewProcess("echo", Spec{
+		Command:     os.Args[0],
+		Env:         map[string]string{childEnv: "1"},
+		CallTimeout: timeout,
+	})
+	require.NoError(t, p.Initialize(context.Background(), map[string]interface{}{"greeting": "hello"}, logger))
+	t.Cleanup(func() { _ = p.Close() })
+	return p, hook
+}
+
+func execute(ctx context.Context, p *Process, action string, shared map[string]any) (map[string]interface{}, error) {
+	req, _ := http.NewRequest(http.MethodGet, "/flow?action="+action, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res, err := p.Execute(ctx, req, &shared)
+	if err != nil {
+		return nil, err
+	}
+	return res.(map[string]interface{}), nil
+}
+
+func TestProcessExecute(t *testing.T) {
+	p, hook := startEcho(t, 0)
+
+	shared := map[string]any{"count": 2, "drop": "me", "chan": make(chan int)}
+	req, _ := http.NewRequest(http.MethodGet, "/flow?action=echo", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res, err := p.Execute(context.Background(), req, &shared)
+	require.NoError(t, err)
+
+	result := res.(map[string]interface{})
+	assert.Equal(t, "hello", result["greeting"])
+	assert.Equal(t, float64(2), result["count"])
+	assert.Empty(t, result["authorization"], "the API token must not reach the plugin")
+
+	assert.Equal(t, true, shared["seen"])
+	assert.NotContains(t, shared, "drop")
+	assert.Contains(t, shared, "chan", "values that cannot be encoded stay in the host")
+
+	formatted, err := p.FormatResult("ok")
+	require.NoError(t, err)
+	assert.Equal(t, "formatted: ok", formatted)
+
+	var initialized *logrus.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "echo initialized" {
+			initialized = entry
+		}
+	}
+	require.NotNil(t, initialized, "log notifications are forwarded to the host logger")
+	assert.Equal(t, "echo", initialized.Data["plugin"])
+	assert.Contains(t, initialized.Data, "pid")
+}
+
+func TestProcessPluginError(t *testing.T) {
+	p, _ := startEcho(t, 0)
+
+	_, err := execute(context.Background(), p, "fail", map[string]any{})
+	assert.EqualError(t, err, "asked to fail")
+
+	_, err = execute(context.Background(), p, "echo", map[string]any{})
+	assert.NoError(t, err, "a plugin error does not stop the process")
+}
+
+func TestProcessRestartsAfterCrash(t *testing.T) {
+	p, _ := startEcho(t, 0)
+
+	first, err := execute(context.Background(), p, "echo", map[string]any{})
+	require.NoError(t, err)
+
+	_, err = execute(context.Background(), p, "crash", map[string]any{})
+	assert.ErrorContains(t, err, "exited during execute")
+
+	second, err := execute(context.Background(), p, "echo", map[string]any{})
+	require.NoError(t, err)
+	assert.NotEqual(t, first["pid"], second["pid"])
+	assert.Equal(t, "hello", second["greeting"], "the restarted process is initialized again")
+}
+
+func TestProcessKilledOnCancel(t *testing.T) {
+	p, _ := startEcho(t, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := execute(ctx, p, "hang", map[string]any{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = execute(context.Background(), p, "echo", map[string]any{})
+	assert.NoError(t, err)
+}
+
+func TestProcessCallTimeout(t *testing.T) {
+	p, _ := startEcho(t, 200*time.Millisecond)
+
+	_, err := execute(context.Background(), p, "hang", map[string]any{})
+	assert.ErrorContains(t, err, "did not answer execute within 200ms")
+}
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, time.Duration(0), restartBackoff(1))
+	assert.Equal(t, time.Second, restartBackoff(2))
+	assert.Equal(t, 4*time.Second, restartBackoff(4))
+	assert.Equal(t, maxRestartBackoff, restartBackoff(10))
+	assert.Equal(t, maxRestartBackoff, restartBackoff(100))
+}
+
+func TestProcessLogsToExecution(t *testing.T) {
+	p, hook := startEcho(t, 0)
+
+	execLogger, captured := logtest.NewNullLogger()
+	ctx := pluginManager.WithLogger(context.Background(), execLogger.WithField("execution_id", "e1"))
+	_, err := execute(ctx, p, "log", map[string]any{"user": "alice"})
+	require.NoError(t, err)
+
+	// The lines of an execute call are kept with the execution
+	var line *logrus.Entry
+	for _, entry := range captured.AllEntries() {
+		if entry.Message == "creating user" {
+			line = entry
+		}
+	}
+	require.NotNil(t, line, "the plugin log line reaches the logger of the execution")
+	assert.Equal(t, "e1", line.Data["execution_id"])
+	assert.Equal(t, "echo", line.Data["plugin"])
+	assert.Equal(t, "alice", line.Data["user"])
+	for _, entry := range hook.AllEntries() {
+		assert.NotEqual(t, "creating user", entry.Message)
+	}
+
+	// Outside an execution, lines go to the logger given to Initialize
+	_, err = execute(context.Background(), p, "log", map[string]any{"user": "bob"})
+	require.NoError(t, err)
+	assert.Equal(t, "creating user", hook.LastEntry().Message)
+}
+
+func TestProcessLogLevelClamped(t *testing.T) {
+	p, hook := startEcho(t, 0)
+
+	levels := map[string]logrus.Level{
+		"panic":   logrus.ErrorLevel,
+		"fatal":   logrus.ErrorLevel,
+		"warning": logrus.WarnLevel,
+		"loud":    logrus.InfoLevel,
+	}
+	for name, expected := range levels {
+		raw := json.RawMessage(fmt.Sprintf(`{"level":%q,"message":"line at %s"}`, name, name))
+		require.NotPanics(t, func() { p.logNotification(raw) }, name)
+		assert.Equal(t, expected, hook.LastEntry().Level, name)
+		assert.Equal(t, "line at "+name, hook.LastEntry().Message)
+	}
+}
@@ -0,0 +1,134 @@
+// Package rpc runs plugins out of process. The host launches an executable
+// and talks to it with JSON-RPC 2.0 messages, one JSON document per line,
+// over the stdin and stdout of the child. Anything the child writes to stderr
+// is forwarded to the server log.
+//
+// The methods mirror the plugin interface:
+//
+//	handshake     {"protocolVersion": 1}                 -> {"protocolVersion": 1}
+//	initialize    {"config": {...}}                      -> null
+//	execute       {"request": {...}, "shared": {...}}    -> {"result": any, "shared": {...}}
+//	formatResult  {"result": any}                        -> {"formatted": "..."}
+//	shutdown      (notification, no response)
+//
+// The child may send `log` notifications ({"level", "message", "fields"})
+// at any time. Plugins written in Go can use Serve to implement the protocol.
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the version of the protocol spoken by this package.
+// It is negotiated by the handshake and bumped on incompatible changes.
+const ProtocolVersion = 1
+
+// JSON-RPC method names
+const (
+	MethodHandshake    = "handshake"
+	MethodInitialize   = "initialize"
+	MethodExecute      = "execute"
+	MethodFormatResult = "formatResult"
+	MethodShutdown     = "shutdown"
+	MethodLog          = "log"
+)
+
+// maxMessageSize bounds a single JSON-RPC message
+const maxMessageSize = 16 << 20
+
+// errCodePlugin is the JSON-RPC error code of errors returned by the plugin
+const errCodePlugin = -32000
+
+// message is any JSON-RPC 2.0 request, response or notification
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *uint64         `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error returned by the plugin process
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// HandshakeParams and HandshakeResult negotiate the protocol version
+type HandshakeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+type HandshakeResult struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// InitializeParams carries the `config` block of the plugin entry
+type InitializeParams struct {
+	Config map[string]interface{} `json:"config"`
+}
+
+// Request is the part of the triggering HTTP request sent to the plugin
+type Request struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+// ExecuteParams carries the request and the shared context of the step
+type ExecuteParams struct {
+	Request Request                `json:"request"`
+	Shared  map[string]interface{} `json:"shared"`
+}
+
+// ExecuteResult is the step result and the shared context as the plugin left
+// it. Keys missing from Shared are deleted from the shared context.
+type ExecuteResult struct {
+	Result interface{}            `json:"result"`
+	Shared map[string]interface{} `json:"shared,omitempty"`
+}
+
+// FormatResultParams and FormatResultResult format a step result for logs
+type FormatResultParams struct {
+	Result interface{} `json:"result"`
+}
+
+type FormatResultResult struct {
+	Formatted string `json:"formatted"`
+}
+
+// LogParams is a log line emitted by the plugin process
+type LogParams struct {
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// newRequest returns a request expecting a response with the given id
+func newRequest(id uint64, method string, params interface{}) (*message, error) {
+	msg, err := newNotification(method, params)
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = &id
+	return msg, nil
+}
+
+// newNotification returns a message that expects no response
+func newNotification(method string, params interface{}) (*message, error) {
+	msg := &message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s params: %w", method, err)
+		}
+		msg.Params = raw
+	}
+	return msg, nil
+}
@@ -0,0 +1,158 @@
+// internal/plugin/rpc/serve.go
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"expressops/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Plugin is the interface served by Serve, identical to the in-process one
+type Plugin interface {
+	Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error
+	Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error)
+	FormatResult(result interface{}) (string, error)
+}
+
+// Serve implements the plugin side of the protocol for a Go plugin: it reads
+// requests from r, typically os.Stdin, and writes responses to w, typically
+// os.Stdout, until a shutdown notification or the end of r. The logger given
+// to Initialize sends its entries to the host as log notifications.
+func Serve(r io.Reader, w io.Writer, p Plugin) error {
+	out := &messageWriter{enc: json.NewEncoder(w)}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(&notificationHook{out: out})
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var req message
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		if req.Method == MethodShutdown {
+			return nil
+		}
+		if req.ID == nil {
+			continue // unknown notification
+		}
+
+		result, err := dispatch(p, logger, req.Method, req.Params)
+		resp := &message{JSONRPC: "2.0", ID: req.ID}
+		if err != nil {
+			resp.Error = &Error{Code: errCodePlugin, Message: err.Error()}
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			resp.Result = nil
+			resp.Error = &Error{Code: errCodePlugin, Message: fmt.Sprintf("error encoding result: %v", err)}
+		}
+		if err := out.write(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func dispatch(p Plugin, logger *logrus.Logger, method string, raw json.RawMessage) (interface{}, error) {
+	ctx := context.Background()
+	switch method {
+	case MethodHandshake:
+		return HandshakeResult{ProtocolVersion: ProtocolVersion}, nil
+
+	case MethodInitialize:
+		var params InitializeParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, err
+		}
+		return nil, p.Initialize(ctx, params.Config, logger)
+
+	case MethodExecute:
+		var params ExecuteParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, params.Request.Method, params.Request.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, values := range params.Request.Headers {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+		ctx = tracing.Extract(ctx, req.Header)
+		req = req.WithContext(ctx)
+		shared := params.Shared
+		if shared == nil {
+			shared = make(map[string]any)
+		}
+		res, err := p.Execute(ctx, req, &shared)
+		if err != nil {
+			return nil, err
+		}
+		return ExecuteResult{Result: res, Shared: shared}, nil
+
+	case MethodFormatResult:
+		var params FormatResultParams
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, err
+		}
+		formatted, err := p.FormatResult(params.Result)
+		if err != nil {
+			return nil, err
+		}
+		return FormatResultResult{Formatted: formatted}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown method '%s'", method)
+	}
+}
+
+// messageWriter serializes writes of responses and log notifications
+type messageWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *messageWriter) write(msg *message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(msg)
+}
+
+// notificationHook turns logrus entries into log notifications
+type notificationHook struct {
+	out *messageWriter
+}
+
+func (h *notificationHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *notificationHook) Fire(entry *logrus.Entry) error {
+	params := LogParams{Level: entry.Level.String(), Message: entry.Message}
+	if len(entry.Data) > 0 {
+		params.Fields = make(map[string]interface{}, len(entry.Data))
+		for k, v := range entry.Data {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			params.Fields[k] = v
+		}
+	}
+	msg, err := newNotification(MethodLog, params)
+	if err != nil {
+		return err
+	}
+	return h.out.write(msg)
+}
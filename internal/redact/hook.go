@@ -0,0 +1,43 @@
+package redact
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Hook returns a logrus hook masking the secrets of r in the message and
+// fields of every line. Hooks run in the order they were added and before
+// the line is written, so it must be added before any hook keeping lines.
+func (r *Redactor) Hook() logrus.Hook {
+	return hook{r}
+}
+
+type hook struct {
+	r *Redactor
+}
+
+func (h hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h hook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.r.String(entry.Message)
+	if len(entry.Data) > 0 {
+		// Data may be shared with the entry the line was logged from, so
+		// replace it rather than masking in place
+		data := make(logrus.Fields, len(entry.Data))
+		for key, value := range entry.Data {
+			data[key] = h.r.field(key, value)
+		}
+		entry.Data = data
+	}
+	return nil
+}
+
+// field masks a log field, keeping the values that hold no text as they are
+// so that formatters still render them natively
+func (r *Redactor) field(key string, value interface{}) interface{} {
+	if r.SecretKey(key) && value != nil && value != "" {
+		return Mask
+	}
+	return r.Value(value)
+}
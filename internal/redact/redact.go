@@ -0,0 +1,301 @@
+// Package redact masks secrets, such as the webhook URL of a plugin or a
+// password passed to a flow, in what ExpressOps outputs: logs, flow
+// responses, stored executions and traces.
+//
+// A Redactor knows three kinds of secrets: values registered with Add, the
+// values of fields and parameters whose name looks like a secret (password,
+// token, webhook_url, ...), and matches of the patterns given in the config.
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Mask replaces every secret
+const Mask = "[REDACTED]"
+
+// minSecretLength is the length below which registered values are ignored.
+// Masking every occurrence of a value such as "1" would garble the output
+// without protecting anything.
+const minSecretLength = 4
+
+// secretKeyRE matches the names of fields and parameters holding a secret
+var secretKeyRE = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api[_-]?key|authorization|credential|private[_-]?key|webhook)`)
+
+// generation is bumped whenever a secret is added to any redactor, so that
+// the redactors below it rebuild their replacer
+var generation atomic.Uint64
+
+// Redactor masks secrets in strings and values. A redactor made with a
+// parent also masks the secrets of its parent.
+type Redactor struct {
+	parent *Redactor
+
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	keys     []*regexp.Regexp
+	patterns []*regexp.Regexp
+
+	// replacer masks the secrets of the redactor and its parents, as of
+	// builtAt
+	replacer *strings.Replacer
+	builtAt  uint64
+}
+
+var defaultRedactor = New(nil)
+
+// Default returns the redactor of the process, holding the secrets of the
+// configuration
+func Default() *Redactor {
+	return defaultRedactor
+}
+
+// New returns a redactor masking the secrets of parent, if any, along with
+// its own
+func New(parent *Redactor) *Redactor {
+	return &Redactor{parent: parent, secrets: make(map[string]bool)}
+}
+
+// Add registers secret values. Values shorter than 4 characters are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if len(secret) >= minSecretLength && !r.secrets[secret] {
+			r.secrets[secret] = true
+			generation.Add(1)
+		}
+	}
+}
+
+// AddKeys registers regular expressions matching the names of further
+// fields and parameters holding secrets
+func (r *Redactor) AddKeys(exprs ...string) error {
+	compiled, err := compile(exprs)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, compiled...)
+	return nil
+}
+
+// AddPatterns registers regular expressions whose matches are masked
+// wherever they appear
+func (r *Redactor) AddPatterns(exprs ...string) error {
+	compiled, err := compile(exprs)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns = append(r.patterns, compiled...)
+	return nil
+}
+
+func compile(exprs []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern '%s': %w", expr, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// SecretKey reports whether a field or parameter named key holds a secret.
+// Keys naming a file, such as password_file, do not.
+func (r *Redactor) SecretKey(key string) bool {
+	if strings.HasSuffix(strings.ToLower(key), "_file") {
+		return false
+	}
+	if secretKeyRE.MatchString(key) {
+		return true
+	}
+	for ; r != nil; r = r.parent {
+		r.mu.RLock()
+		keys := r.keys
+		r.mu.RUnlock()
+		for _, re := range keys {
+			if re.MatchString(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AddFrom registers the string values of the secret keys of values, at any
+// depth, e.g. the config of a plugin or the parameters of an execution
+func (r *Redactor) AddFrom(values map[string]interface{}) {
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			if r.SecretKey(key) {
+				r.Add(v)
+			}
+		case map[string]interface{}:
+			r.AddFrom(v)
+		case map[string]string:
+			for k, s := range v {
+				if r.SecretKey(k) {
+					r.Add(s)
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					r.AddFrom(m)
+				}
+			}
+		}
+	}
+}
+
+// String returns s with every secret masked
+func (r *Redactor) String(s string) string {
+	if s == "" {
+		return s
+	}
+	s = r.currentReplacer().Replace(s)
+	for red := r; red != nil; red = red.parent {
+		red.mu.RLock()
+		patterns := red.patterns
+		red.mu.RUnlock()
+		for _, re := range patterns {
+			s = re.ReplaceAllString(s, Mask)
+		}
+	}
+	return s
+}
+
+// currentReplacer returns the replacer of the secrets of r and its parents,
+// rebuilt when any was added since it was last built
+func (r *Redactor) currentReplacer() *strings.Replacer {
+	gen := generation.Load()
+	r.mu.RLock()
+	replacer, builtAt := r.replacer, r.builtAt
+	r.mu.RUnlock()
+	if replacer != nil && builtAt == gen {
+		return replacer
+	}
+
+	var secrets []string
+	for red := r; red != nil; red = red.parent {
+		red.mu.RLock()
+		for secret := range red.secrets {
+			secrets = append(secrets, secret)
+		}
+		red.mu.RUnlock()
+	}
+	// The replacer tries the secrets in order, so a secret containing
+	// another must come first for none of it to show
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, Mask)
+	}
+	replacer = strings.NewReplacer(pairs...)
+
+	r.mu.Lock()
+	r.replacer, r.builtAt = replacer, gen
+	r.mu.Unlock()
+	return replacer
+}
+
+// Value returns a copy of v with every secret masked, and with the whole
+// value of secret keys masked. Maps, slices and strings are walked; other
+// values, such as the structs a plugin may return, are walked as they
+// would be encoded to JSON.
+func (r *Redactor) Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	case string:
+		return r.String(v)
+	case error:
+		return r.String(v.Error())
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if r.SecretKey(key) && value != nil && value != "" {
+				out[key] = Mask
+				continue
+			}
+			out[key] = r.Value(value)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for key, value := range v {
+			if r.SecretKey(key) && value != "" {
+				out[key] = Mask
+				continue
+			}
+			out[key] = r.String(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.Value(item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = r.String(item)
+		}
+		return out
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return r.String(fmt.Sprint(v))
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return r.String(string(data))
+	}
+	return r.Value(decoded)
+}
+
+// Map is Value for the common case of a map, e.g. step parameters
+func (r *Redactor) Map(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	return r.Value(m).(map[string]interface{})
+}
+
+type redactorKey struct{}
+
+// WithRedactor returns ctx carrying the redactor of an execution
+func WithRedactor(ctx context.Context, r *Redactor) context.Context {
+	return context.WithValue(ctx, redactorKey{}, r)
+}
+
+// FromContext returns the redactor of the execution ctx belongs to, or the
+// default one
+func FromContext(ctx context.Context) *Redactor {
+	if r, ok := ctx.Value(redactorKey{}).(*Redactor); ok {
+		return r
+	}
+	return defaultRedactor
+}
@@ -0,0 +1,124 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	parent := New(nil)
+	parent.Add("hunter2-secret", "abc", "  ")
+	child := New(parent)
+	child.Add("hunter2")
+
+	// The longer secret of the parent wins over the one of the child it
+	// contains, so that none of it shows
+	assert.Equal(t, "password is [REDACTED]", child.String("password is hunter2-secret"))
+	assert.Equal(t, "[REDACTED] and [REDACTED]", child.String("hunter2 and hunter2-secret"))
+	// Short values are not registered
+	assert.Equal(t, "abc", child.String("abc"))
+	// The parent does not know the secrets of the child
+	assert.Equal(t, "hunter2", parent.String("hunter2"))
+
+	// Secrets added after a first use are masked too
+	assert.Equal(t, "late-secret", child.String("late-secret"))
+	parent.Add("late-secret")
+	assert.Equal(t, Mask, child.String("late-secret"))
+}
+
+func TestPatternsAndKeys(t *testing.T) {
+	r := New(nil)
+	require.NoError(t, r.AddPatterns(`xox[abp]-[0-9A-Za-z-]+`))
+	require.NoError(t, r.AddKeys(`^ssn$`))
+	assert.ErrorContains(t, r.AddPatterns(`(`), "invalid redaction pattern")
+
+	assert.Equal(t, "token [REDACTED] posted", r.String("token xoxb-123-abc posted"))
+
+	for key, secret := range map[string]bool{
+		"password":      true,
+		"db_passwd":     true,
+		"API_TOKEN":     true,
+		"webhook_url":   true,
+		"Authorization": true,
+		"ssn":           true,
+		"password_file": false,
+		"username":      false,
+		"ssn_count":     false,
+	} {
+		assert.Equal(t, secret, New(r).SecretKey(key), key)
+	}
+}
+
+type result struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+func TestValue(t *testing.T) {
+	r := New(nil)
+	r.AddFrom(map[string]interface{}{
+		"username": "admin",
+		"auth": map[string]interface{}{
+			"type":     "basic",
+			"password": "s3cr3t-pass",
+		},
+		"env": map[string]string{"API_KEY": "key-12345"},
+	})
+	assert.Equal(t, "admin", r.String("admin"))
+	assert.Equal(t, "basic", r.String("basic"))
+
+	got := r.Value(map[string]interface{}{
+		"output":   "login with s3cr3t-pass",
+		"password": "anything",
+		"count":    3,
+		"items":    []interface{}{"key-12345", true},
+		"lines":    []string{"a", "key-12345"},
+		"struct":   result{Message: "key-12345 used", Token: "t"},
+		"err":      errors.New("denied for s3cr3t-pass"),
+		"empty":    nil,
+	})
+	assert.Equal(t, map[string]interface{}{
+		"output":   "login with [REDACTED]",
+		"password": Mask,
+		"count":    3,
+		"items":    []interface{}{Mask, true},
+		"lines":    []string{"a", Mask},
+		"struct":   map[string]interface{}{"message": "[REDACTED] used", "token": Mask},
+		"err":      "denied for [REDACTED]",
+		"empty":    nil,
+	}, got)
+	assert.Nil(t, r.Map(nil))
+}
+
+func TestHook(t *testing.T) {
+	r := New(nil)
+	r.Add("hook-secret")
+
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(r.Hook())
+
+	entry := logger.WithField("url", "https://example.com/hook-secret")
+	entry.WithField("password", "whatever").WithError(errors.New("hook-secret rejected")).Error("calling hook-secret")
+	entry.Info("again")
+
+	assert.NotContains(t, out.String(), "hook-secret")
+	assert.NotContains(t, out.String(), "whatever")
+	assert.Contains(t, out.String(), `"url":"https://example.com/[REDACTED]"`)
+	// The entry logged from is left as it was
+	assert.Equal(t, "https://example.com/hook-secret", entry.Data["url"])
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, Default(), FromContext(context.Background()))
+	r := New(Default())
+	assert.Same(t, r, FromContext(WithRedactor(context.Background(), r)))
+}
@@ -0,0 +1,59 @@
+// internal/schema/config.go
+package schema
+
+import (
+	"reflect"
+	"sort"
+
+	"expressops/api/v1beta1"
+)
+
+// ConfigSchemaID is the canonical location of the published config schema
+const ConfigSchemaID = "https://raw.githubusercontent.com/expressops-team/expressops/main/docs/schema/config.schema.json"
+
+// ForConfig returns the schema of the ExpressOps configuration file.
+// pluginSchemas maps plugin names to the schema of their `config` block;
+// each one applies to the plugin entry with that name. builtinSchemas does
+// the same for builtin plugins, applying to entries selecting that builtin.
+// Its keys also restrict the values accepted by `builtin`.
+func ForConfig(pluginSchemas, builtinSchemas map[string]*Schema) *Schema {
+	root := FromType(reflect.TypeOf(v1beta1.Config{}))
+	root.Schema = Draft
+	root.ID = ConfigSchemaID
+	root.Title = "ExpressOps configuration"
+
+	pluginItem := root.Properties["plugins"].Items
+	for _, name := range sortedKeys(builtinSchemas) {
+		pluginItem.Properties["builtin"].Enum = append(pluginItem.Properties["builtin"].Enum, name)
+		if builtinSchemas[name] == nil {
+			continue
+		}
+		pluginItem.AllOf = append(pluginItem.AllOf, whenPluginProperty("builtin", name, builtinSchemas[name]))
+	}
+	for _, name := range sortedKeys(pluginSchemas) {
+		pluginItem.AllOf = append(pluginItem.AllOf, whenPluginProperty("name", name, pluginSchemas[name]))
+	}
+	return root
+}
+
+// whenPluginProperty applies config to the plugin entries whose property equals value
+func whenPluginProperty(property, value string, config *Schema) *Schema {
+	return &Schema{
+		If: &Schema{
+			Properties: map[string]*Schema{property: {Const: value}},
+			Required:   []string{property},
+		},
+		Then: &Schema{
+			Properties: map[string]*Schema{"config": config},
+		},
+	}
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,248 @@
+// Package schema provides a small JSON Schema model used to describe the
+// ExpressOps configuration file and the config blocks of plugins
+package schema
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect produced by this package
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used by ExpressOps
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // bool or *Schema
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"` // a secret, masked in every output
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	If                   *Schema            `json:"if,omitempty"`
+	Then                 *Schema            `json:"then,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// String returns a string schema
+func String(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+// Number returns a number schema
+func Number(description string) *Schema {
+	return &Schema{Type: "number", Description: description}
+}
+
+// Integer returns an integer schema
+func Integer(description string) *Schema {
+	return &Schema{Type: "integer", Description: description}
+}
+
+// Boolean returns a boolean schema
+func Boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+// Array returns an array schema whose elements match items
+func Array(items *Schema, description string) *Schema {
+	return &Schema{Type: "array", Items: items, Description: description}
+}
+
+// Object returns a closed object schema with the given properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: false,
+	}
+}
+
+// MapOf returns an object schema whose values all match values
+func MapOf(values *Schema, description string) *Schema {
+	return &Schema{Type: "object", AdditionalProperties: values, Description: description}
+}
+
+// WithDefault sets the default value of the schema and returns it
+func (s *Schema) WithDefault(v interface{}) *Schema {
+	s.Default = v
+	return s
+}
+
+// WithEnum restricts the schema to the given values and returns it
+func (s *Schema) WithEnum(values ...interface{}) *Schema {
+	s.Enum = values
+	return s
+}
+
+// Secret marks the value as a secret and returns the schema
+func (s *Schema) Secret() *Schema {
+	s.WriteOnly = true
+	return s
+}
+
+// WithRange sets inclusive bounds on a numeric schema and returns it
+func (s *Schema) WithRange(min, max float64) *Schema {
+	s.Minimum, s.Maximum = &min, &max
+	return s
+}
+
+// FromType builds a schema from a Go type using its yaml struct tags.
+// Struct fields may also carry `required:"true"`, `default:"..."`,
+// `enum:"a,b"`, `secret:"true"`, `description:"..."`, `minimum:"0"` and
+// `maximum:"100"` tags. The default of a list is written comma-separated, as
+// pluginconf.Decode reads it. The bounds of a list or a map apply to its
+// values.
+func FromType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return &Schema{Type: "string", Description: "duration, e.g. 30s"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return fromStruct(t)
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return &Schema{Type: "object"}
+		}
+		return &Schema{Type: "object", AdditionalProperties: FromType(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: FromType(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		// interface{} and anything else accepts any value
+		return &Schema{}
+	}
+}
+
+func fromStruct(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := FieldName(field)
+		if name == "-" {
+			continue
+		}
+		if inline {
+			embedded := fromStruct(field.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		prop := FromType(field.Type)
+		if description := field.Tag.Get("description"); description != "" {
+			prop.Description = description
+		}
+		if def := field.Tag.Get("default"); def != "" {
+			prop.Default = parseDefault(def, prop)
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			for _, v := range strings.Split(enum, ",") {
+				prop.Enum = append(prop.Enum, parseScalar(v, prop.Type))
+			}
+		}
+		if field.Tag.Get("required") == "true" {
+			s.Required = append(s.Required, name)
+		}
+		bounded := prop
+		if prop.Items != nil {
+			bounded = prop.Items
+		} else if values, ok := prop.AdditionalProperties.(*Schema); ok {
+			bounded = values
+		}
+		if min, err := strconv.ParseFloat(field.Tag.Get("minimum"), 64); err == nil {
+			bounded.Minimum = &min
+		}
+		if max, err := strconv.ParseFloat(field.Tag.Get("maximum"), 64); err == nil {
+			bounded.Maximum = &max
+		}
+		if field.Tag.Get("secret") == "true" {
+			prop.WriteOnly = true
+		}
+		s.Properties[name] = prop
+	}
+
+	sort.Strings(s.Required)
+	return s
+}
+
+// FieldName returns the key of a struct field in YAML and whether it is
+// inlined
+func FieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			return "", true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], false
+	}
+	// yaml.v3 lowercases untagged field names
+	return strings.ToLower(field.Name), false
+}
+
+func parseDefault(def string, prop *Schema) interface{} {
+	if prop.Type != "array" || prop.Items == nil {
+		return parseScalar(def, prop.Type)
+	}
+	items := make([]interface{}, 0)
+	for _, item := range strings.Split(def, ",") {
+		items = append(items, parseScalar(item, prop.Items.Type))
+	}
+	return items
+}
+
+func parseScalar(v, typ string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sampleConfig struct {
+	Name    string            `yaml:"name" required:"true"`
+	Port    int               `yaml:"port" default:"8080" description:"port to listen on"`
+	Retries []int             `yaml:"retries" default:"1,5" maximum:"10"`
+	Ratio   float64           `yaml:"ratio" minimum:"0" maximum:"1"`
+	Format  string            `yaml:"format" enum:"text,json"`
+	Token   string            `yaml:"token" secret:"true"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+	Extra   map[string]interface{}
+	Skipped string `yaml:"-"`
+	hidden  string
+}
+
+func TestFromType(t *testing.T) {
+	s := FromType(reflect.TypeOf(sampleConfig{}))
+
+	assert.Equal(t, "object", s.Type)
+	assert.Equal(t, false, s.AdditionalProperties)
+	assert.Equal(t, []string{"name"}, s.Required)
+	assert.Equal(t, "integer", s.Properties["port"].Type)
+	assert.Equal(t, 8080, s.Properties["port"].Default)
+	assert.Equal(t, "port to listen on", s.Properties["port"].Description)
+	assert.Equal(t, []interface{}{1, 5}, s.Properties["retries"].Default)
+	assert.Equal(t, 10.0, *s.Properties["retries"].Items.Maximum)
+	assert.Equal(t, 0.0, *s.Properties["ratio"].Minimum)
+	assert.Equal(t, 1.0, *s.Properties["ratio"].Maximum)
+	assert.Equal(t, []interface{}{"text", "json"}, s.Properties["format"].Enum)
+	assert.True(t, s.Properties["token"].WriteOnly)
+	assert.False(t, s.Properties["name"].WriteOnly)
+	assert.Equal(t, &Schema{Type: "string"}, s.Properties["labels"].AdditionalProperties)
+	assert.Equal(t, "object", s.Properties["extra"].Type)
+	assert.NotContains(t, s.Properties, "-")
+	assert.NotContains(t, s.Properties, "skipped")
+	assert.NotContains(t, s.Properties, "hidden")
+}
+
+func TestForConfigAddsPluginSchemas(t *testing.T) {
+	slack := Object(map[string]*Schema{"webhook_url": String("")}, "webhook_url")
+	root := ForConfig(map[string]*Schema{"slack-notifier": slack}, map[string]*Schema{"slack": slack, "sleep": nil})
+
+	assert.Equal(t, Draft, root.Schema)
+	pluginItem := root.Properties["plugins"].Items
+	assert.Equal(t, []interface{}{"slack", "sleep"}, pluginItem.Properties["builtin"].Enum)
+	require.Len(t, pluginItem.AllOf, 2)
+	assert.Equal(t, "slack", pluginItem.AllOf[0].If.Properties["builtin"].Const)
+	assert.Equal(t, "slack-notifier", pluginItem.AllOf[1].If.Properties["name"].Const)
+	assert.Same(t, slack, pluginItem.AllOf[1].Then.Properties["config"])
+
+	data, err := json.Marshal(root)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"additionalProperties":false`)
+}
+
+func TestValidate(t *testing.T) {
+	s := Object(map[string]*Schema{
+		"url":     String("").WithEnum("a", "b"),
+		"retries": Integer("").WithRange(0, 5),
+		"ratio":   Number(""),
+		"labels":  MapOf(String(""), ""),
+	}, "url")
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{"valid", map[string]interface{}{"url": "a", "retries": 3, "ratio": 1}, ""},
+		{"null optional", map[string]interface{}{"url": "a", "retries": nil}, ""},
+		{"not an object", "a", "(root): expected object, got string"},
+		{"missing required", map[string]interface{}{}, "(root): missing required property 'url'"},
+		{"null required", map[string]interface{}{"url": nil}, "(root): missing required property 'url'"},
+		{"not in enum", map[string]interface{}{"url": "c"}, "url: must be one of [a b]"},
+		{"out of range", map[string]interface{}{"url": "a", "retries": 9}, "retries: must be at most 5"},
+		{"not an integer", map[string]interface{}{"url": "a", "retries": 1.5}, "retries: expected integer, got number"},
+		{"wrong map value", map[string]interface{}{"url": "a", "labels": map[string]interface{}{"x": 1}}, "labels.x: expected string, got number"},
+		{"unknown property", map[string]interface{}{"url": "a", "ulr": "a"}, "ulr: unknown property (known: labels, ratio, retries, url)"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.Validate(tc.value)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
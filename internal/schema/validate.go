@@ -0,0 +1,198 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Validate checks a decoded YAML or JSON value against the schema and
+// returns every violation found, each prefixed with its path in value
+func (s *Schema) Validate(value interface{}) error {
+	return errors.Join(s.validate("", value)...)
+}
+
+func (s *Schema) validate(path string, value interface{}) []error {
+	if s == nil {
+		return nil
+	}
+
+	if s.Type != "" && !hasType(value, s.Type) {
+		return []error{fmt.Errorf("%s: expected %s, got %s", displayPath(path), s.Type, typeName(value))}
+	}
+
+	var errs []error
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		errs = append(errs, fmt.Errorf("%s: must be one of %v", displayPath(path), s.Enum))
+	}
+	if s.Const != nil && !equal(s.Const, value) {
+		errs = append(errs, fmt.Errorf("%s: must be %v", displayPath(path), s.Const))
+	}
+	if n, ok := toFloat(value); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			errs = append(errs, fmt.Errorf("%s: must be at least %v", displayPath(path), *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			errs = append(errs, fmt.Errorf("%s: must be at most %v", displayPath(path), *s.Maximum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, s.validateObject(path, v)...)
+	case []interface{}:
+		for i, item := range v {
+			errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		errs = append(errs, sub.validate(path, value)...)
+	}
+	if s.If != nil && len(s.If.validate(path, value)) == 0 {
+		errs = append(errs, s.Then.validate(path, value)...)
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}) []error {
+	var errs []error
+	// A property without a value, e.g. `url:` in YAML, counts as missing,
+	// as pluginconf.Decode reads it
+	for _, name := range s.Required {
+		if obj[name] == nil {
+			errs = append(errs, fmt.Errorf("%s: missing required property '%s'", displayPath(path), name))
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := joinPath(path, k)
+		if prop, ok := s.Properties[k]; ok {
+			if obj[k] != nil {
+				errs = append(errs, prop.validate(child, obj[k])...)
+			}
+			continue
+		}
+		switch additional := s.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, fmt.Errorf("%s: unknown property%s", displayPath(child), suggestion(s.Properties)))
+			}
+		case *Schema:
+			errs = append(errs, additional.validate(child, obj[k])...)
+		}
+	}
+	return errs
+}
+
+func suggestion(properties map[string]*Schema) string {
+	if len(properties) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" (known: %s)", strings.Join(names, ", "))
+}
+
+func hasType(value interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// toFloat converts the numeric types produced by the YAML and JSON decoders
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares values, numbers by value whatever their Go type
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
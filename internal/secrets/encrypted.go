@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyEnv is the environment variable holding the key of the encrypted file
+// when no key file is configured
+const KeyEnv = "EXPRESSOPS_SECRETS_KEY"
+
+// keySize is the size of an AES-256 key
+const keySize = 32
+
+// encryptedRE matches a value encrypted with EncryptValue. The format is the
+// one sops uses for the values of a file.
+var encryptedRE = regexp.MustCompile(`^ENC\[AES256_GCM,data:([A-Za-z0-9+/=]*),iv:([A-Za-z0-9+/=]+),tag:([A-Za-z0-9+/=]+),type:str\]$`)
+
+// NewKey returns a random key, encoded as kept in a key file
+func NewKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes the key in the content of a key file. Empty lines and
+// lines starting with # are ignored.
+func ParseKey(text string) ([]byte, error) {
+	var encoded string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if encoded != "" {
+			return nil, errors.New("key file holds more than one key")
+		}
+		encoded = line
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("not a key made by `expressops secrets keygen`: expected %d base64 encoded bytes", keySize)
+	}
+	return key, nil
+}
+
+// EncryptValue encrypts value with AES-256-GCM. The name and key of the
+// secret are authenticated along with it, so that an encrypted value moved
+// to another entry of the file fails to decrypt.
+func EncryptValue(key []byte, name, secretKey, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, []byte(value), additionalData(name, secretKey))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	enc := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]", enc(data), enc(iv), enc(tag)), nil
+}
+
+// DecryptValue decrypts a value made by EncryptValue for the same name and
+// key
+func DecryptValue(key []byte, name, secretKey, encrypted string) (string, error) {
+	m := encryptedRE.FindStringSubmatch(strings.TrimSpace(encrypted))
+	if m == nil {
+		return "", errors.New("value is not encrypted, encrypt it with `expressops secrets encrypt`")
+	}
+	var parts [3][]byte
+	for i := range parts {
+		decoded, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return "", fmt.Errorf("malformed encrypted value: %w", err)
+		}
+		parts[i] = decoded
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(iv) != gcm.NonceSize() || len(tag) != gcm.Overhead() {
+		return "", errors.New("malformed encrypted value: wrong iv or tag size")
+	}
+	plain, err := gcm.Open(nil, iv, append(data, tag...), additionalData(name, secretKey))
+	if err != nil {
+		return "", errors.New("cannot decrypt: wrong key, or the value was encrypted for another entry")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes", keySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData is the path of a value in the file, as sops writes it
+func additionalData(name, secretKey string) []byte {
+	if secretKey == "" {
+		return []byte(name + ":")
+	}
+	return []byte(name + ":" + secretKey + ":")
+}
+
+type encryptedFileProvider struct {
+	path    string
+	keyFile string
+}
+
+// NewEncryptedFileProvider returns the provider reading the YAML file at
+// path, whose values are encrypted with EncryptValue. A ref names an entry
+// of the file holding either an encrypted value or, with a key, a map of
+// them. The key is read from keyFile or, when empty, from the
+// EXPRESSOPS_SECRETS_KEY environment variable. Both files are read again
+// whenever a secret is not cached.
+func NewEncryptedFileProvider(path, keyFile string) Provider {
+	return encryptedFileProvider{path: path, keyFile: keyFile}
+}
+
+func (p encryptedFileProvider) File(Ref) string {
+	return p.path
+}
+
+func (p encryptedFileProvider) Get(_ context.Context, ref Ref) (string, error) {
+	key, err := p.key()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", err
+	}
+	var entries map[string]interface{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return "", fmt.Errorf("invalid encrypted file '%s': %w", p.path, err)
+	}
+
+	entry, ok := entries[ref.Name]
+	if !ok {
+		return "", fmt.Errorf("no entry %s in '%s': %w", ref.Name, p.path, ErrNotFound)
+	}
+	if ref.Key != "" {
+		values, isMap := entry.(map[string]interface{})
+		if !isMap {
+			return "", fmt.Errorf("entry %s of '%s' is not a map of keys", ref.Name, p.path)
+		}
+		if entry, ok = values[ref.Key]; !ok {
+			return "", fmt.Errorf("no key %s in entry %s of '%s': %w", ref.Key, ref.Name, p.path, ErrNotFound)
+		}
+	}
+	encrypted, isString := entry.(string)
+	if !isString {
+		return "", fmt.Errorf("%s in '%s' is not an encrypted value", ref.String(), p.path)
+	}
+	return DecryptValue(key, ref.Name, ref.Key, encrypted)
+}
+
+func (p encryptedFileProvider) key() ([]byte, error) {
+	if p.keyFile == "" {
+		text, ok := os.LookupEnv(KeyEnv)
+		if !ok {
+			return nil, fmt.Errorf("no key for the encrypted file: set %s or secrets.encrypted.keyFile", KeyEnv)
+		}
+		return ParseKey(text)
+	}
+	text, err := os.ReadFile(p.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading the key of the encrypted file: %w", err)
+	}
+	key, err := ParseKey(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %w", p.keyFile, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptValue(t *testing.T) {
+	encoded, err := NewKey()
+	require.NoError(t, err)
+	key, err := ParseKey("# created by a test\n\n" + encoded + "\n")
+	require.NoError(t, err)
+
+	encrypted, err := EncryptValue(key, "slack", "webhook_url", "https://hooks.example.com/abc")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "ENC[AES256_GCM,data:"), encrypted)
+	assert.NotContains(t, encrypted, "hooks.example.com")
+
+	plain, err := DecryptValue(key, "slack", "webhook_url", encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/abc", plain)
+
+	// Bound to its entry and key
+	_, err = DecryptValue(key, "other", "webhook_url", encrypted)
+	assert.ErrorContains(t, err, "cannot decrypt")
+	otherKey, err := NewKey()
+	require.NoError(t, err)
+	other, err := ParseKey(otherKey)
+	require.NoError(t, err)
+	_, err = DecryptValue(other, "slack", "webhook_url", encrypted)
+	assert.ErrorContains(t, err, "cannot decrypt")
+
+	_, err = DecryptValue(key, "slack", "", "plain text")
+	assert.ErrorContains(t, err, "value is not encrypted")
+	_, err = ParseKey("c2hvcnQ=")
+	assert.ErrorContains(t, err, "not a key")
+	_, err = ParseKey(encoded + "\n" + otherKey)
+	assert.ErrorContains(t, err, "more than one key")
+}
+
+func TestEncryptedFileProvider(t *testing.T) {
+	encoded, err := NewKey()
+	require.NoError(t, err)
+	key, err := ParseKey(encoded)
+	require.NoError(t, err)
+
+	webhook, err := EncryptValue(key, "slack", "webhook_url", "https://hooks.example.com/abc")
+	require.NoError(t, err)
+	password, err := EncryptValue(key, "db_password", "", "db-pass-456")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc.yaml")
+	keyFile := filepath.Join(dir, "secrets.key")
+	require.NoError(t, os.WriteFile(path, []byte("slack:\n  webhook_url: "+webhook+"\ndb_password: "+password+"\nplain: text\n"), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, []byte(encoded+"\n"), 0o600))
+
+	ctx := context.Background()
+	provider := NewEncryptedFileProvider(path, keyFile)
+	value, err := provider.Get(ctx, Ref{Provider: ProviderEncrypted, Name: "slack", Key: "webhook_url"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/abc", value)
+	value, err = provider.Get(ctx, Ref{Provider: ProviderEncrypted, Name: "db_password"})
+	require.NoError(t, err)
+	assert.Equal(t, "db-pass-456", value)
+
+	_, err = provider.Get(ctx, Ref{Provider: ProviderEncrypted, Name: "slack", Key: "token"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Get(ctx, Ref{Provider: ProviderEncrypted, Name: "plain"})
+	assert.ErrorContains(t, err, "value is not encrypted")
+	_, err = provider.Get(ctx, Ref{Provider: ProviderEncrypted, Name: "slack"})
+	assert.ErrorContains(t, err, "not an encrypted value")
+
+	// Without a key file, the key comes from the environment
+	t.Setenv(KeyEnv, encoded)
+	value, err = NewEncryptedFileProvider(path, "").Get(ctx, Ref{Provider: ProviderEncrypted, Name: "db_password"})
+	require.NoError(t, err)
+	assert.Equal(t, "db-pass-456", value)
+	assert.Equal(t, path, provider.(encryptedFileProvider).File(Ref{}))
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultDir is where the file provider looks for secrets by default
+const DefaultDir = "/var/run/secrets/expressops"
+
+type envProvider struct{}
+
+// NewEnvProvider returns the provider reading the environment variable
+// named by a ref
+func NewEnvProvider() Provider {
+	return envProvider{}
+}
+
+func (envProvider) Get(_ context.Context, ref Ref) (string, error) {
+	value, ok := os.LookupEnv(ref.Name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set: %w", ref.Name, ErrNotFound)
+	}
+	return value, nil
+}
+
+type dirProvider struct {
+	dir string
+}
+
+// NewFileProvider returns the provider reading the file at dir/name/key, or
+// dir/name without a key. A Kubernetes Secret mounted as a volume at
+// dir/name has a file for each of its keys. The line break ending the file,
+// if any, is not part of the value.
+func NewFileProvider(dir string) Provider {
+	return dirProvider{dir: dir}
+}
+
+func (p dirProvider) File(ref Ref) string {
+	return filepath.Join(p.dir, ref.Name, ref.Key)
+}
+
+func (p dirProvider) Get(_ context.Context, ref Ref) (string, error) {
+	data, err := os.ReadFile(p.File(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no file '%s': %w", p.File(ref), ErrNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	return trimValue(string(data)), nil
+}
@@ -0,0 +1,166 @@
+package secrets
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountDir holds the credentials of the service account of a pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesOptions locates the API server and the credentials used to
+// read Secrets. The zero value works inside a pod whose service account
+// may get the Secrets of its namespace.
+type KubernetesOptions struct {
+	// Namespace of the Secrets, defaults to the namespace of the pod
+	Namespace string
+	// APIServer is the URL of the API server, defaults to the in-cluster
+	// address from KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT
+	APIServer string
+	// TokenFile holds the bearer token, read again on every request since
+	// projected tokens rotate. Defaults to the service account token.
+	TokenFile string
+	// CAFile holds the certificate of the API server, defaults to the
+	// service account CA
+	CAFile string
+}
+
+type kubernetesProvider struct {
+	mu     sync.Mutex
+	opts   KubernetesOptions
+	client *http.Client
+}
+
+// NewKubernetesProvider returns the provider reading the key of a Secret
+// through the Kubernetes API. Nothing is read until the first secret is
+// requested, so that it can be set up outside a cluster.
+func NewKubernetesProvider(opts KubernetesOptions) Provider {
+	return &kubernetesProvider{opts: opts}
+}
+
+// secret is the part of a Kubernetes Secret read by the provider
+type secret struct {
+	Data map[string]string `json:"data"`
+}
+
+// status is the error returned by the Kubernetes API
+type status struct {
+	Message string `json:"message"`
+}
+
+func (p *kubernetesProvider) Get(ctx context.Context, ref Ref) (string, error) {
+	client, opts, err := p.setup()
+	if err != nil {
+		return "", err
+	}
+
+	token, err := os.ReadFile(opts.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading service account token: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s",
+		strings.TrimSuffix(opts.APIServer, "/"), url.PathEscape(opts.Namespace), url.PathEscape(ref.Name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("reading Secret %s/%s: %w", opts.Namespace, ref.Name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("no Secret %s/%s: %w", opts.Namespace, ref.Name, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		var st status
+		if json.Unmarshal(body, &st) != nil || st.Message == "" {
+			st.Message = http.StatusText(resp.StatusCode)
+		}
+		return "", fmt.Errorf("reading Secret %s/%s: %s (%d)", opts.Namespace, ref.Name, st.Message, resp.StatusCode)
+	}
+
+	var s secret
+	if err := json.Unmarshal(body, &s); err != nil {
+		return "", fmt.Errorf("decoding Secret %s/%s: %w", opts.Namespace, ref.Name, err)
+	}
+	encoded, ok := s.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("no key %s in Secret %s/%s: %w", ref.Key, opts.Namespace, ref.Name, ErrNotFound)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decoding key %s of Secret %s/%s: %w", ref.Key, opts.Namespace, ref.Name, err)
+	}
+	return string(value), nil
+}
+
+// setup fills the options left empty from the environment of the pod and
+// builds the client trusting the CA of the API server. It is tried again
+// on the next secret when it fails.
+func (p *kubernetesProvider) setup() (*http.Client, KubernetesOptions, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p.client, p.opts, nil
+	}
+
+	opts := p.opts
+	if opts.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, opts, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set, configure secrets.kubernetes.apiServer")
+		}
+		opts.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if opts.TokenFile == "" {
+		opts.TokenFile = serviceAccountDir + "/token"
+	}
+	if opts.CAFile == "" {
+		opts.CAFile = serviceAccountDir + "/ca.crt"
+	}
+	if opts.Namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, opts, fmt.Errorf("reading the namespace of the pod, configure secrets.kubernetes.namespace: %w", err)
+		}
+		opts.Namespace = strings.TrimSpace(string(data))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.HasPrefix(opts.APIServer, "https://") {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, opts, fmt.Errorf("reading the CA of the API server: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, opts, fmt.Errorf("no certificate found in '%s'", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	p.opts = opts
+	p.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	return p.client, p.opts, nil
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesProvider(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"secrets \"slack\" is forbidden"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/expressops-dev/secrets/slack-webhook-secret":
+			// base64 of https://hooks.example.com/abc
+			_, _ = w.Write([]byte(`{"kind":"Secret","data":{"SLACK_WEBHOOK_URL":"aHR0cHM6Ly9ob29rcy5leGFtcGxlLmNvbS9hYmM="}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind":"Status","message":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	tokenFile := filepath.Join(dir, "token")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, ca, 0o600))
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600))
+
+	provider := NewKubernetesProvider(KubernetesOptions{
+		Namespace: "expressops-dev",
+		APIServer: server.URL,
+		TokenFile: tokenFile,
+		CAFile:    caFile,
+	})
+	ctx := context.Background()
+
+	value, err := provider.Get(ctx, Ref{Provider: ProviderKubernetes, Name: "slack-webhook-secret", Key: "SLACK_WEBHOOK_URL"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/abc", value)
+
+	_, err = provider.Get(ctx, Ref{Provider: ProviderKubernetes, Name: "slack-webhook-secret", Key: "OTHER"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Get(ctx, Ref{Provider: ProviderKubernetes, Name: "other", Key: "x"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// The token is read again on every request
+	require.NoError(t, os.WriteFile(tokenFile, []byte("expired"), 0o600))
+	_, err = provider.Get(ctx, Ref{Provider: ProviderKubernetes, Name: "slack-webhook-secret", Key: "SLACK_WEBHOOK_URL"})
+	assert.ErrorContains(t, err, `secrets "slack" is forbidden (403)`)
+}
+
+func TestKubernetesProviderOutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	provider := NewKubernetesProvider(KubernetesOptions{})
+	_, err := provider.Get(context.Background(), Ref{Provider: ProviderKubernetes, Name: "s", Key: "k"})
+	assert.ErrorContains(t, err, "not running in a Kubernetes cluster")
+}
@@ -0,0 +1,388 @@
+// Package secrets resolves the secretRef values of plugin configs and step
+// parameters from a provider: the environment, files mounted by Kubernetes
+// or External Secrets, a Kubernetes Secret read through the API, or a local
+// file of encrypted values.
+//
+// A secretRef takes the place of any value:
+//
+//	webhook_url:
+//	  secretRef: {provider: file, name: slack-webhook-secret, key: SLACK_WEBHOOK_URL}
+//
+// Values are resolved when they are needed, cached for a while, and masked
+// in every output once resolved.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"expressops/internal/redact"
+)
+
+// Providers
+const (
+	ProviderEnv        = "env"
+	ProviderFile       = "file"
+	ProviderKubernetes = "kubernetes"
+	ProviderEncrypted  = "encrypted"
+)
+
+// RefKey is the key of the map standing for a secret
+const RefKey = "secretRef"
+
+// DefaultCacheTTL is how long a resolved value is reused by default
+const DefaultCacheTTL = 5 * time.Minute
+
+// ErrNotFound is returned when a provider holds no secret at a ref
+var ErrNotFound = errors.New("secret not found")
+
+// Ref points to a secret held by a provider
+type Ref struct {
+	Provider string `yaml:"provider" json:"provider"`
+	// Name is the environment variable, the file or directory below the
+	// secrets dir, the Kubernetes Secret or the entry of the encrypted file
+	Name string `yaml:"name" json:"name"`
+	// Key selects a value inside Name: a file of the directory, a key of the
+	// Kubernetes Secret or of the entry of the encrypted file
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
+func (r Ref) String() string {
+	if r.Key == "" {
+		return r.Provider + ":" + r.Name
+	}
+	return r.Provider + ":" + r.Name + "/" + r.Key
+}
+
+// Validate checks that the provider is known and that it can use the name
+// and key of the ref
+func (r Ref) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	switch r.Provider {
+	case ProviderEnv:
+		if r.Key != "" {
+			return errors.New("the env provider takes no key")
+		}
+	case ProviderFile, ProviderEncrypted:
+		if !filepath.IsLocal(filepath.Join(r.Name, r.Key)) {
+			return fmt.Errorf("'%s' is not a relative path inside the secrets dir", filepath.Join(r.Name, r.Key))
+		}
+	case ProviderKubernetes:
+		if r.Key == "" {
+			return errors.New("the kubernetes provider requires the key of the Secret")
+		}
+	case "":
+		return errors.New("provider is required")
+	default:
+		return fmt.Errorf("unknown provider '%s' (use %s, %s, %s or %s)",
+			r.Provider, ProviderEnv, ProviderFile, ProviderKubernetes, ProviderEncrypted)
+	}
+	return nil
+}
+
+// ParseRef returns the ref value stands for. ok is false when value is not
+// a map holding a secretRef key; err is set when it is but the ref is
+// invalid.
+func ParseRef(value interface{}) (ref Ref, ok bool, err error) {
+	m, isMap := value.(map[string]interface{})
+	if !isMap {
+		return Ref{}, false, nil
+	}
+	raw, isRef := m[RefKey]
+	if !isRef {
+		return Ref{}, false, nil
+	}
+	if len(m) > 1 {
+		return Ref{}, true, fmt.Errorf("%s must be the only key of its map", RefKey)
+	}
+	fields, isMap := raw.(map[string]interface{})
+	if !isMap {
+		return Ref{}, true, fmt.Errorf("%s must be a map with provider, name and key", RefKey)
+	}
+	for field, v := range fields {
+		s, isString := v.(string)
+		if !isString {
+			return Ref{}, true, fmt.Errorf("%s.%s must be a string", RefKey, field)
+		}
+		switch field {
+		case "provider":
+			ref.Provider = s
+		case "name":
+			ref.Name = s
+		case "key":
+			ref.Key = s
+		default:
+			return Ref{}, true, fmt.Errorf("unknown field %s.%s", RefKey, field)
+		}
+	}
+	if err := ref.Validate(); err != nil {
+		return Ref{}, true, fmt.Errorf("%s: %w", RefKey, err)
+	}
+	return ref, true, nil
+}
+
+// Walk calls fn with the path and ref of every secretRef in value, at any
+// depth, and with the error of every invalid one
+func Walk(value interface{}, fn func(path string, ref Ref, err error)) {
+	walk("", value, fn)
+}
+
+func walk(path string, value interface{}, fn func(string, Ref, error)) {
+	if ref, ok, err := ParseRef(value); ok {
+		fn(path, ref, err)
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walk(join(path, key), v[key], fn)
+		}
+	case []interface{}:
+		for i, item := range v {
+			walk(fmt.Sprintf("%s[%d]", path, i), item, fn)
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Refs returns the valid refs of value
+func Refs(value interface{}) []Ref {
+	var refs []Ref
+	Walk(value, func(_ string, ref Ref, err error) {
+		if err == nil {
+			refs = append(refs, ref)
+		}
+	})
+	return refs
+}
+
+// Provider reads secrets from a backend
+type Provider interface {
+	// Get returns the value of the secret at ref, or an error wrapping
+	// ErrNotFound when there is none
+	Get(ctx context.Context, ref Ref) (string, error)
+}
+
+// fileProvider is implemented by the providers reading local files, whose
+// change means a rotated secret
+type fileProvider interface {
+	File(ref Ref) string
+}
+
+// Resolver resolves refs with its providers and caches the values
+type Resolver struct {
+	mu        sync.Mutex
+	providers map[string]Provider
+	ttl       time.Duration
+	cache     map[Ref]cached
+	now       func() time.Time
+}
+
+type cached struct {
+	value   string
+	expires time.Time
+}
+
+var defaultResolver = NewResolver(DefaultCacheTTL)
+
+// Default returns the resolver of the process. Until the config sets it
+// up, it knows the env, file and kubernetes providers with their defaults.
+func Default() *Resolver {
+	return defaultResolver
+}
+
+// NewResolver returns a resolver caching values for ttl, knowing the env,
+// file and kubernetes providers with their defaults
+func NewResolver(ttl time.Duration) *Resolver {
+	r := &Resolver{
+		providers: make(map[string]Provider),
+		ttl:       ttl,
+		cache:     make(map[Ref]cached),
+		now:       time.Now,
+	}
+	r.SetProvider(ProviderEnv, NewEnvProvider())
+	r.SetProvider(ProviderFile, NewFileProvider(DefaultDir))
+	r.SetProvider(ProviderKubernetes, NewKubernetesProvider(KubernetesOptions{}))
+	return r
+}
+
+// SetProvider registers p under name, replacing the provider of that name
+// and dropping the values it resolved
+func (r *Resolver) SetProvider(name string, p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = p
+	for ref := range r.cache {
+		if ref.Provider == name {
+			delete(r.cache, ref)
+		}
+	}
+}
+
+// SetCacheTTL sets how long resolved values are reused
+func (r *Resolver) SetCacheTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = ttl
+}
+
+// Get returns the value of the secret at ref, from the cache when it was
+// resolved less than the cache TTL ago. The value is registered with the
+// default redactor before it is returned.
+func (r *Resolver) Get(ctx context.Context, ref Ref) (string, error) {
+	r.mu.Lock()
+	entry, hit := r.cache[ref]
+	provider := r.providers[ref.Provider]
+	ttl := r.ttl
+	r.mu.Unlock()
+	if hit && r.now().Before(entry.expires) {
+		return entry.value, nil
+	}
+	if provider == nil {
+		return "", fmt.Errorf("secret %s: provider '%s' is not configured", ref, ref.Provider)
+	}
+
+	value, err := provider.Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", ref, err)
+	}
+	redact.Default().Add(value)
+
+	r.mu.Lock()
+	r.cache[ref] = cached{value: value, expires: r.now().Add(ttl)}
+	r.mu.Unlock()
+	return value, nil
+}
+
+// Resolve returns a copy of value with every secretRef replaced by the
+// value of its secret. value itself is returned when it holds no secretRef.
+func (r *Resolver) Resolve(ctx context.Context, value interface{}) (interface{}, error) {
+	found := false
+	var errs []error
+	Walk(value, func(path string, _ Ref, err error) {
+		found = true
+		if err != nil {
+			errs = append(errs, withPath(path, err))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if !found {
+		return value, nil
+	}
+	return r.resolve(ctx, "", value)
+}
+
+func (r *Resolver) resolve(ctx context.Context, path string, value interface{}) (interface{}, error) {
+	if ref, ok, err := ParseRef(value); ok {
+		if err != nil {
+			return nil, withPath(path, err)
+		}
+		secret, err := r.Get(ctx, ref)
+		if err != nil {
+			return nil, withPath(path, err)
+		}
+		return secret, nil
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := r.resolve(ctx, join(path, key), item)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := r.resolve(ctx, fmt.Sprintf("%s[%d]", path, i), item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// ResolveMap is Resolve for the common case of a map, e.g. the config of a
+// plugin
+func (r *Resolver) ResolveMap(ctx context.Context, m map[string]interface{}) (map[string]interface{}, error) {
+	if m == nil {
+		return nil, nil
+	}
+	resolved, err := r.Resolve(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func withPath(path string, err error) error {
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// Forget drops the cached values of the refs of value, so that they are
+// read again from their provider, e.g. when reloading a plugin
+func (r *Resolver) Forget(value interface{}) {
+	refs := Refs(value)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ref := range refs {
+		delete(r.cache, ref)
+	}
+}
+
+// Files returns the local files holding the secrets of value, sorted. A
+// change to one of them means a secret was rotated.
+func (r *Resolver) Files(value interface{}) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, ref := range Refs(value) {
+		r.mu.Lock()
+		provider := r.providers[ref.Provider]
+		r.mu.Unlock()
+		fp, ok := provider.(fileProvider)
+		if !ok {
+			continue
+		}
+		if file := fp.File(ref); file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// trimValue removes the line break that ends most files and command outputs
+func trimValue(value string) string {
+	return strings.TrimRight(value, "\r\n")
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"expressops/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refValue(provider, name, key string) map[string]interface{} {
+	fields := map[string]interface{}{"provider": provider, "name": name}
+	if key != "" {
+		fields["key"] = key
+	}
+	return map[string]interface{}{RefKey: fields}
+}
+
+func TestParseRef(t *testing.T) {
+	ref, ok, err := ParseRef(refValue("file", "slack", "webhook_url"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Ref{Provider: "file", Name: "slack", Key: "webhook_url"}, ref)
+	assert.Equal(t, "file:slack/webhook_url", ref.String())
+
+	for _, value := range []interface{}{"text", 3, map[string]interface{}{"name": "x"}} {
+		_, ok, err := ParseRef(value)
+		assert.False(t, ok)
+		assert.NoError(t, err)
+	}
+
+	for expected, value := range map[string]interface{}{
+		"only key of its map":          map[string]interface{}{RefKey: map[string]interface{}{}, "other": 1},
+		"must be a map":                map[string]interface{}{RefKey: "file:slack"},
+		"unknown field secretRef.path": map[string]interface{}{RefKey: map[string]interface{}{"path": "x"}},
+		"provider is required":         map[string]interface{}{RefKey: map[string]interface{}{"name": "x"}},
+		"unknown provider 'vault'":     refValue("vault", "x", ""),
+		"name is required":             refValue("env", "", ""),
+		"env provider takes no key":    refValue("env", "TOKEN", "x"),
+		"requires the key":             refValue("kubernetes", "slack", ""),
+		"not a relative path":          refValue("file", "../etc", "passwd"),
+	} {
+		_, ok, err := ParseRef(value)
+		assert.True(t, ok, expected)
+		assert.ErrorContains(t, err, expected)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	value := map[string]interface{}{
+		"url": refValue("env", "URL", ""),
+		"headers": []interface{}{
+			map[string]interface{}{"token": refValue("kubernetes", "api", "token")},
+			refValue("env", "", ""),
+		},
+		"plain": "value",
+	}
+	var paths []string
+	Walk(value, func(path string, _ Ref, err error) {
+		if err != nil {
+			path += " (invalid)"
+		}
+		paths = append(paths, path)
+	})
+	assert.Equal(t, []string{"headers[0].token", "headers[1] (invalid)", "url"}, paths)
+	assert.Len(t, Refs(value), 2)
+}
+
+// countingProvider returns the value of each ref with the number of times
+// it was asked for it
+type countingProvider struct {
+	calls map[string]int
+}
+
+func (p *countingProvider) Get(_ context.Context, ref Ref) (string, error) {
+	p.calls[ref.Name]++
+	if ref.Name == "missing" {
+		return "", ErrNotFound
+	}
+	return ref.Name + "-value-" + string(rune('0'+p.calls[ref.Name])), nil
+}
+
+func TestResolver(t *testing.T) {
+	provider := &countingProvider{calls: make(map[string]int)}
+	r := NewResolver(time.Minute)
+	r.SetProvider(ProviderEnv, provider)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	config := map[string]interface{}{
+		"url":    refValue(ProviderEnv, "url", ""),
+		"nested": map[string]interface{}{"items": []interface{}{refValue(ProviderEnv, "item", "")}},
+		"count":  3,
+	}
+	resolved, err := r.ResolveMap(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"url":    "url-value-1",
+		"nested": map[string]interface{}{"items": []interface{}{"item-value-1"}},
+		"count":  3,
+	}, resolved)
+	// The config is left as it was
+	assert.Equal(t, refValue(ProviderEnv, "url", ""), config["url"])
+	// Resolved values are masked
+	assert.Equal(t, redact.Mask, redact.Default().String("item-value-1"))
+
+	// Cached until the TTL expires or the refs are forgotten
+	_, err = r.Resolve(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.calls["url"])
+	now = now.Add(2 * time.Minute)
+	value, err := r.Resolve(context.Background(), config["url"])
+	require.NoError(t, err)
+	assert.Equal(t, "url-value-2", value)
+	r.Forget(config)
+	value, err = r.Resolve(context.Background(), config["url"])
+	require.NoError(t, err)
+	assert.Equal(t, "url-value-3", value)
+
+	// Values without refs are returned as they are
+	plain := map[string]interface{}{"a": "b"}
+	same, err := r.ResolveMap(context.Background(), plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, same)
+
+	_, err = r.Resolve(context.Background(), map[string]interface{}{"x": refValue(ProviderEnv, "missing", "")})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorContains(t, err, "x: secret env:missing")
+	_, err = r.Resolve(context.Background(), map[string]interface{}{"x": refValue("encrypted", "db", "")})
+	assert.ErrorContains(t, err, "provider 'encrypted' is not configured")
+	_, err = r.Resolve(context.Background(), map[string]interface{}{"x": refValue("env", "", "")})
+	assert.ErrorContains(t, err, "x: secretRef: name is required")
+}
+
+func TestEnvAndFileProviders(t *testing.T) {
+	t.Setenv("SECRETS_TEST_TOKEN", "env-token-value")
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "slack"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "slack", "webhook_url"), []byte("https://hooks.example.com/abc\n"), 0o600))
+
+	r := NewResolver(time.Minute)
+	r.SetProvider(ProviderFile, NewFileProvider(dir))
+
+	value, err := r.Get(context.Background(), Ref{Provider: ProviderEnv, Name: "SECRETS_TEST_TOKEN"})
+	require.NoError(t, err)
+	assert.Equal(t, "env-token-value", value)
+	_, err = r.Get(context.Background(), Ref{Provider: ProviderEnv, Name: "SECRETS_TEST_UNSET"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ref := Ref{Provider: ProviderFile, Name: "slack", Key: "webhook_url"}
+	value, err = r.Get(context.Background(), ref)
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/abc", value)
+	_, err = r.Get(context.Background(), Ref{Provider: ProviderFile, Name: "slack", Key: "token"})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	config := map[string]interface{}{
+		"url":   refValue(ProviderFile, "slack", "webhook_url"),
+		"again": refValue(ProviderFile, "slack", "webhook_url"),
+		"token": refValue(ProviderEnv, "SECRETS_TEST_TOKEN", ""),
+	}
+	assert.Equal(t, []string{filepath.Join(dir, "slack", "webhook_url")}, r.Files(config))
+}
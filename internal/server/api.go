@@ -0,0 +1,427 @@
+// internal/server/api.go
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+	"expressops/internal/metrics"
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/redact"
+	"expressops/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// registerAPIRoutes mounts the /api/v1 endpoints used by `expressops client`
+func registerAPIRoutes(mux *http.ServeMux, cfg *v1beta1.Config, logger *logrus.Logger, timeout time.Duration) {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/flows", listFlowsHandler)
+	api.HandleFunc("GET /api/v1/flows/{name}", getFlowHandler)
+	api.HandleFunc("GET /api/v1/flows/{name}/graph", flowGraphHandler)
+	api.HandleFunc("GET /api/v1/plugins", listPluginsHandler)
+	api.HandleFunc("POST /api/v1/plugins/{name}/reload", reloadPluginHandler(logger))
+	api.HandleFunc("GET /api/v1/executions", listExecutionsHandler)
+	api.HandleFunc("POST /api/v1/executions", createExecutionHandler(logger, timeout))
+	api.HandleFunc("GET /api/v1/executions/{id}", getExecutionHandler)
+	api.HandleFunc("GET /api/v1/executions/{id}/logs", executionLogsHandler)
+	api.HandleFunc("POST /api/v1/executions/{id}/cancel", cancelExecutionHandler(logger))
+
+	mux.Handle("/api/v1/", tracing.Handler("/api/v1", metrics.Handler("/api/v1", requireToken(cfg.Server.Auth.Tokens, api))))
+}
+
+// requireToken rejects requests without a valid bearer token, and records
+// the rejections in the audit trail. The fingerprint of the token is passed
+// on as the actor of the request. If no tokens are configured the handler
+// is returned unchanged.
+func requireToken(tokens []string, next http.Handler) http.Handler {
+	var valid [][]byte
+	for _, t := range tokens {
+		if t != "" {
+			valid = append(valid, []byte(t))
+		}
+	}
+	if len(valid) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			for _, t := range valid {
+				if subtle.ConstantTimeCompare([]byte(token), t) == 1 {
+					next.ServeHTTP(w, r.WithContext(withActor(r.Context(), tokenActor(token))))
+					return
+				}
+			}
+		}
+		e := callerOf(r).event(audit.ActionAuthDenied, audit.OutcomeDenied)
+		e.Error = r.Method + " " + r.URL.Path
+		audit.Record(e)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="expressops"`)
+		writeAPIError(w, http.StatusUnauthorized, "invalid or missing API token")
+	})
+}
+
+func listFlowsHandler(w http.ResponseWriter, _ *http.Request) {
+	names := make([]string, 0, len(flowRegistry))
+	for name := range flowRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	flows := make([]v1beta1.FlowSummary, 0, len(names))
+	for _, name := range names {
+		flow := flowRegistry[name]
+		summary := v1beta1.FlowSummary{
+			Name:          flow.Name,
+			Description:   flow.Description,
+			CustomHandler: flow.CustomHandler,
+			Plugins:       []string{},
+		}
+		for _, step := range flow.Pipeline {
+			if step.PluginRef != "" {
+				summary.Plugins = append(summary.Plugins, step.PluginRef)
+			}
+		}
+		flows = append(flows, summary)
+	}
+	writeJSON(w, http.StatusOK, flows)
+}
+
+func listPluginsHandler(w http.ResponseWriter, _ *http.Request) {
+	loaded := pluginManager.Loaded()
+	plugins := make([]v1beta1.PluginSummary, 0, len(loaded))
+	for _, p := range loaded {
+		plugins = append(plugins, pluginSummary(p))
+	}
+	writeJSON(w, http.StatusOK, plugins)
+}
+
+func pluginSummary(p pluginManager.LoadedPlugin) v1beta1.PluginSummary {
+	summary := v1beta1.PluginSummary{
+		Name:        p.Name,
+		Version:     p.Manifest.Version,
+		Description: p.Manifest.Description,
+		Author:      p.Manifest.Author,
+		APIVersion:  p.Manifest.APIVersion,
+		Inputs:      p.Manifest.Inputs,
+		Outputs:     p.Manifest.Outputs,
+	}
+	if p.Manifest.ConfigSchema != nil {
+		if data, err := json.Marshal(p.Manifest.ConfigSchema); err == nil {
+			summary.ConfigSchema = data
+		}
+	}
+	return summary
+}
+
+// ReloadPluginFunc reloads the plugin with the given name from the config
+// file. It is set by main, which knows that file; until then the reload
+// endpoint answers 501.
+var ReloadPluginFunc func(ctx context.Context, name string) error
+
+// reloadPluginHandler swaps a plugin for a new instance. Steps already
+// running finish on the previous one.
+func reloadPluginHandler(logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if ReloadPluginFunc == nil {
+			writeAPIError(w, http.StatusNotImplemented, "plugin reloading is not available")
+			return
+		}
+
+		err := ReloadPluginFunc(r.Context(), name)
+		if !errors.Is(err, pluginManager.ErrNotLoaded) {
+			e := callerOf(r).event(audit.ActionPluginReload, audit.OutcomeSucceeded)
+			e.Plugin = name
+			if err != nil {
+				e.Outcome, e.Error = audit.OutcomeFailed, err.Error()
+			}
+			audit.Record(e)
+		}
+		if err != nil {
+			if errors.Is(err, pluginManager.ErrNotLoaded) {
+				writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", name))
+				return
+			}
+			logger.Errorf("Error reloading plugin '%s': %v", name, err)
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Error reloading plugin '%s': %v", name, err))
+			return
+		}
+
+		for _, p := range pluginManager.Loaded() {
+			if p.Name == name {
+				writeJSON(w, http.StatusOK, pluginSummary(p))
+				return
+			}
+		}
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", name))
+	}
+}
+
+func getFlowHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	flow, exists := flowRegistry[name]
+	if !exists {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Flow '%s' not found", name))
+		return
+	}
+
+	// Step parameters may hold secrets, such as a password given inline
+	pipeline := make([]v1beta1.Step, len(flow.Pipeline))
+	for i, step := range flow.Pipeline {
+		step.Parameters = redact.Default().Map(step.Parameters)
+		pipeline[i] = step
+	}
+	flow.Pipeline = pipeline
+	writeJSON(w, http.StatusOK, flow)
+}
+
+// flowGraphHandler renders the resolved execution plan of a flow as Mermaid or DOT.
+// With ?execution=<id> the steps are colored by the outcome of that run.
+func flowGraphHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	flow, exists := flowRegistry[name]
+	if !exists {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Flow '%s' not found", name))
+		return
+	}
+
+	var results []interface{}
+	if id := r.URL.Query().Get("execution"); id != "" {
+		exec, ok := executions.get(id)
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Execution '%s' not found", id))
+			return
+		}
+		if exec.Flow != name {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("Execution '%s' belongs to flow '%s'", id, exec.Flow))
+			return
+		}
+		results = exec.Results
+		if results == nil {
+			results = []interface{}{}
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	graph, err := buildFlowGraph(flow, results).render(format)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if format == graphFormatDOT {
+		contentType = "text/vnd.graphviz; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write([]byte(graph)); err != nil {
+		logrus.WithError(err).Error("Error writing graph response")
+	}
+}
+
+func listExecutionsHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, executions.list())
+}
+
+func getExecutionHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	exec, ok := executions.get(id)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Execution '%s' not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, exec)
+}
+
+// executionLogsHandler writes the lines logged by an execution as
+// newline-delimited JSON. With ?follow=true it keeps writing new lines as
+// they are logged, until the execution finishes or the client goes away.
+func executionLogsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	capture, done, ok := executions.logs(id)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Execution '%s' not found", id))
+		return
+	}
+	follow := r.URL.Query().Get("follow")
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set(RequestIDHeader, id)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	for offset := 0; ; {
+		// Read done before the lines, so that none logged before the end
+		// of the execution is missed
+		finished := isClosed(done)
+		lines, _, updated := capture.since(offset)
+		for _, line := range lines {
+			if err := enc.Encode(line); err != nil {
+				return
+			}
+		}
+		offset += len(lines)
+
+		if (follow != "true" && follow != "1") || finished {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-updated:
+		case <-done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// isClosed reports whether ch is closed, without blocking
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// createExecutionHandler starts a flow in the background.
+// With ?wait=true it blocks until the flow finishes and returns the final state.
+func createExecutionHandler(logger *logrus.Logger, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body v1beta1.ExecutionRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		if body.Flow == "" {
+			writeAPIError(w, http.StatusBadRequest, "Must indicate flow")
+			return
+		}
+
+		flow, exists := flowRegistry[body.Flow]
+		if !exists {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Flow '%s' not found", body.Flow))
+			return
+		}
+		if body.Params == nil {
+			body.Params = make(map[string]interface{})
+		}
+
+		id, err := startExecution(r.Context(), requestID(r), flow, body.Params, callerOf(r), logger, timeout)
+		var limited *limitError
+		if errors.As(err, &limited) {
+			writeLimited(w, limited, true)
+			return
+		}
+		if errors.Is(err, errExecutionExists) {
+			writeAPIError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		status := http.StatusAccepted
+		if wait := r.URL.Query().Get("wait"); wait == "true" || wait == "1" {
+			executions.wait(r.Context(), id)
+			status = http.StatusOK
+		}
+
+		exec, _ := executions.get(id)
+		w.Header().Set("Location", "/api/v1/executions/"+id)
+		writeJSON(w, status, exec)
+	}
+}
+
+func cancelExecutionHandler(logger *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !executions.cancel(id) {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("Execution '%s' not found", id))
+			return
+		}
+		logger.WithField("execution", id).Info("Execution cancellation requested")
+
+		exec, _ := executions.get(id)
+		e := callerOf(r).event(audit.ActionExecutionCancel, audit.OutcomeSucceeded)
+		e.Flow, e.ExecutionID = exec.Flow, id
+		audit.Record(e)
+		writeJSON(w, http.StatusAccepted, exec)
+	}
+}
+
+// startExecution runs a flow in its own goroutine, detached from the caller's
+// request but part of its trace, and tracks it under id. A run queued by
+// the concurrency of the flow is started once it gets a slot; a run refused
+// by a limit returns a *limitError, checked after the ID is known to be free
+// so that a duplicate does not use up the limits.
+func startExecution(parent context.Context, id string, flow v1beta1.Flow, params map[string]interface{}, c caller, logger *logrus.Logger, timeout time.Duration) (string, error) {
+	if executions.exists(id) {
+		return "", fmt.Errorf("execution '%s': %w", id, errExecutionExists)
+	}
+	release, err := limits.admit(parent, flow.Name, c, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	traced := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(parent))
+	ctx, cancel := context.WithTimeout(traced, timeout)
+
+	// Plugins read the flow name from the request, so mirror the /flow URL
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/flow?flowName="+url.QueryEscape(flow.Name), nil)
+	if err != nil {
+		cancel()
+		release()
+		return "", err
+	}
+
+	exec, err := executions.start(id, flow, params, c, cancel)
+	if err != nil {
+		cancel()
+		release()
+		return "", err
+	}
+	ctx, execLogger := withExecutionLogger(ctx, exec, logger)
+	req = req.WithContext(ctx)
+	execLogger.WithField("ip", c.source).Info("Starting flow execution via API")
+
+	go func() {
+		defer release()
+		defer cancel()
+		results := executeFlow(ctx, flow, params, req, logger, flow.Name == "all-flows")
+		executions.finish(exec.ID, results)
+	}()
+
+	return exec.ID, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.WithError(err).Error("Error encoding JSON response")
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,534 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/redact"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestAPI(t *testing.T, tokens []string) *httptest.Server {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	flowRegistry = map[string]v1beta1.Flow{
+		"api-flow": {
+			Name: "api-flow",
+			Pipeline: []v1beta1.Step{
+				{PluginRef: "api-plugin"},
+			},
+		},
+	}
+	executions = newExecutionStore(maxStoredExecutions)
+
+	apiPlugin := new(MockPlugin)
+	apiPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return("api result", nil)
+	apiPlugin.On("FormatResult", mock.Anything).Return("formatted api result", nil)
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		if name == "api-plugin" {
+			return apiPlugin, nil
+		}
+		return nil, fmt.Errorf("plugin not found")
+	}
+	t.Cleanup(func() { pluginManager.GetPluginFunc = originalGetPlugin })
+
+	cfg := &v1beta1.Config{Server: v1beta1.ServerConfig{Auth: v1beta1.AuthConfig{Tokens: tokens}}}
+	mux := http.NewServeMux()
+	registerAPIRoutes(mux, cfg, logger, 5*time.Second)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAPIListFlows(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	resp, err := http.Get(srv.URL + "/api/v1/flows")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var flows []v1beta1.FlowSummary
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&flows))
+	require.Len(t, flows, 1)
+	assert.Equal(t, "api-flow", flows[0].Name)
+	assert.Equal(t, []string{"api-plugin"}, flows[0].Plugins)
+}
+
+func TestAPIGetFlowNotFound(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	resp, err := http.Get(srv.URL + "/api/v1/flows/missing")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPIRunAndGetExecution(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	body := strings.NewReader(`{"flow":"api-flow","params":{"key":"value"}}`)
+	resp, err := http.Post(srv.URL+"/api/v1/executions?wait=true", "application/json", body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var exec v1beta1.Execution
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exec))
+	assert.NotEmpty(t, exec.ID)
+	assert.Equal(t, v1beta1.ExecutionSucceeded, exec.Status)
+	assert.Equal(t, "value", exec.Params["key"])
+	assert.Len(t, exec.Results, 1)
+
+	getResp, err := http.Get(srv.URL + "/api/v1/executions/" + exec.ID)
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, getResp.StatusCode)
+	var fetched v1beta1.Execution
+	require.NoError(t, json.NewDecoder(getResp.Body).Decode(&fetched))
+	assert.Equal(t, exec.ID, fetched.ID)
+	assert.NotNil(t, fetched.FinishedAt)
+}
+
+func TestAPIExecutionLogs(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	loggingPlugin := new(MockPlugin)
+	loggingPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			logger := pluginManager.LoggerFromContext(args.Get(0).(context.Context), nil)
+			logger.WithField("recipient", "ops").Info("sending message")
+		}).
+		Return("api result", nil)
+	loggingPlugin.On("FormatResult", mock.Anything).Return("formatted api result", nil)
+
+	getPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		if name == "api-plugin" {
+			return loggingPlugin, nil
+		}
+		return getPlugin(name)
+	}
+	t.Cleanup(func() { pluginManager.GetPluginFunc = getPlugin })
+
+	run := func(id string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/executions?wait=true", strings.NewReader(`{"flow":"api-flow"}`))
+		require.NoError(t, err)
+		req.Header.Set(RequestIDHeader, id)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := run("req-123")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "req-123", resp.Header.Get(RequestIDHeader))
+	var exec v1beta1.Execution
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exec))
+	assert.Equal(t, "req-123", exec.ID)
+
+	var pluginLine *v1beta1.ExecutionLog
+	for i, line := range exec.Logs {
+		if line.Message == "sending message" {
+			pluginLine = &exec.Logs[i]
+		}
+	}
+	require.NotNil(t, pluginLine, "plugin log line not captured: %+v", exec.Logs)
+	assert.Equal(t, "info", pluginLine.Level)
+	assert.Equal(t, "api-plugin", pluginLine.Fields["step"])
+	assert.Equal(t, "ops", pluginLine.Fields["recipient"])
+	assert.NotContains(t, pluginLine.Fields, "execution_id")
+
+	// An ID can only be used once
+	dup := run("req-123")
+	defer dup.Body.Close()
+	assert.Equal(t, http.StatusConflict, dup.StatusCode)
+
+	// An ID that is not a short printable token is replaced
+	invalid := run("has spaces")
+	defer invalid.Body.Close()
+	assert.Equal(t, http.StatusOK, invalid.StatusCode)
+	assert.NotEqual(t, "has spaces", invalid.Header.Get(RequestIDHeader))
+	assert.NotEmpty(t, invalid.Header.Get(RequestIDHeader))
+}
+
+func TestAPIExecutionLogsEndpoint(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	release := make(chan struct{})
+	slowPlugin := new(MockPlugin)
+	slowPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			logger := pluginManager.LoggerFromContext(args.Get(0).(context.Context), nil)
+			logger.Info("waiting")
+			<-release
+			logger.Info("released")
+		}).
+		Return("api result", nil)
+	slowPlugin.On("FormatResult", mock.Anything).Return("line one\nline two", nil)
+
+	getPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		if name == "api-plugin" {
+			return slowPlugin, nil
+		}
+		return getPlugin(name)
+	}
+	t.Cleanup(func() { pluginManager.GetPluginFunc = getPlugin })
+
+	resp, err := http.Post(srv.URL+"/api/v1/executions", "application/json", strings.NewReader(`{"flow":"api-flow"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	id := resp.Header.Get(RequestIDHeader)
+	require.NotEmpty(t, id)
+
+	follow, err := http.Get(srv.URL + "/api/v1/executions/" + id + "/logs?follow=true")
+	require.NoError(t, err)
+	defer follow.Body.Close()
+	assert.Equal(t, http.StatusOK, follow.StatusCode)
+	assert.Equal(t, "application/x-ndjson", follow.Header.Get("Content-Type"))
+
+	// Lines arrive while the execution is running, and the stream ends
+	// with it
+	dec := json.NewDecoder(follow.Body)
+	var messages []string
+	for {
+		var line v1beta1.ExecutionLog
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		messages = append(messages, line.Message)
+		if line.Message == "waiting" {
+			close(release)
+		}
+	}
+	assert.Contains(t, messages, "released")
+	assert.Contains(t, messages, "Result from api-plugin: line one (1 more lines)")
+
+	exec, ok := executions.get(id)
+	require.True(t, ok)
+	assert.True(t, exec.Status.Finished())
+
+	logs, err := http.Get(srv.URL + "/api/v1/executions/" + id + "/logs")
+	require.NoError(t, err)
+	defer logs.Body.Close()
+	body, err := io.ReadAll(logs.Body)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(body)), "\n"), len(messages))
+
+	missing, err := http.Get(srv.URL + "/api/v1/executions/missing/logs")
+	require.NoError(t, err)
+	defer missing.Body.Close()
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+}
+
+func TestLogCaptureLimits(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	capture := newLogCapture()
+	entry := newExecutionLogger(logger, redact.Default(), capture).WithField("execution_id", "e1")
+	for i := 0; i < maxExecutionLogLines+10; i++ {
+		entry.WithField("i", i).Info("line")
+	}
+	lines, truncated := capture.snapshot()
+	assert.True(t, truncated)
+	require.Len(t, lines, maxExecutionLogLines+1)
+	assert.Equal(t, "warning", lines[maxExecutionLogLines].Level)
+	assert.Contains(t, lines[maxExecutionLogLines].Message, "Log limit")
+
+	capture = newLogCapture()
+	entry = newExecutionLogger(logger, redact.Default(), capture).WithField("execution_id", "e2")
+	entry.Info(strings.Repeat("x", maxExecutionLogBytes/2+1))
+	entry.Info(strings.Repeat("x", maxExecutionLogBytes/2+1))
+	lines, truncated = capture.snapshot()
+	assert.True(t, truncated)
+	assert.Len(t, lines, 2)
+}
+
+func TestAPIRunUnknownFlow(t *testing.T) {
+	srv := newTestAPI(t, nil)
+
+	resp, err := http.Post(srv.URL+"/api/v1/executions", "application/json", strings.NewReader(`{"flow":"missing"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPITokenAuth(t *testing.T) {
+	srv := newTestAPI(t, []string{"s3cret"})
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+	}{
+		{name: "missing token", header: "", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer nope", expectedStatus: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer s3cret", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/flows", nil)
+			require.NoError(t, err)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestAPIReloadPlugin(t *testing.T) {
+	srv := newTestAPI(t, nil)
+	t.Cleanup(func() { ReloadPluginFunc = nil })
+
+	reload := func(name string) (*http.Response, v1beta1.PluginSummary) {
+		resp, err := http.Post(srv.URL+"/api/v1/plugins/"+name+"/reload", "application/json", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var summary v1beta1.PluginSummary
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&summary))
+		}
+		return resp, summary
+	}
+
+	resp, _ := reload("api-plugin")
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+
+	ReloadPluginFunc = func(ctx context.Context, name string) error {
+		switch name {
+		case "reloaded-plugin":
+			p := new(MockPlugin)
+			p.On("Initialize", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			return pluginManager.LoadInstance(ctx, p, name, nil, logrus.New())
+		case "broken-plugin":
+			return fmt.Errorf("error initializing plugin: '%s': bad webhook", name)
+		}
+		return fmt.Errorf("plugin '%s': %w", name, pluginManager.ErrNotLoaded)
+	}
+
+	resp, summary := reload("reloaded-plugin")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "reloaded-plugin", summary.Name)
+
+	resp, _ = reload("broken-plugin")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	resp, _ = reload("missing-plugin")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestSecretsNeverOutput runs flows with a secret from the config of a
+// plugin and one passed as a parameter, and checks that neither appears in
+// any output: logs, responses, stored executions, flow descriptions and
+// spans
+func TestSecretsNeverOutput(t *testing.T) {
+	const configSecret = "https://hooks.slack.com/services/T000/B000/config-secret"
+	const paramSecret = "param-secret-hunter2"
+
+	srv := newTestAPI(t, nil)
+	flowRegistry["secret-flow"] = v1beta1.Flow{
+		Name: "secret-flow",
+		Pipeline: []v1beta1.Step{
+			{PluginRef: "secret-plugin", Parameters: map[string]interface{}{"api_token": "step-token-secret"}},
+			{PluginRef: "failing-plugin"},
+		},
+	}
+	redact.Default().AddFrom(flowRegistry["secret-flow"].Pipeline[0].Parameters)
+
+	var out strings.Builder
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(redact.Default().Hook())
+
+	recorder := tracetest.NewSpanRecorder()
+	originalTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(originalTP) })
+
+	secretPlugin := new(MockPlugin)
+	secretPlugin.On("Initialize", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	secretPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			log := pluginManager.LoggerFromContext(ctx, nil)
+			log.WithField("webhook", configSecret).Infof("posting to %s with %s", configSecret, paramSecret)
+			log.WithField("password", paramSecret).Warn("retrying")
+		}).
+		Return(map[string]interface{}{
+			"url":  configSecret,
+			"echo": "password was " + paramSecret,
+		}, nil)
+	secretPlugin.On("FormatResult", mock.Anything).Return("sent to "+configSecret, nil)
+
+	failingPlugin := new(MockPlugin)
+	failingPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, fmt.Errorf("login with %s refused by %s", paramSecret, configSecret))
+
+	// The secret of the config is registered as the plugin is loaded
+	require.NoError(t, pluginManager.LoadInstance(context.Background(), secretPlugin, "secret-plugin",
+		map[string]interface{}{"webhook_url": configSecret}, logger))
+
+	getPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		switch name {
+		case "secret-plugin":
+			return secretPlugin, nil
+		case "failing-plugin":
+			return failingPlugin, nil
+		}
+		return getPlugin(name)
+	}
+	t.Cleanup(func() { pluginManager.GetPluginFunc = getPlugin })
+
+	var outputs []string
+	read := func(resp *http.Response, err error) {
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		outputs = append(outputs, string(body))
+	}
+
+	// Through the API
+	read(http.Post(srv.URL+"/api/v1/executions?wait=true", "application/json",
+		strings.NewReader(`{"flow":"secret-flow","params":{"password":"`+paramSecret+`"}}`)))
+	var exec v1beta1.Execution
+	require.NoError(t, json.Unmarshal([]byte(outputs[0]), &exec))
+	assert.Equal(t, v1beta1.ExecutionFailed, exec.Status)
+	assert.Equal(t, redact.Mask, exec.Params["password"])
+	read(http.Get(srv.URL + "/api/v1/executions/" + exec.ID))
+	read(http.Get(srv.URL + "/api/v1/executions/" + exec.ID + "/logs"))
+	read(http.Get(srv.URL + "/api/v1/executions"))
+	read(http.Get(srv.URL + "/api/v1/flows/secret-flow"))
+
+	// Through /flow, with the logger of the server
+	w := httptest.NewRecorder()
+	dynamicFlowHandler(logger, 5*time.Second)(w, httptest.NewRequest(http.MethodGet,
+		"/flow?flowName=secret-flow&params=password:"+paramSecret, nil))
+	outputs = append(outputs, w.Body.String())
+	flowID := w.Header().Get(RequestIDHeader)
+	read(http.Get(srv.URL + "/api/v1/executions/" + flowID))
+
+	outputs = append(outputs, out.String())
+	for _, span := range recorder.Ended() {
+		outputs = append(outputs, span.Name(), span.Status().Description)
+		for _, attr := range span.Attributes() {
+			outputs = append(outputs, attr.Value.Emit())
+		}
+		for _, event := range span.Events() {
+			for _, attr := range event.Attributes {
+				outputs = append(outputs, attr.Value.Emit())
+			}
+		}
+	}
+
+	// Make sure the secrets went through every output path
+	all := strings.Join(outputs, "\n")
+	assert.Contains(t, all, "login with [REDACTED] refused by [REDACTED]")
+	assert.Contains(t, all, "sent to [REDACTED]")
+	assert.Contains(t, out.String(), "posting to [REDACTED] with [REDACTED]")
+	for _, secret := range []string{configSecret, paramSecret, "step-token-secret", "hunter2", "config-secret"} {
+		for i, output := range outputs {
+			assert.NotContains(t, output, secret, "output %d", i)
+		}
+	}
+}
+
+func TestAPIAuditTrail(t *testing.T) {
+	srv := newTestAPI(t, []string{"s3cret"})
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := audit.OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, audit.SetDefault(audit.New(file)))
+	t.Cleanup(func() { _ = audit.SetDefault(audit.New()) })
+
+	post := func(token, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/executions?wait=true", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("nope", `{"flow":"api-flow"}`).StatusCode)
+	resp := post("s3cret", `{"flow":"api-flow","params":{"user":"alice","password":"hunter2"}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	id := resp.Header.Get(RequestIDHeader)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.NotContains(t, string(data), "hunter2")
+	n, err := audit.Verify(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	var events []audit.Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		events = append(events, e)
+	}
+	assert.Equal(t, audit.ActionAuthDenied, events[0].Action)
+	assert.Equal(t, audit.ActorAnonymous, events[0].Actor)
+	assert.Equal(t, "POST /api/v1/executions", events[0].Error)
+
+	started, finished := events[1], events[2]
+	assert.Equal(t, audit.ActionFlowRun, started.Action)
+	assert.Equal(t, audit.OutcomeStarted, started.Outcome)
+	assert.Equal(t, tokenActor("s3cret"), started.Actor)
+	assert.Regexp(t, `^token:[0-9a-f]{8}$`, started.Actor)
+	assert.Equal(t, "203.0.113.7", started.ForwardedFor)
+	assert.NotEmpty(t, started.Source)
+	assert.Equal(t, "api-flow", started.Flow)
+	assert.Equal(t, []string{"api-plugin"}, started.Plugins)
+	assert.Equal(t, id, started.ExecutionID)
+	assert.Equal(t, "alice", started.Params["user"])
+	assert.Equal(t, redact.Mask, started.Params["password"])
+
+	assert.Equal(t, audit.OutcomeSucceeded, finished.Outcome)
+	assert.Equal(t, started.Actor, finished.Actor)
+	assert.Equal(t, id, finished.ExecutionID)
+}
@@ -0,0 +1,87 @@
+// internal/server/audit.go
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+)
+
+// caller is who made a request, as written to the audit trail
+type caller struct {
+	actor        string
+	source       string
+	forwardedFor string
+	userAgent    string
+}
+
+type actorKey struct{}
+
+// tokenActor names the holder of an API token in the audit trail by a
+// fingerprint, so that the token itself is never written
+func tokenActor(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// callerOf returns the caller of r. The actor is set by requireToken; it
+// is anonymous when no tokens are configured and on the /flow endpoint.
+func callerOf(r *http.Request) caller {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	if actor == "" {
+		actor = audit.ActorAnonymous
+	}
+	return caller{
+		actor:        actor,
+		source:       r.RemoteAddr,
+		forwardedFor: r.Header.Get("X-Forwarded-For"),
+		userAgent:    r.UserAgent(),
+	}
+}
+
+func withActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// event returns an audit event of an action of the caller
+func (c caller) event(action, outcome string) audit.Event {
+	return audit.Event{
+		Action:       action,
+		Outcome:      outcome,
+		Actor:        c.actor,
+		Source:       c.source,
+		ForwardedFor: c.forwardedFor,
+		UserAgent:    c.userAgent,
+	}
+}
+
+// flowPlugins returns the plugins the steps of flow run, in order and
+// without repeats
+func flowPlugins(flow v1beta1.Flow) []string {
+	var plugins []string
+	seen := make(map[string]bool)
+	for _, step := range flow.Pipeline {
+		if step.PluginRef != "" && !seen[step.PluginRef] {
+			seen[step.PluginRef] = true
+			plugins = append(plugins, step.PluginRef)
+		}
+	}
+	return plugins
+}
+
+// firstError returns the error of the first failed step of results
+func firstError(results []interface{}) string {
+	for _, res := range results {
+		if result, ok := res.(map[string]interface{}); ok {
+			if err, hasError := result["error"]; hasError {
+				return fmt.Sprintf("step %v: %v", result["step"], err)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,245 @@
+// internal/server/executions.go
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+	"expressops/internal/redact"
+)
+
+// maxStoredExecutions bounds the in-memory execution history
+const maxStoredExecutions = 100
+
+// trackedExecution is an execution plus the handles needed to control it
+type trackedExecution struct {
+	v1beta1.Execution
+	cancel    context.CancelFunc
+	cancelled bool
+	done      chan struct{}
+	logs      *logCapture
+	// redactor masks the secrets of the configuration and those passed
+	// as parameters in everything the execution outputs
+	redactor *redact.Redactor
+	// caller started the execution, and plugins are those of its flow, as
+	// recorded in the audit trail
+	caller  caller
+	plugins []string
+}
+
+// errExecutionExists is returned when starting an execution under the ID
+// of one still stored
+var errExecutionExists = errors.New("an execution with this ID already exists")
+
+// executionStore keeps the most recent executions in memory
+type executionStore struct {
+	mu    sync.Mutex
+	items map[string]*trackedExecution
+	order []string
+	max   int
+}
+
+func newExecutionStore(max int) *executionStore {
+	return &executionStore{
+		items: make(map[string]*trackedExecution),
+		max:   max,
+	}
+}
+
+// executions is the store used by the HTTP API
+var executions = newExecutionStore(maxStoredExecutions)
+
+// newExecutionID returns a random 16-character hex identifier
+func newExecutionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// start registers a new running execution of flow under id, records it in
+// the audit trail and returns it. The values of params named like a secret
+// are masked from then on.
+func (s *executionStore) start(id string, flow v1beta1.Flow, params map[string]interface{}, c caller, cancel context.CancelFunc) (*trackedExecution, error) {
+	redactor := redact.New(redact.Default())
+	redactor.AddFrom(params)
+	exec := &trackedExecution{
+		Execution: v1beta1.Execution{
+			ID:        id,
+			Flow:      flow.Name,
+			Status:    v1beta1.ExecutionRunning,
+			Params:    redactor.Map(params),
+			StartedAt: time.Now().UTC(),
+		},
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		logs:     newLogCapture(),
+		redactor: redactor,
+		caller:   c,
+		plugins:  flowPlugins(flow),
+	}
+
+	s.mu.Lock()
+	if _, exists := s.items[id]; exists {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("execution '%s': %w", id, errExecutionExists)
+	}
+	s.items[exec.ID] = exec
+	s.order = append(s.order, exec.ID)
+	s.evictLocked()
+	s.mu.Unlock()
+
+	e := exec.runEvent(audit.OutcomeStarted)
+	e.Params = exec.Params
+	audit.Record(e)
+	return exec, nil
+}
+
+// runEvent returns the audit event of the run of the execution
+func (exec *trackedExecution) runEvent(outcome string) audit.Event {
+	e := exec.caller.event(audit.ActionFlowRun, outcome)
+	e.Flow, e.Plugins, e.ExecutionID = exec.Flow, exec.plugins, exec.ID
+	return e
+}
+
+// evictLocked drops the oldest finished executions above the store limit
+func (s *executionStore) evictLocked() {
+	for i := 0; len(s.order) > s.max && i < len(s.order); {
+		id := s.order[i]
+		if !s.items[id].Status.Finished() {
+			i++
+			continue
+		}
+		delete(s.items, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
+
+// finish records the results of an execution, marks it terminal and
+// records its outcome in the audit trail
+func (s *executionStore) finish(id string, results []interface{}) {
+	s.mu.Lock()
+	exec, ok := s.items[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+
+	status := v1beta1.ExecutionSucceeded
+	if exec.cancelled {
+		status = v1beta1.ExecutionCancelled
+	} else if resultsHaveError(results) {
+		status = v1beta1.ExecutionFailed
+	}
+
+	now := time.Now().UTC()
+	exec.Status = status
+	exec.Results = exec.redactor.Value(results).([]interface{})
+	exec.FinishedAt = &now
+	close(exec.done)
+	e := exec.runEvent(string(status))
+	e.Error = firstError(exec.Results)
+	s.mu.Unlock()
+
+	audit.Record(e)
+}
+
+// exists reports whether an execution is stored under id
+func (s *executionStore) exists(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[id]
+	return ok
+}
+
+// get returns a snapshot of the execution with the given ID and its logs
+func (s *executionStore) get(id string) (v1beta1.Execution, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, ok := s.items[id]
+	if !ok {
+		return v1beta1.Execution{}, false
+	}
+	snapshot := exec.Execution
+	snapshot.Logs, snapshot.LogsTruncated = exec.logs.snapshot()
+	return snapshot, true
+}
+
+// logs returns the log capture of the execution with the given ID, along
+// with a channel closed once the execution finishes
+func (s *executionStore) logs(id string) (*logCapture, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, ok := s.items[id]
+	if !ok {
+		return nil, nil, false
+	}
+	return exec.logs, exec.done, true
+}
+
+// list returns snapshots of all stored executions, newest first, without
+// their logs
+func (s *executionStore) list() []v1beta1.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]v1beta1.Execution, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		list = append(list, s.items[s.order[i]].Execution)
+	}
+	return list
+}
+
+// cancel requests cancellation of a running execution.
+// It returns false if the execution does not exist.
+func (s *executionStore) cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exec, ok := s.items[id]
+	if !ok {
+		return false
+	}
+	if !exec.Status.Finished() {
+		exec.cancelled = true
+		exec.cancel()
+	}
+	return true
+}
+
+// wait blocks until the execution finishes or ctx is done
+func (s *executionStore) wait(ctx context.Context, id string) {
+	s.mu.Lock()
+	exec, ok := s.items[id]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case <-exec.done:
+	case <-ctx.Done():
+	}
+}
+
+// resultsHaveError reports whether any step result carries an error
+func resultsHaveError(results []interface{}) bool {
+	for _, res := range results {
+		if result, ok := res.(map[string]interface{}); ok {
+			if _, hasError := result["error"]; hasError {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+// internal/server/graph.go
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"expressops/api/v1beta1"
+)
+
+// Supported graph formats
+const (
+	graphFormatMermaid = "mermaid"
+	graphFormatDOT     = "dot"
+)
+
+// Step states used to color a graph after an execution
+const (
+	stepStatusSucceeded = "succeeded"
+	stepStatusFailed    = "failed"
+	stepStatusSkipped   = "skipped"
+	stepStatusNotRun    = "not_run"
+)
+
+// graphStatusColors maps each step state to a fill and stroke color
+var graphStatusColors = map[string][2]string{
+	stepStatusSucceeded: {"#d4edda", "#28a745"},
+	stepStatusFailed:    {"#f8d7da", "#dc3545"},
+	stepStatusSkipped:   {"#e2e3e5", "#6c757d"},
+	stepStatusNotRun:    {"#ffffff", "#adb5bd"},
+}
+
+// graphEdge is a dependency between two steps of the resolved plan
+type graphEdge struct {
+	from, to int
+	implicit bool
+}
+
+// flowGraph is the resolved execution plan of a flow, ready to be rendered
+type flowGraph struct {
+	flow     string
+	labels   []string
+	edges    []graphEdge
+	statuses []string
+	ignored  []string
+}
+
+// buildFlowGraph resolves the plan of a flow exactly as the engine does and,
+// if results are given, assigns a status to every step
+func buildFlowGraph(flow v1beta1.Flow, results []interface{}) *flowGraph {
+	plan := resolveExecutionPlan(flow.Pipeline, nil)
+
+	g := &flowGraph{flow: flow.Name}
+	index := make(map[*stepExecution]int, len(plan))
+	known := make(map[string]bool, len(plan))
+	for i, step := range plan {
+		index[step] = i
+		known[step.id()] = true
+		label := step.id()
+		if label != step.step.PluginRef {
+			label = fmt.Sprintf("%s (%s)", label, step.step.PluginRef)
+		}
+		g.labels = append(g.labels, label)
+	}
+
+	for i, step := range plan {
+		for _, dep := range step.dependencies {
+			g.edges = append(g.edges, graphEdge{from: index[dep], to: i, implicit: step.implicitDependency})
+		}
+		// The engine silently drops dependsOn entries that match no step
+		for _, ref := range step.step.DependsOn {
+			if !known[ref] {
+				g.ignored = append(g.ignored, fmt.Sprintf("%s: dependsOn '%s' matches no step and is ignored", step.id(), ref))
+			}
+		}
+	}
+
+	if results != nil {
+		g.statuses = stepStatuses(plan, results)
+	}
+	return g
+}
+
+// stepStatuses matches execution results to plan steps by step ID. Results
+// without one only carry the plugin name, so they are matched in pipeline order.
+func stepStatuses(plan []*stepExecution, results []interface{}) []string {
+	byStep := make(map[string]string)
+	byPlugin := make(map[string][]string)
+	for _, res := range results {
+		result, ok := res.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		status := stepStatusSucceeded
+		if errMsg, hasError := result["error"]; hasError {
+			status = stepStatusFailed
+			if strings.Contains(fmt.Sprintf("%v", errMsg), "Skipped due to dependency") {
+				status = stepStatusSkipped
+			}
+		} else if _, skipped := result["skipped"]; skipped {
+			status = stepStatusSkipped
+		}
+		if id, ok := result["step"].(string); ok {
+			byStep[id] = status
+			continue
+		}
+		plugin, _ := result["plugin"].(string)
+		byPlugin[plugin] = append(byPlugin[plugin], status)
+	}
+
+	statuses := make([]string, len(plan))
+	for i, step := range plan {
+		ref := step.step.PluginRef
+		if status, ok := byStep[step.id()]; ok {
+			statuses[i] = status
+		} else if pending := byPlugin[ref]; len(pending) > 0 {
+			statuses[i] = pending[0]
+			byPlugin[ref] = pending[1:]
+		} else {
+			statuses[i] = stepStatusNotRun
+		}
+	}
+	return statuses
+}
+
+// render returns the graph in the requested format
+func (g *flowGraph) render(format string) (string, error) {
+	switch format {
+	case "", graphFormatMermaid:
+		return g.mermaid(), nil
+	case graphFormatDOT:
+		return g.dot(), nil
+	default:
+		return "", fmt.Errorf("unsupported graph format '%s' (use %s or %s)", format, graphFormatMermaid, graphFormatDOT)
+	}
+}
+
+func (g *flowGraph) mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	sb.WriteString(fmt.Sprintf("    %%%% flow: %s\n", g.flow))
+	for _, note := range g.ignored {
+		sb.WriteString(fmt.Sprintf("    %%%% %s\n", note))
+	}
+
+	for i, label := range g.labels {
+		sb.WriteString(fmt.Sprintf("    s%d[\"%s\"]\n", i, strings.ReplaceAll(label, `"`, "#quot;")))
+	}
+	for _, e := range g.edges {
+		if e.implicit {
+			sb.WriteString(fmt.Sprintf("    s%d -. implicit .-> s%d\n", e.from, e.to))
+		} else {
+			sb.WriteString(fmt.Sprintf("    s%d --> s%d\n", e.from, e.to))
+		}
+	}
+
+	if g.statuses != nil {
+		for _, status := range []string{stepStatusSucceeded, stepStatusFailed, stepStatusSkipped, stepStatusNotRun} {
+			colors := graphStatusColors[status]
+			sb.WriteString(fmt.Sprintf("    classDef %s fill:%s,stroke:%s\n", status, colors[0], colors[1]))
+		}
+		for i, status := range g.statuses {
+			sb.WriteString(fmt.Sprintf("    class s%d %s\n", i, status))
+		}
+	}
+	return sb.String()
+}
+
+func (g *flowGraph) dot() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("digraph %q {\n", g.flow))
+	sb.WriteString("    rankdir=TB;\n")
+	sb.WriteString("    node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+	for _, note := range g.ignored {
+		sb.WriteString(fmt.Sprintf("    // %s\n", note))
+	}
+
+	for i, label := range g.labels {
+		attrs := fmt.Sprintf("label=%q", label)
+		if g.statuses != nil {
+			colors := graphStatusColors[g.statuses[i]]
+			attrs += fmt.Sprintf(", fillcolor=%q, color=%q, tooltip=%q", colors[0], colors[1], g.statuses[i])
+		}
+		sb.WriteString(fmt.Sprintf("    s%d [%s];\n", i, attrs))
+	}
+	for _, e := range g.edges {
+		if e.implicit {
+			sb.WriteString(fmt.Sprintf("    s%d -> s%d [style=dashed, label=\"implicit\"];\n", e.from, e.to))
+		} else {
+			sb.WriteString(fmt.Sprintf("    s%d -> s%d;\n", e.from, e.to))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
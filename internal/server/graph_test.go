@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+
+	"expressops/api/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func graphTestFlow() v1beta1.Flow {
+	return v1beta1.Flow{
+		Name: "graph-flow",
+		Pipeline: []v1beta1.Step{
+			{PluginRef: "collect"},
+			{PluginRef: "format"},
+			{PluginRef: "audit", Parallel: true},
+			{PluginRef: ""}, // commented out step
+			{PluginRef: "notify", DependsOn: []string{"collect", "typo"}},
+		},
+	}
+}
+
+func TestBuildFlowGraphFollowsEngineRules(t *testing.T) {
+	g := buildFlowGraph(graphTestFlow(), nil)
+
+	assert.Equal(t, []string{"collect", "format", "audit", "notify"}, g.labels)
+	assert.Equal(t, []graphEdge{
+		{from: 0, to: 1, implicit: true},
+		{from: 0, to: 3},
+	}, g.edges)
+	require.Len(t, g.ignored, 1)
+	assert.Contains(t, g.ignored[0], "'typo'")
+	assert.Nil(t, g.statuses)
+}
+
+func TestFlowGraphRendering(t *testing.T) {
+	g := buildFlowGraph(graphTestFlow(), nil)
+
+	mermaid, err := g.render("mermaid")
+	require.NoError(t, err)
+	assert.Contains(t, mermaid, "flowchart TD")
+	assert.Contains(t, mermaid, `s2["audit"]`)
+	assert.Contains(t, mermaid, "s0 -. implicit .-> s1")
+	assert.Contains(t, mermaid, "s0 --> s3")
+	assert.NotContains(t, mermaid, "classDef")
+
+	dot, err := g.render("dot")
+	require.NoError(t, err)
+	assert.Contains(t, dot, `digraph "graph-flow" {`)
+	assert.Contains(t, dot, `s0 -> s1 [style=dashed, label="implicit"];`)
+	assert.Contains(t, dot, "s0 -> s3;")
+
+	_, err = g.render("svg")
+	assert.Error(t, err)
+}
+
+func TestFlowGraphStatuses(t *testing.T) {
+	results := []interface{}{
+		map[string]interface{}{"plugin": "collect", "result": "ok"},
+		map[string]interface{}{"plugin": "format", "error": "Error: boom"},
+		map[string]interface{}{"plugin": "notify", "error": "Skipped due to dependency failure"},
+	}
+
+	g := buildFlowGraph(graphTestFlow(), results)
+	assert.Equal(t, []string{stepStatusSucceeded, stepStatusFailed, stepStatusNotRun, stepStatusSkipped}, g.statuses)
+
+	mermaid, err := g.render("mermaid")
+	require.NoError(t, err)
+	assert.Contains(t, mermaid, "class s1 failed")
+	assert.Contains(t, mermaid, "classDef succeeded fill:#d4edda,stroke:#28a745")
+}
@@ -0,0 +1,265 @@
+// internal/server/limits.go
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+	"expressops/internal/metrics"
+)
+
+// defaultRetryAfter is suggested to a run refused because the flow has too
+// many runs going on, whose end cannot be told in advance
+const defaultRetryAfter = time.Second
+
+// maxCallerBuckets is how many callers are tracked before those that used
+// none of their budget lately are forgotten
+const maxCallerBuckets = 1024
+
+// limitError is returned when a limit refuses a run. RetryAfter is how
+// long until trying again may succeed.
+type limitError struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e *limitError) Error() string {
+	return e.msg
+}
+
+// writeLimited answers a run refused by a limit with 429 Too Many Requests
+// and a Retry-After header in whole seconds
+func writeLimited(w http.ResponseWriter, err *limitError, api bool) {
+	seconds := int(math.Ceil(err.retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	if api {
+		writeAPIError(w, http.StatusTooManyRequests, err.msg)
+		return
+	}
+	http.Error(w, err.msg, http.StatusTooManyRequests)
+}
+
+// tokenBucket allows a number of runs per period on average, and bursts of
+// up to its capacity
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit v1beta1.RateLimit, now time.Time) *tokenBucket {
+	period, _ := limit.PeriodDuration() // checked by Validate
+	if period == 0 {
+		period = time.Second
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.Requests
+	}
+	return &tokenBucket{
+		rate:   float64(limit.Requests) / period.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// take takes a token, or returns how long until one is available
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
+
+// full reports whether the bucket is back to its capacity, in which case
+// it is no different from a new one
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// flowLimiter enforces the rate limit and the concurrency of a flow
+type flowLimiter struct {
+	bucket *tokenBucket
+	// slots holds a token for each run going on, nil without a concurrency
+	// limit
+	slots     chan struct{}
+	queue     bool
+	maxQueued int
+
+	mu     sync.Mutex
+	queued int
+}
+
+// runLimits holds the limits of every flow and of the callers
+type runLimits struct {
+	flows map[string]*flowLimiter
+	// callerLimit gives each caller a bucket, nil without a limit
+	callerLimit *v1beta1.RateLimit
+
+	mu      sync.Mutex
+	callers map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// limits are those of the flows of the config, set by StartServer
+var limits = newRunLimits(&v1beta1.Config{})
+
+func newRunLimits(cfg *v1beta1.Config) *runLimits {
+	l := &runLimits{
+		flows:       make(map[string]*flowLimiter),
+		callerLimit: cfg.Server.Auth.RateLimit,
+		callers:     make(map[string]*tokenBucket),
+		now:         time.Now,
+	}
+	for _, flow := range cfg.Flows {
+		if flow.RateLimit == nil && flow.Concurrency == nil {
+			continue
+		}
+		fl := &flowLimiter{}
+		if flow.RateLimit != nil {
+			fl.bucket = newTokenBucket(*flow.RateLimit, l.now())
+		}
+		if c := flow.Concurrency; c != nil {
+			fl.slots = make(chan struct{}, c.Max)
+			fl.queue = c.Policy == v1beta1.ConcurrencyQueue
+			fl.maxQueued = c.MaxQueued
+		}
+		l.flows[flow.Name] = fl
+	}
+	return l
+}
+
+// callerKey names the budget of a caller: the fingerprint of their token,
+// or their address without one. X-Forwarded-For is not trusted, since any
+// client may set it.
+func callerKey(c caller) string {
+	if c.actor != audit.ActorAnonymous {
+		return c.actor
+	}
+	if host, _, err := net.SplitHostPort(c.source); err == nil {
+		return host
+	}
+	return c.source
+}
+
+// admit checks the limits of a run of flow started by c and takes a slot
+// of the flow. With the queue policy it waits for a slot, for at most wait
+// or until ctx is done. The returned function frees the slot once the run
+// has ended. A refused run gets a *limitError.
+func (l *runLimits) admit(ctx context.Context, flow string, c caller, wait time.Duration) (func(), error) {
+	if l.callerLimit != nil {
+		if retry, ok := l.callerBucket(callerKey(c)).take(l.now()); !ok {
+			metrics.RecordFlowRejected(flow, metrics.RejectedCallerRateLimit)
+			return nil, &limitError{msg: "Rate limit exceeded, too many runs started by this caller", retryAfter: retry}
+		}
+	}
+
+	fl, ok := l.flows[flow]
+	if !ok {
+		return func() {}, nil
+	}
+	if fl.bucket != nil {
+		if retry, ok := fl.bucket.take(l.now()); !ok {
+			metrics.RecordFlowRejected(flow, metrics.RejectedRateLimit)
+			return nil, &limitError{msg: fmt.Sprintf("Rate limit of flow '%s' exceeded", flow), retryAfter: retry}
+		}
+	}
+	if fl.slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-fl.slots }
+	select {
+	case fl.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if !fl.queue {
+		metrics.RecordFlowRejected(flow, metrics.RejectedConcurrency)
+		return nil, &limitError{
+			msg:        fmt.Sprintf("Flow '%s' already has %d runs going on", flow, cap(fl.slots)),
+			retryAfter: defaultRetryAfter,
+		}
+	}
+
+	fl.mu.Lock()
+	if fl.maxQueued > 0 && fl.queued >= fl.maxQueued {
+		fl.mu.Unlock()
+		metrics.RecordFlowRejected(flow, metrics.RejectedQueueFull)
+		return nil, &limitError{
+			msg:        fmt.Sprintf("Flow '%s' already has %d runs waiting", flow, fl.maxQueued),
+			retryAfter: defaultRetryAfter,
+		}
+	}
+	fl.queued++
+	fl.mu.Unlock()
+	done := metrics.StartQueued(flow)
+	defer func() {
+		done()
+		fl.mu.Lock()
+		fl.queued--
+		fl.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	select {
+	case fl.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		metrics.RecordFlowRejected(flow, metrics.RejectedQueueTimeout)
+		return nil, &limitError{
+			msg:        fmt.Sprintf("Gave up waiting for a run of flow '%s' to finish", flow),
+			retryAfter: defaultRetryAfter,
+		}
+	}
+}
+
+// callerBucket returns the bucket of the caller key, forgetting the
+// callers with a full budget once too many are tracked
+func (l *runLimits) callerBucket(key string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if b, ok := l.callers[key]; ok {
+		return b
+	}
+	if len(l.callers) >= maxCallerBuckets {
+		for k, b := range l.callers {
+			if b.full(now) {
+				delete(l.callers, k)
+			}
+		}
+	}
+	b := newTokenBucket(*l.callerLimit, now)
+	l.callers[key] = b
+	return b
+}
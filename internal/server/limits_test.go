@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/audit"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(v1beta1.RateLimit{Requests: 2, Period: "1m", Burst: 3}, now)
+
+	for i := 0; i < 3; i++ {
+		_, ok := b.take(now)
+		require.True(t, ok, "burst token %d", i)
+	}
+	retry, ok := b.take(now)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, retry)
+
+	_, ok = b.take(now.Add(30 * time.Second))
+	assert.True(t, ok)
+	assert.False(t, b.full(now.Add(30*time.Second)))
+	assert.True(t, b.full(now.Add(2*time.Minute)))
+}
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	l := newRunLimits(&v1beta1.Config{Flows: []v1beta1.Flow{
+		{Name: "reject", Concurrency: &v1beta1.Concurrency{Max: 1}},
+		{Name: "queue", Concurrency: &v1beta1.Concurrency{Max: 1, Policy: v1beta1.ConcurrencyQueue, MaxQueued: 1}},
+	}})
+	ctx := context.Background()
+	c := caller{actor: audit.ActorAnonymous, source: "10.0.0.1:1234"}
+
+	release, err := l.admit(ctx, "reject", c, time.Second)
+	require.NoError(t, err)
+	_, err = l.admit(ctx, "reject", c, time.Second)
+	var limited *limitError
+	require.ErrorAs(t, err, &limited)
+	assert.Contains(t, limited.msg, "already has 1 runs going on")
+	release()
+	release, err = l.admit(ctx, "reject", c, time.Second)
+	require.NoError(t, err)
+	release()
+
+	// A queued run starts once the running one ends
+	release, err = l.admit(ctx, "queue", c, time.Second)
+	require.NoError(t, err)
+	admitted := make(chan func())
+	go func() {
+		next, err := l.admit(ctx, "queue", c, 5*time.Second)
+		assert.NoError(t, err)
+		admitted <- next
+	}()
+	require.Eventually(t, func() bool {
+		fl := l.flows["queue"]
+		fl.mu.Lock()
+		defer fl.mu.Unlock()
+		return fl.queued == 1
+	}, time.Second, time.Millisecond)
+
+	// The queue is full
+	_, err = l.admit(ctx, "queue", c, time.Second)
+	require.ErrorAs(t, err, &limited)
+	assert.Contains(t, limited.msg, "already has 1 runs waiting")
+
+	release()
+	next := <-admitted
+	// Waiting gives up after its timeout
+	_, err = l.admit(ctx, "queue", c, 10*time.Millisecond)
+	require.ErrorAs(t, err, &limited)
+	assert.Contains(t, limited.msg, "Gave up waiting")
+	next()
+
+	// Flows without limits are always admitted
+	release, err = l.admit(ctx, "unlimited", c, time.Second)
+	require.NoError(t, err)
+	release()
+}
+
+func TestRunLimitsRate(t *testing.T) {
+	l := newRunLimits(&v1beta1.Config{
+		Server: v1beta1.ServerConfig{Auth: v1beta1.AuthConfig{RateLimit: &v1beta1.RateLimit{Requests: 1, Period: "1m"}}},
+		Flows:  []v1beta1.Flow{{Name: "f", RateLimit: &v1beta1.RateLimit{Requests: 2, Period: "1h"}}},
+	})
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+	alice := caller{actor: "token:0000aaaa", source: "10.0.0.1:1234"}
+	bob := caller{actor: "token:0000bbbb", source: "10.0.0.1:1234"}
+	anonymous := caller{actor: audit.ActorAnonymous, source: "10.0.0.2:1234"}
+
+	_, err := l.admit(ctx, "f", alice, time.Second)
+	require.NoError(t, err)
+	// Each caller has a budget of their own
+	_, err = l.admit(ctx, "f", alice, time.Second)
+	var limited *limitError
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, time.Minute, limited.retryAfter)
+	_, err = l.admit(ctx, "f", bob, time.Second)
+	require.NoError(t, err)
+
+	// The flow has its own budget, whoever the caller
+	_, err = l.admit(ctx, "f", anonymous, time.Second)
+	require.ErrorAs(t, err, &limited)
+	assert.Equal(t, "Rate limit of flow 'f' exceeded", limited.msg)
+	assert.Equal(t, 30*time.Minute, limited.retryAfter)
+
+	now = now.Add(time.Minute)
+	_, err = l.admit(ctx, "other", alice, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", callerKey(anonymous))
+}
+
+func TestAPIRateLimited(t *testing.T) {
+	srv := newTestAPI(t, nil)
+	limits = newRunLimits(&v1beta1.Config{Flows: []v1beta1.Flow{
+		{Name: "api-flow", RateLimit: &v1beta1.RateLimit{Requests: 2, Period: "1h"}},
+	}})
+	t.Cleanup(func() { limits = newRunLimits(&v1beta1.Config{}) })
+
+	run := func(id string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/executions?wait=true", strings.NewReader(`{"flow":"api-flow"}`))
+		require.NoError(t, err)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, run("limited-1").StatusCode)
+	// A duplicate ID is refused without using up the budget
+	assert.Equal(t, http.StatusConflict, run("limited-1").StatusCode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/flow?flowName=api-flow", nil)
+	req.Header.Set(RequestIDHeader, "limited-1")
+	dynamicFlowHandler(logger, time.Second).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, http.StatusOK, run("").StatusCode)
+
+	resp := run("")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1800", resp.Header.Get("Retry-After"))
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Rate limit of flow 'api-flow' exceeded", body["error"])
+
+	// The /flow endpoint shares the limits
+	rec = httptest.NewRecorder()
+	dynamicFlowHandler(logger, time.Second).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/flow?flowName=api-flow", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1800", rec.Header().Get("Retry-After"))
+}
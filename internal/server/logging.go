@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"expressops/api/v1beta1"
+	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/redact"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID of an execution. A caller may set it to
+// choose the ID; responses always carry it.
+const RequestIDHeader = "X-Request-ID"
+
+// Limits on the lines kept for a single execution. Lines past either limit
+// are dropped, so that a chatty plugin cannot exhaust the memory of the
+// server.
+const (
+	maxExecutionLogLines = 1000
+	maxExecutionLogBytes = 256 << 10
+)
+
+// requestID returns the execution ID asked for by the request, or a new one
+// when it asks for none or for one that is not a short printable token
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > 128 {
+		return newExecutionID()
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' || c == '/' {
+			return newExecutionID()
+		}
+	}
+	return id
+}
+
+// logCapture is a logrus hook keeping the lines logged during an execution
+type logCapture struct {
+	mu        sync.Mutex
+	lines     []v1beta1.ExecutionLog
+	size      int
+	truncated bool
+	// updated is closed, and replaced, whenever a line is kept
+	updated chan struct{}
+}
+
+func newLogCapture() *logCapture {
+	return &logCapture{updated: make(chan struct{})}
+}
+
+func (c *logCapture) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (c *logCapture) Fire(entry *logrus.Entry) error {
+	fields := make(map[string]interface{}, len(entry.Data))
+	size := len(entry.Message)
+	for k, v := range entry.Data {
+		// Every line of the execution has the same ID and flow
+		if k == "execution_id" || k == "flow" {
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		fields[k] = v
+		size += len(k) + len(fmt.Sprint(v))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.truncated {
+		return nil
+	}
+	line := v1beta1.ExecutionLog{
+		Time:    entry.Time.UTC(),
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+	}
+	if len(fields) > 0 {
+		line.Fields = fields
+	}
+	if len(c.lines) >= maxExecutionLogLines || c.size+size > maxExecutionLogBytes {
+		// Say so once, so that readers of the logs know lines are missing
+		c.truncated = true
+		line = v1beta1.ExecutionLog{
+			Time:    line.Time,
+			Level:   logrus.WarnLevel.String(),
+			Message: fmt.Sprintf("Log limit of %d lines or %d bytes reached, further lines of this execution are dropped", maxExecutionLogLines, maxExecutionLogBytes),
+		}
+	}
+	c.lines = append(c.lines, line)
+	c.size += size
+	close(c.updated)
+	c.updated = make(chan struct{})
+	return nil
+}
+
+// snapshot returns a copy of the lines captured so far
+func (c *logCapture) snapshot() ([]v1beta1.ExecutionLog, bool) {
+	lines, truncated, _ := c.since(0)
+	return lines, truncated
+}
+
+// since returns a copy of the lines captured after the first offset ones,
+// along with a channel closed when another line is captured
+func (c *logCapture) since(offset int) ([]v1beta1.ExecutionLog, bool, <-chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lines []v1beta1.ExecutionLog
+	if offset < len(c.lines) {
+		lines = append(lines, c.lines[offset:]...)
+	}
+	return lines, c.truncated, c.updated
+}
+
+// newExecutionLogger returns a logger writing where base does, with its
+// level, formatter and hooks, that also masks the secrets of redactor and
+// keeps every line in capture
+func newExecutionLogger(base *logrus.Logger, redactor *redact.Redactor, capture *logCapture) *logrus.Logger {
+	logger := &logrus.Logger{
+		Out:          base.Out,
+		Formatter:    base.Formatter,
+		Level:        base.GetLevel(),
+		ReportCaller: base.ReportCaller,
+		ExitFunc:     base.ExitFunc,
+		Hooks:        make(logrus.LevelHooks),
+	}
+	for level, hooks := range base.Hooks {
+		logger.Hooks[level] = append([]logrus.Hook(nil), hooks...)
+	}
+	// Masking comes first so that no secret is captured
+	logger.AddHook(redactor.Hook())
+	logger.AddHook(capture)
+	return logger
+}
+
+// withExecutionLogger returns ctx carrying the logger and the redactor of
+// exec, along with that logger. Its lines go where logger writes, with the
+// execution_id and flow fields, and are kept with exec.
+func withExecutionLogger(ctx context.Context, exec *trackedExecution, logger *logrus.Logger) (context.Context, *logrus.Entry) {
+	entry := newExecutionLogger(logger, exec.redactor, exec.logs).WithFields(logrus.Fields{
+		"execution_id": exec.ID,
+		"flow":         exec.Flow,
+	})
+	ctx = redact.WithRedactor(ctx, exec.redactor)
+	return pluginManager.WithLogger(ctx, entry), entry
+}
@@ -5,28 +5,35 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	"expressops/api/v1alpha1"
+	"expressops/api/v1beta1"
+	"expressops/internal/expr"
 	"expressops/internal/metrics"
 	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/redact"
+	"expressops/internal/secrets"
+	"expressops/internal/tracing"
 
 	"github.com/sirupsen/logrus"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // registry of flows
-var flowRegistry map[string]v1alpha1.Flow
+var flowRegistry map[string]v1beta1.Flow
 
 // initializeFlowRegistry loads the flows defined in the configuration file
-
-func initializeFlowRegistry(cfg *v1alpha1.Config, logger *logrus.Logger) {
-	flowRegistry = make(map[string]v1alpha1.Flow)
+// along with their limits
+func initializeFlowRegistry(cfg *v1beta1.Config, logger *logrus.Logger) {
+	limits = newRunLimits(cfg)
+	flowRegistry = make(map[string]v1beta1.Flow)
 	for _, flow := range cfg.Flows {
 		flowRegistry[flow.Name] = flow
 		logger.Infof("Flow registered: %s", flow.Name)
@@ -34,7 +41,7 @@ func initializeFlowRegistry(cfg *v1alpha1.Config, logger *logrus.Logger) {
 }
 
 // StartServer initializes and starts the HTTP server with the provided configuration
-func StartServer(cfg *v1alpha1.Config, logger *logrus.Logger) {
+func StartServer(cfg *v1beta1.Config, logger *logrus.Logger) {
 	initializeFlowRegistry(cfg, logger)
 
 	// Start resource monitoring routine (metrics will already be initialized by expressops.go)
@@ -42,11 +49,9 @@ func StartServer(cfg *v1alpha1.Config, logger *logrus.Logger) {
 
 	address := fmt.Sprintf("%s:%d", cfg.Server.Address, cfg.Server.Port)
 
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		startTime := time.Now()
+	http.Handle("/healthz", metrics.Handler("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userAgent := r.Header.Get("User-Agent")
 		probeTypeLabel := "manual_curl"
-		httpStatusCode := http.StatusOK
 
 		// Check if the request is from a Kubernetes liveness/readiness probe
 		if strings.HasPrefix(userAgent, "kube-probe/") {
@@ -56,26 +61,25 @@ func StartServer(cfg *v1alpha1.Config, logger *logrus.Logger) {
 		// Log the request
 		logger.Infof("Health check request received on /healthz from User-Agent: %s, identified as: %s", userAgent, probeTypeLabel)
 
-		metrics.IncKubernetesProbe(probeTypeLabel, "/healthz")
-		metrics.IncFlowExecuted("healthz", "success")
+		metrics.IncHealthProbe(probeTypeLabel)
 
-		// You could add actual health checks here. If they fail, set status to "error" and httpStatusCode accordingly.
-		// For now, always success.
+		// You could add actual health checks here. For now, always success.
 		w.Header().Set("Content-Type", "text/plain")
 		if _, err := w.Write([]byte("OK")); err != nil {
 			logger.WithError(err).Error("Error writing response")
 		}
-
-		duration := time.Since(startTime).Seconds()
-		metrics.ObserveFlowDuration("healthz", "success", duration) // Flow duration for healthz
-		metrics.IncHTTPRequestsTotal(r.URL.Path, r.Method, httpStatusCode)
-		metrics.ObserveHTTPRequestDuration(r.URL.Path, r.Method, httpStatusCode, duration)
-	})
+	})))
 
 	timeout := time.Duration(cfg.Server.TimeoutSec) * time.Second
 
 	// ONLY one generic handler that will handle all flows
-	http.HandleFunc("/flow", metricsMiddleware(dynamicFlowHandler(logger, timeout), logger))
+	http.Handle("/flow", tracing.Handler("/flow", metrics.Handler("/flow", dynamicFlowHandler(logger, timeout))))
+
+	// REST API used by `expressops client`
+	registerAPIRoutes(http.DefaultServeMux, cfg, logger, timeout)
+	if len(cfg.Server.Auth.Tokens) == 0 {
+		logger.Warn("No API tokens configured, /api/v1 endpoints are unauthenticated")
+	}
 
 	// Prometheus metrics endpoint
 	http.Handle("/metrics", metrics.MetricsHandler())
@@ -83,12 +87,9 @@ func StartServer(cfg *v1alpha1.Config, logger *logrus.Logger) {
 	logger.Infof("Server listening on http://%s", address)
 	logger.Infof("Prometheus metrics available at http://%s/metrics", address)
 
-	// Prometheus metrics endpoint
-	http.Handle("/metrics", promhttp.Handler())
-	logger.Info("Metrics endpoint registered at /metrics")
-
 	// help for the user
 	logger.Infof("➡️ curl http://%s/flow?flowName=<flow_name> ⬅️", address)
+	logger.Infof("➡️ expressops client --server http://%s flows list ⬅️", address)
 
 	srv := &http.Server{Addr: address}
 
@@ -131,7 +132,7 @@ func monitorResourceUsage(logger *logrus.Logger) {
 		// Extract and update CPU metrics
 		if cpuInfo, ok := healthData["cpu"].(map[string]interface{}); ok {
 			if cpuPercent, ok := cpuInfo["usage_percent"].(float64); ok {
-				metrics.RecordCpuUsage(cpuPercent)
+				metrics.SetCPUUsage(cpuPercent)
 				logger.Debugf("Updated CPU usage: %.2f%%", cpuPercent)
 			}
 		}
@@ -139,7 +140,7 @@ func monitorResourceUsage(logger *logrus.Logger) {
 		// Extract and update memory metrics
 		if memInfo, ok := healthData["memory"].(map[string]interface{}); ok {
 			if used, ok := memInfo["used"].(uint64); ok {
-				metrics.RecordMemoryUsage(float64(used))
+				metrics.SetMemoryUsage(float64(used))
 				logger.Debugf("Updated memory usage: %d bytes", used)
 			}
 		}
@@ -155,141 +156,72 @@ func monitorResourceUsage(logger *logrus.Logger) {
 					}
 				}
 			}
-			metrics.UpdateStorageUsage(float64(totalUsed))
+			metrics.SetStorageUsage(float64(totalUsed))
 			logger.Debugf("Updated storage usage: %d bytes", totalUsed)
 		}
 
-		// Update active plugins count
-		activePlugins := 0
-		globalPlanMutex.Lock()
-		// Count plugins currently executing
-		for _, steps := range globalStepPlan {
-			for _, step := range steps {
-				if !step.executed {
-					activePlugins++
-				}
-			}
-		}
-		globalPlanMutex.Unlock()
-		metrics.UpdateConcurrentPlugins(activePlugins)
-		metrics.SetActivePlugins(activePlugins)
-
 		logger.Debug("Updated all resource metrics from health-check-plugin")
 	}
 }
 
-// Middleware to record Prometheus metrics
-func metricsMiddleware(next http.HandlerFunc, logger *logrus.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		flowName := r.URL.Query().Get("flowName")
-		if flowName == "" {
-			flowName = "unknown"
-		}
-
-		startTime := time.Now()
-
-		// Create wrapper to capture status code
-		mw := newMetricsResponseWriter(w)
-
-		// Record HTTP request started
-		metrics.RecordHttpRequest(r.URL.Path, r.Method, 200)
-
-		// Call original handler
-		next(mw, r)
-
-		// Record metrics
-		duration := time.Since(startTime)
-		success := mw.statusCode < 400
-
-		// Record flow execution with success status
-		metrics.RecordFlowExecution(flowName, success)
-
-		// Record flow duration
-		metrics.RecordFlowDuration(flowName, duration)
-
-		// Record final HTTP status
-		metrics.RecordHttpRequest(r.URL.Path, r.Method, mw.statusCode)
-
-		logger.WithFields(logrus.Fields{
-			"flow":        flowName,
-			"duration_ms": duration.Milliseconds(),
-			"status_code": mw.statusCode,
-		}).Debug("Flow execution metrics recorded")
-	}
-}
-
-// Wrapper for ResponseWriter that captures status code
-type metricsResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func newMetricsResponseWriter(w http.ResponseWriter) *metricsResponseWriter {
-	return &metricsResponseWriter{w, http.StatusOK}
-}
-
-func (mw *metricsResponseWriter) WriteHeader(code int) {
-	mw.statusCode = code
-	mw.ResponseWriter.WriteHeader(code)
-}
-
 // dynamicFlowHandler handles requests to /flow and executes configured flows
 func dynamicFlowHandler(logger *logrus.Logger, timeout time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		metrics.IncActiveFlowHandlers()
-		defer metrics.DecActiveFlowHandlers()
-
-		startTime := time.Now()
-		httpStatusCode := http.StatusOK
-
-		ctx, cancel := context.WithTimeout(r.Context(), timeout) // if it takes more than 4 seconds, it will be killed
-
-		defer cancel()
-
 		// Validate and get flow
 		flowName := r.URL.Query().Get("flowName")
 		if flowName == "" {
-			httpStatusCode = http.StatusBadRequest
-			errMsg := "Must indicate flowName"
-			http.Error(w, errMsg, httpStatusCode)
-
-			duration := time.Since(startTime).Seconds()
-			metrics.IncHTTPRequestsTotal(r.URL.Path, r.Method, httpStatusCode)
-			metrics.ObserveHTTPRequestDuration(r.URL.Path, r.Method, httpStatusCode, duration)
-			metrics.IncFlowExecution(flowName, "error_bad_request")
-
+			http.Error(w, "Must indicate flowName", http.StatusBadRequest)
 			return
 		}
 
 		flow, exists := flowRegistry[flowName]
 		if !exists {
-			httpStatusCode = http.StatusNotFound
-			errMsg := fmt.Sprintf("Flow '%s' not found", flowName)
-			http.Error(w, errMsg, httpStatusCode)
-
-			// Errors Metrics
-			duration := time.Since(startTime).Seconds()
-			metrics.IncFlowExecuted(flowName, "error_flow_not_found")
-			metrics.ObserveFlowDuration(flowName, "error_flow_not_found", duration)
-			metrics.IncHTTPRequestsTotal(r.URL.Path, r.Method, httpStatusCode)
-			metrics.ObserveHTTPRequestDuration(r.URL.Path, r.Method, httpStatusCode, duration)
+			http.Error(w, fmt.Sprintf("Flow '%s' not found", flowName), http.StatusNotFound)
+			return
+		}
+
+		// A run under the ID of a stored execution is refused before it
+		// uses up any of the limits
+		id := requestID(r)
+		if executions.exists(id) {
+			http.Error(w, fmt.Sprintf("execution '%s': %v", id, errExecutionExists), http.StatusConflict)
+			return
+		}
+
+		// A queued run waits before its timeout starts
+		c := callerOf(r)
+		release, err := limits.admit(r.Context(), flowName, c, timeout)
+		var limited *limitError
+		if errors.As(err, &limited) {
+			writeLimited(w, limited, false)
 			return
 		}
+		defer release()
 
-		// Log execution info
-		logger.WithFields(logrus.Fields{
-			"flow": flowName, "ip": r.RemoteAddr,
-		}).Info("Executing flow")
+		ctx, cancel := context.WithTimeout(r.Context(), timeout) // if it takes more than 4 seconds, it will be killed
+
+		defer cancel()
 
 		// Process params
 		params := parseParams(r.URL.Query().Get("params"))
 		isAllFlowsFlow := flowName == "all-flows"
 
+		// Track the run like API executions, so that its result and logs
+		// can be fetched by ID
+		exec, err := executions.start(id, flow, params, c, cancel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set(RequestIDHeader, exec.ID)
+		ctx, execLogger := withExecutionLogger(ctx, exec, logger)
+		execLogger.WithField("ip", r.RemoteAddr).Info("Executing flow")
+
 		// Execute and prepare response
-		results := executeFlow(ctx, flow, params, r, logger, isAllFlowsFlow)
+		results := executeFlow(ctx, flow, params, r.WithContext(ctx), logger, isAllFlowsFlow)
+		executions.finish(exec.ID, results)
 		response := map[string]interface{}{
-			"flow": flowName, "success": true, "count": len(results),
+			"flow": flowName, "execution_id": exec.ID, "success": true, "count": len(results),
 		}
 
 		flowSucceeded := true
@@ -304,22 +236,6 @@ func dynamicFlowHandler(logger *logrus.Logger, timeout time.Duration) http.Handl
 		}
 		response["success"] = flowSucceeded
 
-		if flowSucceeded {
-			metrics.IncFlowExecuted(flowName, "success")
-		} else {
-			metrics.IncFlowExecuted(flowName, "error")
-		}
-
-		duration := time.Since(startTime).Seconds()
-		metrics.ObserveFlowDuration(flowName, "success", duration)
-
-		metrics.IncHTTPRequestsTotal(r.URL.Path, r.Method, httpStatusCode)
-		metrics.ObserveHTTPRequestDuration(r.URL.Path, r.Method, httpStatusCode, duration)
-
-		if httpStatusCode != http.StatusOK {
-			w.WriteHeader(httpStatusCode)
-		}
-
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			logger.WithError(err).Error("Error encoding JSON response")
 		}
@@ -345,55 +261,65 @@ func parseParams(paramsRaw string) map[string]interface{} {
 // Represents a step in the pipeline with its execution context
 // https://github.com/saantiaguilera/go-pipeline/blob/master/step.go
 type stepExecution struct {
-	step         v1alpha1.Step
+	step         v1beta1.Step
 	index        int
 	sharedCtx    map[string]interface{} //dependencies, result, flags for execution
+	params       map[string]interface{} // request params and step parameters, as seen by inputs
 	result       interface{}
+	outputs      map[string]interface{}
 	dependencies []*stepExecution
-	executed     bool
-	hasError     bool
+	// started is set when the last of its dependencies starts the step, and
+	// executed once the step is done; both are guarded by the mutex of the
+	// execution
+	started  bool
+	executed bool
+	hasError bool
+	// skipped is set when the step did not run because its condition did not hold
+	skipped bool
+	// implicitDependency is set when the step waits on the previous one only
+	// because it declares neither dependsOn nor parallel
+	implicitDependency bool
+	// span covers the step once its dependencies are done
+	span trace.Span
+	// logger adds the step field to the lines of the execution
+	logger *logrus.Entry
 }
 
-// Global registry to track dependencies between steps for the current execution
-var (
-	globalPlanMutex sync.Mutex
-	globalStepPlan  map[string][]*stepExecution
-	// Track dependencies - which steps depend on this one
-	globalDependents map[*stepExecution][]*stepExecution
-)
-
-// Initialize the global execution plan trackers
-func initializeExecutionPlanTrackers(plan []*stepExecution) {
-	globalPlanMutex.Lock()
-	defer globalPlanMutex.Unlock()
+// id returns the step ID, which defaults to the plugin name
+func (s *stepExecution) id() string {
+	if s.step.ID != "" {
+		return s.step.ID
+	}
+	return s.step.PluginRef
+}
 
-	globalStepPlan = make(map[string][]*stepExecution)
-	globalDependents = make(map[*stepExecution][]*stepExecution)
+// status returns the outcome of the step as labeled in metrics
+func (s *stepExecution) status() string {
+	switch {
+	case s.hasError:
+		return metrics.StatusFailed
+	case s.skipped:
+		return metrics.StatusSkipped
+	}
+	return metrics.StatusSucceeded
+}
 
+// dependentsOf returns, for each step of plan, the steps that depend on it
+func dependentsOf(plan []*stepExecution) map[*stepExecution][]*stepExecution {
+	dependents := make(map[*stepExecution][]*stepExecution, len(plan))
 	for _, step := range plan {
-		// Register step
-		pluginRef := step.step.PluginRef
-		globalStepPlan[pluginRef] = append(globalStepPlan[pluginRef], step)
-
-		// Create dependents list if needed
-		if _, exists := globalDependents[step]; !exists {
-			globalDependents[step] = make([]*stepExecution, 0)
-		}
-
-		// Register reverse dependencies
 		for _, dep := range step.dependencies {
-			if _, exists := globalDependents[dep]; !exists {
-				globalDependents[dep] = make([]*stepExecution, 0)
-			}
-			globalDependents[dep] = append(globalDependents[dep], step)
+			dependents[dep] = append(dependents[dep], step)
 		}
 	}
+	return dependents
 }
 
-// buildExecutionPlan creates a plan of steps to execute from the flow pipeline
-func buildExecutionPlan(pipeline []v1alpha1.Step, shared map[string]interface{}) []*stepExecution {
+// resolveExecutionPlan turns a pipeline into steps with their dependencies resolved.
+// It has no side effects, so it is also used to render flow graphs.
+func resolveExecutionPlan(pipeline []v1beta1.Step, shared map[string]interface{}) []*stepExecution {
 	var execSteps []*stepExecution
-	pluginRefToStep := make(map[string]*stepExecution)
+	idToStep := make(map[string]*stepExecution)
 
 	// First pass: Create step objects
 	for i, step := range pipeline {
@@ -412,15 +338,23 @@ func buildExecutionPlan(pipeline []v1alpha1.Step, shared map[string]interface{})
 			stepCtx[k] = v
 		}
 
+		params := make(map[string]interface{}, len(stepCtx))
+		for k, v := range stepCtx {
+			if k != "flow_registry" {
+				params[k] = v
+			}
+		}
+
 		exec := &stepExecution{
 			step:         step,
 			index:        i,
 			sharedCtx:    stepCtx,
+			params:       params,
 			dependencies: make([]*stepExecution, 0),
 		}
 
 		execSteps = append(execSteps, exec)
-		pluginRefToStep[step.PluginRef] = exec
+		idToStep[exec.id()] = exec
 	}
 
 	// Second pass: Resolve dependencies
@@ -429,7 +363,7 @@ func buildExecutionPlan(pipeline []v1alpha1.Step, shared map[string]interface{})
 		if len(execStep.step.DependsOn) > 0 {
 			// Process explicit dependencies
 			for _, depRef := range execStep.step.DependsOn {
-				if depStep, exists := pluginRefToStep[depRef]; exists {
+				if depStep, exists := idToStep[depRef]; exists {
 					execStep.dependencies = append(execStep.dependencies, depStep)
 				}
 			}
@@ -437,24 +371,27 @@ func buildExecutionPlan(pipeline []v1alpha1.Step, shared map[string]interface{})
 			// Fallback: This step depends on the previous one if not marked as parallel
 			// and has no explicit dependencies
 			execStep.dependencies = append(execStep.dependencies, execSteps[i-1])
+			execStep.implicitDependency = true
 		}
 	}
 
-	// Initialize the global trackers
-	initializeExecutionPlanTrackers(execSteps)
-
 	return execSteps
 }
 
 // Execution context shared across all steps
 type executionContext struct {
 	ctx      context.Context
-	logger   *logrus.Logger
+	flow     string
+	logger   *logrus.Entry
 	request  *http.Request
 	wg       *sync.WaitGroup
 	mutex    *sync.Mutex
 	results  *[]interface{}
 	allFlows bool
+	// redactor masks the secrets of the execution in span attributes
+	redactor *redact.Redactor
+	// dependents lists the steps waiting on each step of this execution
+	dependents map[*stepExecution][]*stepExecution
 }
 
 // Execute all steps in the plan, respecting dependencies
@@ -471,65 +408,103 @@ func executeSteps(plan []*stepExecution, execCtx *executionContext) {
 // Execute a single step asynchronously
 func executeStepAsync(step *stepExecution, execCtx *executionContext) {
 	defer execCtx.wg.Done()
-
-	// Increment concurrent plugins counter
-	metrics.UpdateConcurrentPlugins(1)
-	defer metrics.UpdateConcurrentPlugins(-1) // Decrease counter when done
+	step.logger = execCtx.logger.WithField("step", step.id())
 
 	// Wait for dependencies in parallel
 	var depWg sync.WaitGroup
-	depErr := false
+	var depMu sync.Mutex
+	depErr, depSkipped := false, false
 
 	for _, dep := range step.dependencies {
 		depWg.Add(1)
 		go func(dependency *stepExecution) {
 			defer depWg.Done()
-			for !dependency.executed {
+			for !execCtx.finished(dependency) {
 				time.Sleep(5 * time.Millisecond)
 			}
+			depMu.Lock()
+			defer depMu.Unlock()
 			if dependency.hasError {
 				depErr = true
 			}
+			if dependency.skipped {
+				depSkipped = true
+			}
 		}(dep)
 	}
 
 	depWg.Wait()
 
-	// Skip if any dependency failed
-	if depErr {
-		markStepFailed(step, execCtx, "Skipped due to dependency failure")
-		metrics.RecordPluginError(step.step.PluginRef, "dependency_failure")
-		return
+	ctx, span := tracing.Start(execCtx.ctx, "step "+step.id(), trace.WithAttributes(
+		attribute.String("step.id", step.id()),
+		attribute.String("plugin.ref", step.step.PluginRef),
+	))
+	defer span.End()
+	step.span = span
+	ctx = pluginManager.WithLogger(ctx, step.logger)
+
+	done := metrics.StartStep(execCtx.flow, step.id(), step.step.PluginRef)
+	defer func() { done(step.status()) }()
+
+	// Check the step condition against the outcome of its dependencies
+	switch step.step.Condition {
+	case v1beta1.ConditionAlways:
+	case v1beta1.ConditionOnFailure:
+		if !depErr {
+			markStepSkipped(step, execCtx, "condition on_failure not met")
+			return
+		}
+	default:
+		if depErr {
+			markStepFailed(step, execCtx, "Skipped due to dependency failure")
+			return
+		}
+		if depSkipped {
+			markStepSkipped(step, execCtx, "a dependency was skipped")
+			return
+		}
 	}
 
 	// Add dependency results to context
 	for _, dep := range step.dependencies {
 		step.sharedCtx[fmt.Sprintf("%s_result", dep.step.PluginRef)] = dep.result
+		if dep.id() != dep.step.PluginRef {
+			step.sharedCtx[fmt.Sprintf("%s_result", dep.id())] = dep.result
+		}
 		step.sharedCtx["previous_result"] = dep.result // backward compatibility
 		step.sharedCtx["_input"] = dep.result
 	}
 
-	// Get and execute plugin
-	plugin, err := pluginManager.GetPlugin(step.step.PluginRef)
-	if err != nil {
-		markStepFailed(step, execCtx, fmt.Sprintf("Plugin not found: %v", err))
-		metrics.RecordPluginError(step.step.PluginRef, "plugin_not_found")
+	if err := resolveSecrets(ctx, step); err != nil {
+		markStepFailed(step, execCtx, fmt.Sprintf("Error resolving secrets: %v", err))
 		return
 	}
 
-	// Start measuring plugin execution time
-	pluginStartTime := time.Now()
-
-	execCtx.logger.Infof("Executing plugin: %s", step.step.PluginRef)
-	res, err := plugin.Execute(execCtx.ctx, execCtx.request, &step.sharedCtx)
+	// Explicit inputs take precedence over the keys above
+	if err := resolveInputs(step); err != nil {
+		markStepFailed(step, execCtx, fmt.Sprintf("Error resolving inputs: %v", err))
+		return
+	}
 
-	// Record plugin execution latency
-	pluginDuration := time.Since(pluginStartTime)
-	metrics.RecordPluginLatency(step.step.PluginRef, pluginDuration)
+	// Get and execute plugin. A plugin reloaded meanwhile keeps this
+	// instance alive until the step is done with it.
+	plugin, release, err := pluginManager.Acquire(step.step.PluginRef)
+	if err != nil {
+		markStepFailed(step, execCtx, fmt.Sprintf("Plugin not found: %v", err))
+		return
+	}
+	defer release()
 
+	step.logger.Infof("Executing plugin: %s", step.step.PluginRef)
+	res, err := executePlugin(ctx, plugin, step, execCtx)
 	if err != nil {
 		markStepFailed(step, execCtx, fmt.Sprintf("Error: %v", err))
-		metrics.RecordPluginError(step.step.PluginRef, "execution_error")
+		return
+	}
+
+	outputs, err := resolveOutputs(step, res)
+	if err != nil {
+		markStepFailed(step, execCtx, fmt.Sprintf("Error resolving outputs: %v", err))
 		return
 	}
 
@@ -538,36 +513,131 @@ func executeStepAsync(step *stepExecution, execCtx *executionContext) {
 	if res != nil {
 		formattedResult, err = plugin.FormatResult(res)
 		if err != nil {
-			execCtx.logger.Warnf("Format error: %v", err)
+			step.logger.Warnf("Format error: %v", err)
 			formattedResult = fmt.Sprintf("%v", res)
 		}
 	}
 
 	// Log and store result
-	logResult(step.step.PluginRef, formattedResult, execCtx)
+	logResult(step, formattedResult, execCtx)
 
 	execCtx.mutex.Lock()
 	result := map[string]interface{}{
 		"plugin": step.step.PluginRef,
+		"step":   step.id(),
 		"result": res,
 	}
 	if formattedResult != "" {
 		result["formatted_result"] = formattedResult
 	}
+	if len(outputs) > 0 {
+		result["outputs"] = outputs
+	}
 	*execCtx.results = append(*execCtx.results, result)
-	execCtx.mutex.Unlock()
-
 	// Mark complete and trigger dependents
 	step.result = res
+	step.outputs = outputs
 	step.executed = true
+	execCtx.mutex.Unlock()
+
+	span.SetAttributes(attribute.String("step.status", "succeeded"))
 	triggerDependentSteps(step, execCtx)
 }
 
+// resolveSecrets replaces the secretRefs of the step parameters by their
+// value, in the context of the step and in the params its inputs see. Only
+// the parameters of the config may hold a secretRef, never those of the
+// request.
+func resolveSecrets(ctx context.Context, step *stepExecution) error {
+	for name, value := range step.step.Parameters {
+		resolved, err := secrets.Default().Resolve(ctx, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		step.sharedCtx[name] = resolved
+		step.params[name] = resolved
+	}
+	return nil
+}
+
+// resolveInputs evaluates the inputs of the step into its context
+func resolveInputs(step *stepExecution) error {
+	if len(step.step.Inputs) == 0 {
+		return nil
+	}
+	data := map[string]interface{}{
+		"params": step.params,
+		"steps":  step.previousSteps(),
+	}
+	for name, raw := range step.step.Inputs {
+		value, err := evalTemplate(raw, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		step.sharedCtx[name] = value
+	}
+	return nil
+}
+
+// resolveOutputs evaluates the outputs of the step from its result
+func resolveOutputs(step *stepExecution, res interface{}) (map[string]interface{}, error) {
+	outputs := make(map[string]interface{}, len(step.step.Outputs))
+	data := map[string]interface{}{
+		"params": step.params,
+		"result": res,
+	}
+	for name, raw := range step.step.Outputs {
+		value, err := evalTemplate(raw, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		outputs[name] = value
+	}
+	return outputs, nil
+}
+
+func evalTemplate(raw string, data map[string]interface{}) (interface{}, error) {
+	tmpl, err := expr.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Eval(data)
+}
+
+// previousSteps returns, by ID, the status, result and outputs of the steps
+// that ran before this one
+func (s *stepExecution) previousSteps() map[string]interface{} {
+	steps := make(map[string]interface{})
+	var visit func(*stepExecution)
+	visit = func(current *stepExecution) {
+		for _, dep := range current.dependencies {
+			if _, seen := steps[dep.id()]; seen {
+				continue
+			}
+			summary := map[string]interface{}{}
+			switch {
+			case dep.hasError:
+				summary["status"] = "failed"
+			case dep.skipped:
+				summary["status"] = "skipped"
+			default:
+				summary["status"] = "succeeded"
+				summary["result"] = dep.result
+				summary["outputs"] = dep.outputs
+			}
+			steps[dep.id()] = summary
+			visit(dep)
+		}
+	}
+	visit(s)
+	return steps
+}
+
 // Helper to mark a step as failed
 func markStepFailed(step *stepExecution, execCtx *executionContext, errMsg string) {
-	execCtx.logger.Errorf("Plugin %s: %s", step.step.PluginRef, errMsg)
+	step.logger.Errorf("Plugin %s: %s", step.step.PluginRef, errMsg)
 
-	// Registrar error en métricas
+	// The single place where step failures are counted
 	errorType := "execution_error"
 	if strings.Contains(errMsg, "Plugin not found") {
 		errorType = "plugin_not_found"
@@ -576,30 +646,110 @@ func markStepFailed(step *stepExecution, execCtx *executionContext, errMsg strin
 	}
 	metrics.RecordPluginError(step.step.PluginRef, errorType)
 
+	if step.span != nil {
+		redacted := execCtx.redactor.String(errMsg)
+		step.span.SetAttributes(attribute.String("step.status", "failed"), attribute.String("error.message", redacted))
+		step.span.SetStatus(codes.Error, redacted)
+	}
+
 	execCtx.mutex.Lock()
 	*execCtx.results = append(*execCtx.results, map[string]interface{}{
 		"plugin": step.step.PluginRef,
+		"step":   step.id(),
 		"error":  errMsg,
 	})
+	step.executed = true
+	step.hasError = true
 	execCtx.mutex.Unlock()
 
+	triggerDependentSteps(step, execCtx)
+}
+
+// Helper to mark a step as skipped because its condition did not hold.
+// A skipped step is not an error.
+func markStepSkipped(step *stepExecution, execCtx *executionContext, reason string) {
+	step.logger.Infof("Plugin %s (step %s) skipped: %s", step.step.PluginRef, step.id(), reason)
+	if step.span != nil {
+		step.span.SetAttributes(attribute.String("step.status", "skipped"), attribute.String("step.skip_reason", reason))
+	}
+
+	execCtx.mutex.Lock()
+	*execCtx.results = append(*execCtx.results, map[string]interface{}{
+		"plugin":  step.step.PluginRef,
+		"step":    step.id(),
+		"skipped": reason,
+	})
+	step.skipped = true
 	step.executed = true
-	step.hasError = true
+	execCtx.mutex.Unlock()
+
 	triggerDependentSteps(step, execCtx)
 }
 
+// executePlugin runs the plugin of a step with ctx, which carries the step
+// span. Each attempt is bounded by the step timeout, and failed attempts are
+// retried as set by the step retry policy.
+func executePlugin(stepCtx context.Context, plugin pluginManager.Plugin, step *stepExecution, execCtx *executionContext) (interface{}, error) {
+	span := trace.SpanFromContext(stepCtx)
+	timeout, _ := step.step.TimeoutDuration()
+	attempts := 1
+	var backoff time.Duration
+	if step.step.Retry != nil && step.step.Retry.Attempts > 1 {
+		attempts = step.step.Retry.Attempts
+		backoff, _ = step.step.Retry.BackoffDuration()
+	}
+
+	var res interface{}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		span.SetAttributes(attribute.Int("step.attempt", attempt))
+		ctx, cancel := stepCtx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(stepCtx, timeout)
+		}
+		res, err = plugin.Execute(ctx, execCtx.request, &step.sharedCtx)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && execCtx.ctx.Err() == nil {
+			err = fmt.Errorf("step timed out after %s: %w", timeout, err)
+		}
+		cancel()
+
+		if err == nil || attempt == attempts {
+			break
+		}
+		step.logger.Warnf("Plugin %s (step %s) failed on attempt %d/%d: %v", step.step.PluginRef, step.id(), attempt, attempts, err)
+		span.AddEvent("attempt failed", trace.WithAttributes(
+			attribute.Int("step.attempt", attempt),
+			attribute.String("error.message", execCtx.redactor.String(err.Error())),
+		))
+
+		select {
+		case <-execCtx.ctx.Done():
+			return res, err
+		case <-time.After(backoff):
+		}
+	}
+	return res, err
+}
+
 // Start execution of steps that were waiting on this step
 func triggerDependentSteps(completedStep *stepExecution, execCtx *executionContext) {
 	// Find all steps that were waiting on this one
 	for _, step := range findDependentSteps(completedStep, execCtx) {
-		// Check if all dependencies are now satisfied
-		allDepsComplete := true
+		// Check if all dependencies are now satisfied. Dependencies finishing
+		// together both see it, so the step is only started once.
+		execCtx.mutex.Lock()
+		allDepsComplete := !step.started
 		for _, dep := range step.dependencies {
 			if !dep.executed {
 				allDepsComplete = false
 				break
 			}
 		}
+		if allDepsComplete {
+			step.started = true
+		}
+		execCtx.mutex.Unlock()
+
 		// If all dependencies are complete, start this step
 		if allDepsComplete {
 			execCtx.wg.Add(1)
@@ -608,22 +758,20 @@ func triggerDependentSteps(completedStep *stepExecution, execCtx *executionConte
 	}
 }
 
+// finished reports whether step is done, whatever its outcome
+func (execCtx *executionContext) finished(step *stepExecution) bool {
+	execCtx.mutex.Lock()
+	defer execCtx.mutex.Unlock()
+	return step.executed
+}
+
 // findDependentSteps returns all steps that depend on the given step
 func findDependentSteps(step *stepExecution, execCtx *executionContext) []*stepExecution {
-	globalPlanMutex.Lock()
-	defer globalPlanMutex.Unlock()
-
-	// Return the steps that depend on this one
-	if deps, exists := globalDependents[step]; exists {
-		return deps
-	}
-
-	// If no dependencies found, return empty slice
-	return []*stepExecution{}
+	return execCtx.dependents[step]
 }
 
 // step by step execution of the flow with dependency management
-func executeFlow(ctx context.Context, flow v1alpha1.Flow, params map[string]interface{}, r *http.Request, logger *logrus.Logger, isAllFlowsFlow bool) []interface{} {
+func executeFlow(ctx context.Context, flow v1beta1.Flow, params map[string]interface{}, r *http.Request, logger *logrus.Logger, isAllFlowsFlow bool) []interface{} {
 	var results []interface{}
 
 	// Skip empty pipelines
@@ -638,47 +786,67 @@ func executeFlow(ctx context.Context, flow v1alpha1.Flow, params map[string]inte
 	}
 	shared["flow_registry"] = flowRegistry
 
+	ctx, span := tracing.Start(ctx, "flow "+flow.Name, trace.WithAttributes(
+		attribute.String("flow.name", flow.Name),
+		attribute.Int("flow.steps", len(flow.Pipeline)),
+	))
+	defer span.End()
+	done := metrics.StartFlow(flow.Name)
+
 	// Prepare execution
-	executionPlan := buildExecutionPlan(flow.Pipeline, shared)
+	executionPlan := resolveExecutionPlan(flow.Pipeline, shared)
 	var wg sync.WaitGroup
 	var mutex sync.Mutex
 
 	execCtx := &executionContext{
-		ctx:      ctx,
-		logger:   logger,
-		request:  r,
-		wg:       &wg,
-		mutex:    &mutex,
-		results:  &results,
-		allFlows: isAllFlowsFlow,
+		ctx:        ctx,
+		flow:       flow.Name,
+		logger:     pluginManager.LoggerFromContext(ctx, logger).WithField("flow", flow.Name),
+		request:    r,
+		wg:         &wg,
+		mutex:      &mutex,
+		results:    &results,
+		allFlows:   isAllFlowsFlow,
+		redactor:   redact.FromContext(ctx),
+		dependents: dependentsOf(executionPlan),
 	}
 
 	// Run and wait for completion
 	executeSteps(executionPlan, execCtx)
 	wg.Wait()
 
+	if resultsHaveError(results) {
+		span.SetStatus(codes.Error, "a step failed")
+		done(metrics.StatusFailed)
+	} else {
+		done(metrics.StatusSucceeded)
+	}
 	return results
 }
 
+// RunFlow runs a flow outside of the HTTP handlers, as the plugin test
+// harness does, and returns the result of each step
+func RunFlow(ctx context.Context, flow v1beta1.Flow, params map[string]interface{}, r *http.Request, logger *logrus.Logger) []interface{} {
+	return executeFlow(ctx, flow, params, r, logger, false)
+}
+
 // Helper function to log plugin results with appropriate formatting
-func logResult(pluginRef string, formattedResult string, execCtx *executionContext) {
+func logResult(step *stepExecution, formattedResult string, execCtx *executionContext) {
+	pluginRef := step.step.PluginRef
 	switch {
 	case strings.HasSuffix(pluginRef, "-formatter") || pluginRef == "formatter-plugin":
-		execCtx.logger.Infof("Result from %s: [long output]", pluginRef)
+		step.logger.Infof("Result from %s: [long output]", pluginRef)
 
-	case strings.HasPrefix(formattedResult, "__MULTILINE_LOG__"):
-		execCtx.logger.Infof("Result from %s (multi-line):", pluginRef)
-		for _, line := range strings.Split(formattedResult, "__MULTILINE_LOG__") {
-			if line != "" {
-				execCtx.logger.Info(line)
-			}
-		}
+	case strings.Contains(formattedResult, "\n"):
+		// The full result is kept in formatted_result of the execution
+		lines := strings.Split(strings.TrimSpace(formattedResult), "\n")
+		step.logger.Infof("Result from %s: %s (%d more lines)", pluginRef, lines[0], len(lines)-1)
 
 	default:
 		if !execCtx.allFlows && len(formattedResult) > 100 {
-			execCtx.logger.Infof("Result from %s: %s...", pluginRef, formattedResult[:100])
+			step.logger.Infof("Result from %s: %s...", pluginRef, formattedResult[:100])
 		} else {
-			execCtx.logger.Infof("Result from %s: %s", pluginRef, formattedResult)
+			step.logger.Infof("Result from %s: %s", pluginRef, formattedResult)
 		}
 	}
 }
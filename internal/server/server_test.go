@@ -10,12 +10,20 @@ import (
 	"testing"
 	"time"
 
-	"expressops/api/v1alpha1"
+	"expressops/api/v1beta1"
+	"expressops/internal/metrics"
 	pluginManager "expressops/internal/plugin/loader"
+	"expressops/internal/tracing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // Variables y funciones no utilizadas están comentadas
@@ -105,10 +113,10 @@ func TestDynamicFlowHandler(t *testing.T) {
 	timeout := 5 * time.Second
 
 	// Reset flow registry before each test
-	flowRegistry = map[string]v1alpha1.Flow{
+	flowRegistry = map[string]v1beta1.Flow{
 		"test-flow": {
 			Name: "test-flow",
-			Pipeline: []v1alpha1.Step{
+			Pipeline: []v1beta1.Step{
 				{
 					PluginRef: "test-plugin",
 					Parameters: map[string]interface{}{
@@ -212,6 +220,8 @@ func TestDynamicFlowHandler(t *testing.T) {
 				for k, v := range tc.expectedBody {
 					assert.Equal(t, v, result[k])
 				}
+				assert.Equal(t, resp.Header.Get(RequestIDHeader), result["execution_id"])
+				assert.NotEmpty(t, result["execution_id"])
 			}
 		})
 	}
@@ -224,9 +234,9 @@ func TestExecuteFlow(t *testing.T) {
 	ctx := context.Background()
 
 	// Simple flow with one step
-	simpleFlow := v1alpha1.Flow{
+	simpleFlow := v1beta1.Flow{
 		Name: "simple-flow",
-		Pipeline: []v1alpha1.Step{
+		Pipeline: []v1beta1.Step{
 			{
 				PluginRef: "simple-plugin",
 				Parameters: map[string]interface{}{
@@ -237,9 +247,9 @@ func TestExecuteFlow(t *testing.T) {
 	}
 
 	// Flow with multiple steps
-	multiStepFlow := v1alpha1.Flow{
+	multiStepFlow := v1beta1.Flow{
 		Name: "multi-step-flow",
-		Pipeline: []v1alpha1.Step{
+		Pipeline: []v1beta1.Step{
 			{
 				PluginRef: "step1-plugin",
 				Parameters: map[string]interface{}{
@@ -256,9 +266,9 @@ func TestExecuteFlow(t *testing.T) {
 	}
 
 	// Flow with a failing step
-	failingFlow := v1alpha1.Flow{
+	failingFlow := v1beta1.Flow{
 		Name: "failing-flow",
-		Pipeline: []v1alpha1.Step{
+		Pipeline: []v1beta1.Step{
 			{
 				PluginRef: "failing-plugin",
 				Parameters: map[string]interface{}{
@@ -269,9 +279,9 @@ func TestExecuteFlow(t *testing.T) {
 	}
 
 	// Flow with a non-existent plugin
-	nonExistentPluginFlow := v1alpha1.Flow{
+	nonExistentPluginFlow := v1beta1.Flow{
 		Name: "non-existent-plugin-flow",
-		Pipeline: []v1alpha1.Step{
+		Pipeline: []v1beta1.Step{
 			{
 				PluginRef: "non-existent-plugin",
 			},
@@ -327,7 +337,7 @@ func TestExecuteFlow(t *testing.T) {
 	// Test cases
 	tests := []struct {
 		name             string
-		flow             v1alpha1.Flow
+		flow             v1beta1.Flow
 		additionalParams map[string]interface{}
 		expectedCount    int
 		expectedError    bool
@@ -411,9 +421,9 @@ func TestExecuteFlowTimeout(t *testing.T) {
 	defer cancel()
 
 	// Flow with a plugin that takes longer than the timeout
-	slowFlow := v1alpha1.Flow{
+	slowFlow := v1beta1.Flow{
 		Name: "slow-flow",
-		Pipeline: []v1alpha1.Step{
+		Pipeline: []v1beta1.Step{
 			{
 				PluginRef: "slow-plugin",
 			},
@@ -469,3 +479,361 @@ func TestExecuteFlowTimeout(t *testing.T) {
 	assert.True(t, ok, "The error should be a string")
 	assert.Contains(t, errorStr, "context deadline exceeded", "Error should mention context deadline exceeded")
 }
+
+func TestExecuteFlowStepPolicies(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	flakyPlugin := new(MockPlugin)
+	flakyPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("transient")).Once()
+	flakyPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return("recovered", nil)
+	flakyPlugin.On("FormatResult", mock.Anything).Return("recovered", nil)
+
+	slowPlugin := new(MockPlugin)
+	slowPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(nil, context.DeadlineExceeded)
+
+	okPlugin := new(MockPlugin)
+	okPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return("ok", nil)
+	okPlugin.On("FormatResult", mock.Anything).Return("ok", nil)
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		switch name {
+		case "flaky-plugin":
+			return flakyPlugin, nil
+		case "slow-plugin":
+			return slowPlugin, nil
+		case "ok-plugin":
+			return okPlugin, nil
+		default:
+			return nil, fmt.Errorf("plugin not found")
+		}
+	}
+	defer func() {
+		pluginManager.GetPluginFunc = originalGetPlugin
+	}()
+
+	byStep := func(results []interface{}) map[string]map[string]interface{} {
+		out := make(map[string]map[string]interface{})
+		for _, res := range results {
+			result := res.(map[string]interface{})
+			out[result["step"].(string)] = result
+		}
+		return out
+	}
+	req := httptest.NewRequest("GET", "/test", nil)
+
+	t.Run("retry until success", func(t *testing.T) {
+		flow := v1beta1.Flow{Name: "retry", Pipeline: []v1beta1.Step{
+			{PluginRef: "flaky-plugin", Retry: &v1beta1.RetryPolicy{Attempts: 3, Backoff: "1ms"}},
+		}}
+		results := byStep(executeFlow(context.Background(), flow, nil, req, logger, false))
+		assert.Equal(t, "recovered", results["flaky-plugin"]["result"])
+		flakyPlugin.AssertNumberOfCalls(t, "Execute", 2)
+	})
+
+	t.Run("step timeout and conditions", func(t *testing.T) {
+		flow := v1beta1.Flow{Name: "conditions", Pipeline: []v1beta1.Step{
+			{ID: "check", PluginRef: "slow-plugin", Timeout: "20ms"},
+			{ID: "notify-ok", PluginRef: "ok-plugin", DependsOn: []string{"check"}},
+			{ID: "notify-failure", PluginRef: "ok-plugin", DependsOn: []string{"check"}, Condition: v1beta1.ConditionOnFailure},
+			{ID: "cleanup", PluginRef: "ok-plugin", DependsOn: []string{"check"}, Condition: v1beta1.ConditionAlways},
+			{ID: "after-ok", PluginRef: "ok-plugin", DependsOn: []string{"notify-ok"}, Condition: v1beta1.ConditionAlways},
+		}}
+		start := time.Now()
+		results := byStep(executeFlow(context.Background(), flow, nil, req, logger, false))
+		assert.Less(t, time.Since(start), time.Second)
+
+		assert.Contains(t, results["check"]["error"], "step timed out after 20ms")
+		assert.Contains(t, results["notify-ok"]["error"], "Skipped due to dependency failure")
+		assert.Equal(t, "ok", results["notify-failure"]["result"])
+		assert.Equal(t, "ok", results["cleanup"]["result"])
+		assert.Equal(t, "ok", results["after-ok"]["result"])
+	})
+
+	t.Run("on_failure skipped when dependencies succeed", func(t *testing.T) {
+		flow := v1beta1.Flow{Name: "skip", Pipeline: []v1beta1.Step{
+			{ID: "check", PluginRef: "ok-plugin"},
+			{ID: "notify-failure", PluginRef: "ok-plugin", DependsOn: []string{"check"}, Condition: v1beta1.ConditionOnFailure},
+			{ID: "after", PluginRef: "ok-plugin", DependsOn: []string{"notify-failure"}},
+		}}
+		results := byStep(executeFlow(context.Background(), flow, nil, req, logger, false))
+		assert.Equal(t, "condition on_failure not met", results["notify-failure"]["skipped"])
+		assert.Equal(t, "a dependency was skipped", results["after"]["skipped"])
+		assert.False(t, resultsHaveError(executeFlow(context.Background(), flow, nil, req, logger, false)))
+	})
+}
+
+func TestExecuteFlowInputsOutputs(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	formatter := new(MockPlugin)
+	formatter.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Return(map[string]interface{}{"text": "disk full", "level": "critical"}, nil)
+	formatter.On("FormatResult", mock.Anything).Return("formatted", nil)
+
+	// notifier returns the inputs it received
+	var received map[string]any
+	notifier := new(MockPlugin)
+	notifier.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			shared := *args.Get(2).(*map[string]any)
+			received = map[string]any{"message": shared["message"], "severity": shared["severity"]}
+		}).
+		Return("sent", nil)
+	notifier.On("FormatResult", mock.Anything).Return("sent", nil)
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		switch name {
+		case "formatter":
+			return formatter, nil
+		case "notifier":
+			return notifier, nil
+		default:
+			return nil, fmt.Errorf("plugin not found")
+		}
+	}
+	defer func() {
+		pluginManager.GetPluginFunc = originalGetPlugin
+	}()
+
+	req := httptest.NewRequest("GET", "/test", nil)
+
+	t.Run("inputs from results and outputs", func(t *testing.T) {
+		flow := v1beta1.Flow{Name: "io", Pipeline: []v1beta1.Step{
+			{ID: "format", PluginRef: "formatter", Outputs: map[string]string{"level": "{{ result.level }}"}},
+			{ID: "notify", PluginRef: "notifier", Inputs: map[string]string{
+				"message":  "[{{ params.team }}] {{ steps.format.result.text }}",
+				"severity": "{{ steps.format.outputs.level }}",
+			}},
+		}}
+		results := executeFlow(context.Background(), flow, map[string]interface{}{"team": "sre"}, req, logger, false)
+		assert.False(t, resultsHaveError(results))
+		assert.Equal(t, map[string]any{"message": "[sre] disk full", "severity": "critical"}, received)
+		assert.Equal(t, map[string]interface{}{"level": "critical"}, results[0].(map[string]interface{})["outputs"])
+	})
+
+	t.Run("unresolved reference fails the step", func(t *testing.T) {
+		flow := v1beta1.Flow{Name: "io", Pipeline: []v1beta1.Step{
+			{ID: "format", PluginRef: "formatter"},
+			{ID: "notify", PluginRef: "notifier", Inputs: map[string]string{"message": "{{ steps.format.result.missing }}"}},
+		}}
+		results := executeFlow(context.Background(), flow, nil, req, logger, false)
+		require.Len(t, results, 2)
+		assert.Contains(t, results[1].(map[string]interface{})["error"],
+			"Error resolving inputs: message: unresolved reference 'steps.format.result.missing'")
+	})
+
+	t.Run("secret refs in parameters", func(t *testing.T) {
+		t.Setenv("STEP_SECRET_TEST", "step-secret-value")
+		ref := func(name string) map[string]interface{} {
+			return map[string]interface{}{"secretRef": map[string]interface{}{"provider": "env", "name": name}}
+		}
+		flow := v1beta1.Flow{Name: "io", Pipeline: []v1beta1.Step{
+			{ID: "notify", PluginRef: "notifier",
+				Parameters: map[string]interface{}{"message": ref("STEP_SECRET_TEST")},
+				Inputs:     map[string]string{"severity": "{{ params.message }}"}},
+		}}
+		results := executeFlow(context.Background(), flow, nil, req, logger, false)
+		assert.False(t, resultsHaveError(results))
+		assert.Equal(t, map[string]any{"message": "step-secret-value", "severity": "step-secret-value"}, received)
+		// The flow keeps the ref
+		assert.Equal(t, ref("STEP_SECRET_TEST"), flow.Pipeline[0].Parameters["message"])
+
+		flow.Pipeline[0].Parameters["message"] = ref("STEP_SECRET_UNSET")
+		results = executeFlow(context.Background(), flow, nil, req, logger, false)
+		require.Len(t, results, 1)
+		assert.Contains(t, results[0].(map[string]interface{})["error"],
+			"Error resolving secrets: message: secret env:STEP_SECRET_UNSET")
+	})
+}
+
+func TestExecuteFlowSpans(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(original)
+
+	// The plugin adds a span of its own under the step span
+	tracedPlugin := new(MockPlugin)
+	tracedPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, span := tracing.Start(args.Get(0).(context.Context), "send message")
+			span.End()
+		}).
+		Return("sent", nil)
+	tracedPlugin.On("FormatResult", mock.Anything).Return("sent", nil)
+
+	failingPlugin := new(MockPlugin)
+	failingPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("webhook down"))
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		switch name {
+		case "traced-plugin":
+			return tracedPlugin, nil
+		case "failing-plugin":
+			return failingPlugin, nil
+		}
+		return nil, fmt.Errorf("plugin not found")
+	}
+	defer func() { pluginManager.GetPluginFunc = originalGetPlugin }()
+
+	flow := v1beta1.Flow{Name: "traced", Pipeline: []v1beta1.Step{
+		{ID: "notify", PluginRef: "traced-plugin"},
+		{ID: "escalate", PluginRef: "failing-plugin", DependsOn: []string{"notify"}, Retry: &v1beta1.RetryPolicy{Attempts: 2, Backoff: "1ms"}},
+	}}
+
+	// The caller's traceparent makes the flow part of its trace
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	header := http.Header{"Traceparent": []string{"00-" + traceID + "-00f067aa0ba902b7-01"}}
+	ctx := tracing.Extract(context.Background(), header)
+	executeFlow(ctx, flow, nil, httptest.NewRequest("GET", "/flow", nil), logger, false)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		assert.Equal(t, traceID, span.SpanContext().TraceID().String(), span.Name())
+	}
+	require.Contains(t, spans, "flow traced")
+	require.Contains(t, spans, "step notify")
+	require.Contains(t, spans, "step escalate")
+	require.Contains(t, spans, "send message")
+
+	flowSpan := spans["flow traced"]
+	assert.Equal(t, "00f067aa0ba902b7", flowSpan.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, flowSpan.Status().Code)
+
+	notify := spans["step notify"]
+	assert.Equal(t, flowSpan.SpanContext().SpanID(), notify.Parent().SpanID())
+	assert.Equal(t, notify.SpanContext().SpanID(), spans["send message"].Parent().SpanID())
+	assert.Contains(t, notify.Attributes(), attribute.String("plugin.ref", "traced-plugin"))
+	assert.Contains(t, notify.Attributes(), attribute.String("step.status", "succeeded"))
+
+	escalate := spans["step escalate"]
+	assert.Equal(t, codes.Error, escalate.Status().Code)
+	assert.Contains(t, escalate.Attributes(), attribute.String("step.status", "failed"))
+	assert.Contains(t, escalate.Attributes(), attribute.Int("step.attempt", 2))
+	require.Len(t, escalate.Events(), 1)
+	assert.Equal(t, "attempt failed", escalate.Events()[0].Name)
+}
+
+// counterValue returns the value of the counter name with the given labels
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := metrics.Registry().Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	next:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue next
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestExecuteFlowMetrics(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	okPlugin := new(MockPlugin)
+	okPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).Return("ok", nil)
+	okPlugin.On("FormatResult", mock.Anything).Return("ok", nil)
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		if name == "ok-plugin" {
+			return okPlugin, nil
+		}
+		return nil, fmt.Errorf("plugin not found")
+	}
+	defer func() { pluginManager.GetPluginFunc = originalGetPlugin }()
+
+	flow := v1beta1.Flow{Name: "metered", Pipeline: []v1beta1.Step{
+		{ID: "check", PluginRef: "ok-plugin"},
+		{ID: "missing", PluginRef: "missing-plugin"},
+		{ID: "cleanup", PluginRef: "ok-plugin", Condition: v1beta1.ConditionOnFailure},
+		{ID: "notify", PluginRef: "ok-plugin", DependsOn: []string{"check"}, Condition: v1beta1.ConditionOnFailure},
+	}}
+	executeFlow(context.Background(), flow, nil, httptest.NewRequest("GET", "/flow", nil), logger, false)
+
+	// Each flow and step is counted once, whichever way it ends
+	step := func(id, plugin, status string) float64 {
+		return counterValue(t, "expressops_steps_executed_total",
+			map[string]string{"flow": "metered", "step": id, "plugin": plugin, "status": status})
+	}
+	assert.Equal(t, 1.0, counterValue(t, "expressops_flows_executed_total", map[string]string{"flow": "metered", "status": "failed"}))
+	assert.Equal(t, 1.0, step("check", "ok-plugin", "succeeded"))
+	assert.Equal(t, 1.0, step("missing", "missing-plugin", "failed"))
+	assert.Equal(t, 1.0, step("cleanup", "ok-plugin", "succeeded"))
+	assert.Equal(t, 1.0, step("notify", "ok-plugin", "skipped"))
+	assert.Equal(t, 1.0, counterValue(t, "expressops_plugin_errors_total", map[string]string{"plugin": "missing-plugin", "reason": "plugin_not_found"}))
+}
+
+// TestExecuteFlowConcurrentRuns overlaps runs of multi-step flows, which
+// must each run every one of their steps
+func TestExecuteFlowConcurrentRuns(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	slowPlugin := new(MockPlugin)
+	slowPlugin.On("Execute", mock.Anything, mock.Anything, mock.Anything).After(10*time.Millisecond).Return("ok", nil)
+	slowPlugin.On("FormatResult", mock.Anything).Return("ok", nil)
+
+	originalGetPlugin := pluginManager.GetPluginFunc
+	pluginManager.GetPluginFunc = func(name string) (pluginManager.Plugin, error) {
+		return slowPlugin, nil
+	}
+	defer func() { pluginManager.GetPluginFunc = originalGetPlugin }()
+
+	flows := []v1beta1.Flow{
+		{Name: "chain", Pipeline: []v1beta1.Step{
+			{ID: "a", PluginRef: "slow-plugin"},
+			{ID: "b", PluginRef: "slow-plugin"},
+			{ID: "c", PluginRef: "slow-plugin"},
+		}},
+		{Name: "fan-in", Pipeline: []v1beta1.Step{
+			{ID: "a", PluginRef: "slow-plugin"},
+			{ID: "b", PluginRef: "slow-plugin", Parallel: true},
+			{ID: "c", PluginRef: "slow-plugin", DependsOn: []string{"a", "b"}},
+			{ID: "d", PluginRef: "slow-plugin", DependsOn: []string{"c"}},
+		}},
+	}
+
+	const runs = 8
+	results := make([][]interface{}, runs)
+	done := make(chan int)
+	for i := 0; i < runs; i++ {
+		go func(i int) {
+			flow := flows[i%len(flows)]
+			results[i] = executeFlow(context.Background(), flow, nil, httptest.NewRequest("GET", "/flow", nil), logger, false)
+			done <- i
+		}(i)
+		// Start each run while the previous ones are still going on
+		time.Sleep(3 * time.Millisecond)
+	}
+	for i := 0; i < runs; i++ {
+		<-done
+	}
+
+	for i, res := range results {
+		assert.Len(t, res, len(flows[i%len(flows)].Pipeline), "run %d", i)
+	}
+}
@@ -0,0 +1,37 @@
+// Package tmpl parses and renders the Go templates builtins take in their
+// config, such as the args of exec or the body of http-request, with the
+// step context as data. A missing key is an error, and the json function
+// encodes a value, e.g. {{json .groups}}.
+package tmpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Parse parses text as the template name, e.g. "headers.Authorization"
+func Parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Render executes t with data
+func Render(t *template.Template, data map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering %s: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,26 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := map[string]any{"user": "ada", "groups": []string{"ops", "dev"}}
+
+	body, err := Parse("body", `{"user": {{json .user}}, "groups": {{json .groups}}}`)
+	require.NoError(t, err)
+	out, err := Render(body, data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"user": "ada", "groups": ["ops","dev"]}`, out)
+
+	missing, err := Parse("args[0]", "{{.team}}")
+	require.NoError(t, err)
+	_, err = Render(missing, data)
+	assert.ErrorContains(t, err, "error rendering args[0]")
+
+	_, err = Parse("url", "{{.user")
+	assert.ErrorContains(t, err, "invalid template in url")
+}
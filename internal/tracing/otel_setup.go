@@ -1,58 +1,221 @@
 package tracing
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
 	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"expressops/api/v1beta1"
+	"expressops/internal/redact"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/credentials"
 )
 
+// DefaultServiceName is the service name of the spans when none is configured
+const DefaultServiceName = "expressops-service"
+
 var tracer trace.Tracer
 
-// InitTracerProvider initializes the OpenTelemetry tracer provider.
-// Exports to the default standard output.
-func InitTracerProvider(serviceName string) (*sdktrace.TracerProvider, error) {
-	writer := os.Stdout
+// InitTracerProvider initializes the OpenTelemetry tracer provider with the
+// exporter, sampler and resource of cfg, and selects W3C trace context
+// propagation. With no exporter, or `none`, spans are sampled as usual but
+// never exported.
+func InitTracerProvider(ctx context.Context, cfg v1beta1.TracingConfig) (*sdktrace.TracerProvider, error) {
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
 
-	exporter, err := stdouttrace.New(
-		stdouttrace.WithWriter(writer),
-		stdouttrace.WithPrettyPrint(),
-		stdouttrace.WithoutTimestamps(),
-	)
+	res, err := newResource(serviceName, cfg.ResourceAttributes)
 	if err != nil {
 		return nil, err
 	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(newSampler(cfg.Sampler)),
+	}
 
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("0.1.0"),
-		),
-	)
+	exporter, err := newExporter(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("tracing exporter %s: %w", cfg.Exporter, err)
+	}
+	if exporter != nil {
+		exporter = redactingExporter{SpanExporter: exporter, redactor: redact.Default()}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-	)
-
+	tp := sdktrace.NewTracerProvider(opts...)
 	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
 	tracer = otel.Tracer(serviceName + "-tracer")
-
-	log.Println("TracerProvider inicializado, exportando a stdout.")
 	return tp, nil
 }
 
+// newExporter returns the span exporter selected by cfg, nil for none
+func newExporter(ctx context.Context, cfg v1beta1.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", v1beta1.TracingExporterNone:
+		return nil, nil
+
+	case v1beta1.TracingExporterStdout:
+		// One span per line, to keep the logs readable
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case v1beta1.TracingExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else if cfg.TLS != nil {
+			tlsCfg, err := buildTLSConfig(cfg.TLS)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+
+	case v1beta1.TracingExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if strings.Contains(cfg.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else if cfg.TLS != nil {
+			tlsCfg, err := buildTLSConfig(cfg.TLS)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unknown exporter '%s'", cfg.Exporter)
+}
+
+func buildTLSConfig(cfg *v1beta1.TracingTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} // #nosec G402 -- opt-in
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// newSampler returns the sampler selected by cfg, parentbased_always_on by default
+func newSampler(cfg v1beta1.SamplerConfig) sdktrace.Sampler {
+	var ratio float64
+	if cfg.Ratio != nil {
+		ratio = *cfg.Ratio
+	}
+	switch cfg.Type {
+	case v1beta1.SamplerAlwaysOn:
+		return sdktrace.AlwaysSample()
+	case v1beta1.SamplerAlwaysOff:
+		return sdktrace.NeverSample()
+	case v1beta1.SamplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(ratio)
+	case v1beta1.SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	case v1beta1.SamplerParentBasedTraceIDRatio:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	}
+	return sdktrace.ParentBased(sdktrace.AlwaysSample())
+}
+
+func newResource(serviceName string, attributes map[string]string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(Version()),
+	}
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, attribute.String(k, attributes[k]))
+	}
+	return resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+}
+
+// Version returns the version of the binary from its build info: the module
+// version when installed with `go install`, else the VCS revision it was
+// built from, suffixed with -dirty for uncommitted changes.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
 // GetTracer returns the global tracer instance.
 func GetTracer() trace.Tracer {
 	if tracer == nil {
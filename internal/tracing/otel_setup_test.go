@@ -0,0 +1,162 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"expressops/api/v1beta1"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector stands in for an OpenTelemetry collector
+type collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	mu       sync.Mutex
+	requests []*collectortrace.ExportTraceServiceRequest
+	headers  []string
+}
+
+func (c *collector) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.record(req, md.Get("x-api-key"))
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (c *collector) record(req *collectortrace.ExportTraceServiceRequest, headers []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, headers...)
+}
+
+// spans returns the names of the exported spans and the resource
+// attributes of the last export
+func (c *collector) spans() ([]string, map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	attrs := make(map[string]string)
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, kv := range rs.Resource.Attributes {
+				attrs[kv.Key] = stringValue(kv.Value)
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					names = append(names, span.Name)
+				}
+			}
+		}
+	}
+	return names, attrs
+}
+
+func stringValue(v *commonpb.AnyValue) string {
+	if s, ok := v.Value.(*commonpb.AnyValue_StringValue); ok {
+		return s.StringValue
+	}
+	return ""
+}
+
+func exportSpan(t *testing.T, cfg v1beta1.TracingConfig) {
+	t.Helper()
+	ctx := context.Background()
+	tp, err := InitTracerProvider(ctx, cfg)
+	require.NoError(t, err)
+	_, span := GetTracer().Start(ctx, "test-span")
+	span.End()
+	require.NoError(t, tp.Shutdown(ctx))
+}
+
+func TestOTLPGRPCExporter(t *testing.T) {
+	c := &collector{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(srv, c)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	exportSpan(t, v1beta1.TracingConfig{
+		Exporter:           v1beta1.TracingExporterOTLPGRPC,
+		Endpoint:           lis.Addr().String(),
+		Insecure:           true,
+		Headers:            map[string]string{"x-api-key": "secret"},
+		ServiceName:        "expressops-test",
+		ResourceAttributes: map[string]string{"deployment.environment": "ci"},
+	})
+
+	names, attrs := c.spans()
+	assert.Equal(t, []string{"test-span"}, names)
+	assert.Equal(t, []string{"secret"}, c.headers)
+	assert.Equal(t, "expressops-test", attrs["service.name"])
+	assert.Equal(t, "ci", attrs["deployment.environment"])
+	assert.Equal(t, Version(), attrs["service.version"])
+	assert.NotEqual(t, "0.1.0", attrs["service.version"])
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := &collectortrace.ExportTraceServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, req))
+		c.record(req, r.Header.Values("X-Api-Key"))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	exportSpan(t, v1beta1.TracingConfig{
+		Exporter: v1beta1.TracingExporterOTLPHTTP,
+		Endpoint: srv.URL + "/v1/traces",
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+	})
+
+	names, attrs := c.spans()
+	assert.Equal(t, []string{"test-span"}, names)
+	assert.Equal(t, []string{"secret"}, c.headers)
+	assert.Equal(t, DefaultServiceName, attrs["service.name"])
+}
+
+func TestSampler(t *testing.T) {
+	ctx := context.Background()
+	zero, one := 0.0, 1.0
+
+	tests := []struct {
+		name    string
+		sampler v1beta1.SamplerConfig
+		sampled bool
+	}{
+		{name: "default", sampler: v1beta1.SamplerConfig{}, sampled: true},
+		{name: "always off", sampler: v1beta1.SamplerConfig{Type: v1beta1.SamplerAlwaysOff}, sampled: false},
+		{name: "ratio 0", sampler: v1beta1.SamplerConfig{Type: v1beta1.SamplerTraceIDRatio, Ratio: &zero}, sampled: false},
+		{name: "ratio 1", sampler: v1beta1.SamplerConfig{Type: v1beta1.SamplerParentBasedTraceIDRatio, Ratio: &one}, sampled: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tp, err := InitTracerProvider(ctx, v1beta1.TracingConfig{Sampler: tc.sampler})
+			require.NoError(t, err)
+			defer tp.Shutdown(ctx)
+
+			_, span := GetTracer().Start(ctx, "sampled")
+			defer span.End()
+			assert.Equal(t, tc.sampled, span.SpanContext().IsSampled())
+		})
+	}
+}
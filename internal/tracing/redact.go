@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+
+	"expressops/internal/redact"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// redactingExporter masks the secrets of the configuration in the name,
+// string attributes, events and status of spans before exporting them
+type redactingExporter struct {
+	sdktrace.SpanExporter
+	redactor *redact.Redactor
+}
+
+func (e redactingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	redacted := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		redacted[i] = redactedSpan{ReadOnlySpan: span, redactor: e.redactor}
+	}
+	return e.SpanExporter.ExportSpans(ctx, redacted)
+}
+
+// redactedSpan is a span whose text is masked as it is read by the exporter
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	redactor *redact.Redactor
+}
+
+func (s redactedSpan) Name() string {
+	return s.redactor.String(s.ReadOnlySpan.Name())
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.redactAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	redacted := make([]sdktrace.Event, len(events))
+	for i, event := range events {
+		event.Name = s.redactor.String(event.Name)
+		event.Attributes = s.redactAttributes(event.Attributes)
+		redacted[i] = event
+	}
+	return redacted
+}
+
+func (s redactedSpan) Status() sdktrace.Status {
+	status := s.ReadOnlySpan.Status()
+	status.Description = s.redactor.String(status.Description)
+	return status
+}
+
+func (s redactedSpan) redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch {
+		case attr.Value.Type() == attribute.STRING && s.redactor.SecretKey(string(attr.Key)):
+			attr.Value = attribute.StringValue(redact.Mask)
+		case attr.Value.Type() == attribute.STRING:
+			attr.Value = attribute.StringValue(s.redactor.String(attr.Value.AsString()))
+		case attr.Value.Type() == attribute.STRINGSLICE:
+			attr.Value = attribute.StringSliceValue(s.redactor.Value(attr.Value.AsStringSlice()).([]string))
+		}
+		redacted[i] = attr
+	}
+	return redacted
+}
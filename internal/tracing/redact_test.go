@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"expressops/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRedactingExporter(t *testing.T) {
+	redactor := redact.New(nil)
+	redactor.Add("span-secret")
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(redactingExporter{SpanExporter: exporter, redactor: redactor}))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	_, span := tp.Tracer("test").Start(context.Background(), "call span-secret")
+	span.SetAttributes(
+		attribute.String("url", "https://example.com/span-secret"),
+		attribute.String("api_key", "anything"),
+		attribute.StringSlice("args", []string{"--token", "span-secret"}),
+		attribute.Int("attempt", 2),
+	)
+	span.AddEvent("retry", trace.WithAttributes(attribute.String("error.message", "span-secret rejected")))
+	span.SetStatus(codes.Error, "span-secret rejected")
+	span.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	got := spans[0]
+	assert.Equal(t, "call [REDACTED]", got.Name)
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, attr := range got.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	assert.Equal(t, "https://example.com/[REDACTED]", attrs["url"].AsString())
+	assert.Equal(t, redact.Mask, attrs["api_key"].AsString())
+	assert.Equal(t, []string{"--token", redact.Mask}, attrs["args"].AsStringSlice())
+	assert.Equal(t, int64(2), attrs["attempt"].AsInt64())
+	assert.Equal(t, "[REDACTED] rejected", got.Events[0].Attributes[0].Value.AsString())
+	assert.Equal(t, "[REDACTED] rejected", got.Status.Description)
+}
@@ -1,28 +1,23 @@
 # Build operations
-.PHONY: build run
+.PHONY: build build-plugin plugin-new run
 
 ## Build operations for ExpressOps application
 
-build: ## Build plugins and application locally
-	@echo "Cleaning previous plugins..."
-	@find plugins -name "*.so" -delete
-	@echo "Building plugins..."
-	@for dir in $$(find plugins -type f -name "*.go" -exec dirname {} \; | sort -u); do \
-		for gofile in $$dir/*.go; do \
-			if [ -f "$$gofile" ]; then \
-				plugin_name=$$(basename "$$gofile" .go); \
-				echo "Building plugin $$plugin_name.so from $$gofile"; \
-				CGO_ENABLED=1 GOOS=linux go build -buildmode=plugin -o "$$dir/$$plugin_name.so" "$$gofile" || exit 1; \
-			fi \
-		done \
-	done
-	@echo "Verifying compiled plugins:"
-	@find plugins -name "*.so" | sort
+build: ## Build the application with the bundled (builtin) plugins
 	@echo "Building main application..."
 	@go build -o expressops ./cmd
 	@echo "✅ Build completed"
 
+build-plugin: ## Build a third-party .so plugin: make build-plugin PLUGIN_DIR=path/to/plugin
+	@if [ -z "$(PLUGIN_DIR)" ]; then echo "PLUGIN_DIR is required"; exit 1; fi
+	@echo "Building plugin from $(PLUGIN_DIR)..."
+	@CGO_ENABLED=1 go build -buildmode=plugin -o "$(PLUGIN_DIR)/$$(basename $(PLUGIN_DIR)).so" "./$(PLUGIN_DIR)"
+	@echo "✅ Plugin built: $(PLUGIN_DIR)/$$(basename $(PLUGIN_DIR)).so"
+
+plugin-new: ## Generate a builtin plugin: make plugin-new NAME=ticket-notifier KIND=notification|check|action
+	@if [ -z "$(NAME)" ]; then echo "NAME is required"; exit 1; fi
+	@go run ./cmd plugin new $(NAME) --kind $(or $(KIND),action)
+
 run: build ## Run application locally
 	@echo "🚀 Starting ExpressOps"
-	./expressops -config $(CONFIG_PATH) 
-	
\ No newline at end of file
+	./expressops -config $(CONFIG_PATH)
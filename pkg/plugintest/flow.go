@@ -0,0 +1,143 @@
+package plugintest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"expressops/api/v1beta1"
+	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/server"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// Upstream is a stub step that runs before the plugin under test. Its
+// plugin returns Result, or fails with Err.
+type Upstream struct {
+	ID     string
+	Result interface{}
+	Err    error
+}
+
+// StepResult is the outcome of one step of a flow run
+type StepResult struct {
+	Plugin    string
+	Result    interface{}
+	Formatted string
+	Outputs   map[string]interface{}
+	// Error is set when the step failed and Skipped when its condition did
+	// not hold
+	Error   string
+	Skipped string
+}
+
+// FlowRun is the outcome of RunFlow
+type FlowRun struct {
+	// Steps holds the result of every step by ID
+	Steps map[string]StepResult
+	// Step is the result of the step running the plugin under test
+	Step StepResult
+	// Execution is the call to the plugin under test, with the shared
+	// context the engine built for it; nil when the step did not run
+	Execution *Execution
+}
+
+// RunFlow runs step, with the plugin under test, through the flow engine
+// after the upstream stubs, which all run in parallel. The PluginRef of
+// step defaults to the harness Name and its dependsOn to every upstream
+// step, so inputs may refer to them as steps.<id>. params are the request
+// parameters of the flow.
+//
+// Plugins are looked up through pluginconf.GetPluginFunc for the duration
+// of the run, so tests using RunFlow must not run in parallel.
+func (h *Harness) RunFlow(step v1beta1.Step, params map[string]interface{}, upstream ...Upstream) *FlowRun {
+	h.T.Helper()
+
+	if step.PluginRef == "" {
+		step.PluginRef = h.Name
+	}
+	plugins := map[string]pluginconf.Plugin{}
+	var pipeline []v1beta1.Step
+	for _, u := range upstream {
+		require.NotEmpty(h.T, u.ID, "upstream steps need an ID")
+		plugins[u.ID] = &stub{result: u.Result, err: u.Err}
+		pipeline = append(pipeline, v1beta1.Step{ID: u.ID, PluginRef: u.ID, Parallel: true})
+	}
+	if len(step.DependsOn) == 0 {
+		for _, u := range upstream {
+			step.DependsOn = append(step.DependsOn, u.ID)
+		}
+	}
+
+	recorder := &recorder{Plugin: h.Plugin, h: h}
+	plugins[step.PluginRef] = recorder
+	pipeline = append(pipeline, step)
+
+	cfg := &v1beta1.Config{Flows: []v1beta1.Flow{{Name: "plugintest", Pipeline: pipeline}}}
+	v1beta1.SetDefaults(cfg)
+	require.NoError(h.T, cfg.Validate(), "invalid test flow")
+
+	original := pluginconf.GetPluginFunc
+	pluginconf.GetPluginFunc = func(name string) (pluginconf.Plugin, error) {
+		if p, ok := plugins[name]; ok {
+			return p, nil
+		}
+		return original(name)
+	}
+	defer func() { pluginconf.GetPluginFunc = original }()
+
+	results := server.RunFlow(h.Ctx, cfg.Flows[0], params, h.Request, h.Logger)
+
+	run := &FlowRun{Steps: make(map[string]StepResult), Execution: recorder.execution}
+	for _, r := range results {
+		entry, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := entry["step"].(string)
+		result := StepResult{Result: entry["result"]}
+		result.Plugin, _ = entry["plugin"].(string)
+		result.Formatted, _ = entry["formatted_result"].(string)
+		result.Outputs, _ = entry["outputs"].(map[string]interface{})
+		result.Error, _ = entry["error"].(string)
+		result.Skipped, _ = entry["skipped"].(string)
+		run.Steps[id] = result
+	}
+	run.Step = run.Steps[cfg.Flows[0].Pipeline[len(pipeline)-1].ID]
+	return run
+}
+
+// stub is the plugin of an upstream step
+type stub struct {
+	result interface{}
+	err    error
+}
+
+func (s *stub) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	return nil
+}
+
+func (s *stub) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	return s.result, s.err
+}
+
+func (s *stub) FormatResult(result interface{}) (string, error) {
+	return fmt.Sprint(result), nil
+}
+
+// recorder wraps the plugin under test to keep the shared context it was
+// called with
+type recorder struct {
+	pluginconf.Plugin
+	h         *Harness
+	execution *Execution
+}
+
+func (r *recorder) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	before := copyMap(*shared)
+	res, err := r.Plugin.Execute(ctx, request, shared)
+	r.execution = &Execution{h: r.h, Result: res, Err: err, Before: before, Shared: copyMap(*shared)}
+	return res, err
+}
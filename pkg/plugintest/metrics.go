@@ -0,0 +1,55 @@
+package plugintest
+
+import (
+	pluginconf "expressops/internal/plugin/loader"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// Metric returns the value of the series of a metric registered by the
+// plugin, by its name without the expressops_plugin_<name>_ prefix, and
+// its labels. Histograms return their number of observations. A series
+// never recorded is 0.
+func (h *Harness) Metric(name string, labels map[string]string) float64 {
+	h.T.Helper()
+	families, err := h.Registry.Gather()
+	require.NoError(h.T, err, "gathering metrics")
+
+	fullName := pluginconf.MetricName(h.Name, name)
+	for _, family := range families {
+		if family.GetName() != fullName {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if matchLabels(m, labels) {
+				return value(m)
+			}
+		}
+	}
+	return 0
+}
+
+func matchLabels(m *dto.Metric, labels map[string]string) bool {
+	if len(m.GetLabel()) != len(labels) {
+		return false
+	}
+	for _, label := range m.GetLabel() {
+		if v, ok := labels[label.GetName()]; !ok || v != label.GetValue() {
+			return false
+		}
+	}
+	return true
+}
+
+func value(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Histogram != nil:
+		return float64(m.GetHistogram().GetSampleCount())
+	}
+	return 0
+}
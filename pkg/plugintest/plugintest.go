@@ -0,0 +1,201 @@
+// Package plugintest helps plugin authors test a plugin without a server. A
+// Harness initializes the plugin with a config, executes it against a fake
+// request and shared context, captures what it logs and runs it inside a
+// small flow fed by stub upstream steps:
+//
+//	h := plugintest.New(t, &MyPlugin{}).MustInitialize(map[string]interface{}{"channel": "#ops"})
+//	exec := h.Execute(map[string]interface{}{"message": "disk full"})
+//	require.NoError(t, exec.Err)
+//	exec.AssertShared("ticket_id", "T-1")
+//	h.AssertLogged(logrus.InfoLevel, "ticket created")
+package plugintest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	pluginconf "expressops/internal/plugin/loader"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultName is the name the plugin under test is registered with in flows
+const DefaultName = "plugin-under-test"
+
+// Harness drives one plugin instance in a test. Its fields may be changed
+// before calling Initialize or Execute.
+type Harness struct {
+	T      testing.TB
+	Plugin pluginconf.Plugin
+	// Name is the plugin name used by RunFlow, DefaultName by default
+	Name string
+	// Ctx is passed to Initialize and Execute
+	Ctx    context.Context
+	Logger *logrus.Logger
+	// Logs captures every entry the plugin logs through Logger
+	Logs *test.Hook
+	// Request is passed to Execute, a GET on /flow by default
+	Request *http.Request
+	// Registry holds the metrics the plugin registers in Initialize
+	Registry *prometheus.Registry
+}
+
+// New returns a harness for p logging at debug level into Logs
+func New(t testing.TB, p pluginconf.Plugin) *Harness {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	return &Harness{
+		T:        t,
+		Plugin:   p,
+		Name:     DefaultName,
+		Ctx:      context.Background(),
+		Logger:   logger,
+		Logs:     hook,
+		Request:  httptest.NewRequest(http.MethodGet, "/flow?flowName=plugintest", nil),
+		Registry: prometheus.NewRegistry(),
+	}
+}
+
+// Initialize validates config against the plugin manifest, as the loader
+// does, then initializes the plugin
+func (h *Harness) Initialize(config map[string]interface{}) error {
+	if err := pluginconf.ManifestOf(h.Plugin).Check(config); err != nil {
+		return err
+	}
+	ctx := pluginconf.WithMetrics(h.Ctx, pluginconf.NewMetrics(h.Name, h.Registry, h.Logger))
+	return h.Plugin.Initialize(ctx, config, h.Logger)
+}
+
+// MustInitialize is Initialize failing the test on error
+func (h *Harness) MustInitialize(config map[string]interface{}) *Harness {
+	h.T.Helper()
+	require.NoError(h.T, h.Initialize(config), "initializing plugin")
+	return h
+}
+
+// Execute runs the plugin once with a copy of shared, which is left as is
+func (h *Harness) Execute(shared map[string]interface{}) *Execution {
+	after := copyMap(shared)
+	res, err := h.Plugin.Execute(h.Ctx, h.Request, &after)
+	return &Execution{h: h, Result: res, Err: err, Before: copyMap(shared), Shared: after}
+}
+
+// Messages returns the messages logged at level
+func (h *Harness) Messages(level logrus.Level) []string {
+	var messages []string
+	for _, entry := range h.Logs.AllEntries() {
+		if entry.Level == level {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
+
+// AssertLogged checks that a message containing substring was logged at level
+func (h *Harness) AssertLogged(level logrus.Level, substring string) bool {
+	h.T.Helper()
+	messages := h.Messages(level)
+	for _, m := range messages {
+		if strings.Contains(m, substring) {
+			return true
+		}
+	}
+	return assert.Fail(h.T, "message not logged",
+		"no %s message contains %q, got %q", level, substring, messages)
+}
+
+// Execution is the outcome of one call to Execute
+type Execution struct {
+	h      *Harness
+	Result interface{}
+	Err    error
+	// Before and Shared are the shared context before and after the call.
+	// Only top-level keys are copied: a plugin changing a nested map in
+	// place changes both.
+	Before map[string]interface{}
+	Shared map[string]interface{}
+}
+
+// Format returns the result formatted by the plugin, failing the test on error
+func (e *Execution) Format() string {
+	e.h.T.Helper()
+	formatted, err := e.h.Plugin.FormatResult(e.Result)
+	require.NoError(e.h.T, err, "formatting result")
+	return formatted
+}
+
+// Changes are the top-level keys a plugin set or removed in the shared context
+type Changes struct {
+	// Set holds the keys added or given a different value
+	Set map[string]interface{}
+	// Removed holds the deleted keys, sorted
+	Removed []string
+}
+
+// Changes compares the shared context before and after the call
+func (e *Execution) Changes() Changes {
+	changes := Changes{Set: make(map[string]interface{})}
+	for k, v := range e.Shared {
+		if old, ok := e.Before[k]; !ok || !reflect.DeepEqual(old, v) {
+			changes.Set[k] = v
+		}
+	}
+	for k := range e.Before {
+		if _, ok := e.Shared[k]; !ok {
+			changes.Removed = append(changes.Removed, k)
+		}
+	}
+	sort.Strings(changes.Removed)
+	return changes
+}
+
+// AssertShared checks that the shared context holds expected under key
+func (e *Execution) AssertShared(key string, expected interface{}) bool {
+	e.h.T.Helper()
+	value, ok := e.Shared[key]
+	if !ok {
+		return assert.Fail(e.h.T, "missing shared key", "shared context has no key %q", key)
+	}
+	return assert.Equal(e.h.T, expected, value, "shared context key %q", key)
+}
+
+// AssertOnlyChanged checks that the plugin set or removed no key of the
+// shared context other than keys
+func (e *Execution) AssertOnlyChanged(keys ...string) bool {
+	e.h.T.Helper()
+	allowed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		allowed[k] = true
+	}
+	changes := e.Changes()
+	var unexpected []string
+	for k := range changes.Set {
+		if !allowed[k] {
+			unexpected = append(unexpected, k)
+		}
+	}
+	for _, k := range changes.Removed {
+		if !allowed[k] {
+			unexpected = append(unexpected, k)
+		}
+	}
+	sort.Strings(unexpected)
+	return assert.Empty(e.h.T, unexpected, "unexpected changes to the shared context")
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"expressops/api/v1beta1"
+	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// greeter greets shared["user"] and moves it to shared["greeted"]
+type greeter struct {
+	logger    *logrus.Logger
+	greeting  string
+	greetings *pluginconf.Counter
+}
+
+func (g *greeter) Manifest() pluginconf.Manifest {
+	return pluginconf.Manifest{
+		Name:         "greeter",
+		ConfigSchema: schema.Object(map[string]*schema.Schema{"greeting": schema.String("")}, "greeting"),
+	}
+}
+
+func (g *greeter) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	g.logger = logger
+	g.greeting = config["greeting"].(string)
+	var err error
+	g.greetings, err = pluginconf.MetricsFromContext(ctx).Counter("greetings_total", "Users greeted.", "team")
+	return err
+}
+
+func (g *greeter) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	user, ok := (*shared)["user"].(string)
+	if !ok {
+		return nil, errors.New("no user")
+	}
+	g.logger.Infof("greeting %s", user)
+	team, _ := (*shared)["team"].(string)
+	g.greetings.Inc(team)
+	delete(*shared, "user")
+	(*shared)["greeted"] = user
+	return fmt.Sprintf("%s %s", g.greeting, user), nil
+}
+
+func (g *greeter) FormatResult(result interface{}) (string, error) {
+	return fmt.Sprintf("greeter: %v", result), nil
+}
+
+func TestHarnessExecute(t *testing.T) {
+	h := New(t, &greeter{}).MustInitialize(map[string]interface{}{"greeting": "hello"})
+
+	shared := map[string]interface{}{"user": "ada", "team": "sre"}
+	exec := h.Execute(shared)
+	require.NoError(t, exec.Err)
+	assert.Equal(t, "hello ada", exec.Result)
+	assert.Equal(t, "greeter: hello ada", exec.Format())
+	assert.Equal(t, "ada", shared["user"], "the caller's map is left as is")
+
+	exec.AssertShared("greeted", "ada")
+	exec.AssertOnlyChanged("user", "greeted")
+	assert.Equal(t, Changes{Set: map[string]interface{}{"greeted": "ada"}, Removed: []string{"user"}}, exec.Changes())
+	h.AssertLogged(logrus.InfoLevel, "greeting ada")
+	assert.Empty(t, h.Messages(logrus.ErrorLevel))
+	assert.Equal(t, 1.0, h.Metric("greetings_total", map[string]string{"team": "sre"}))
+	assert.Equal(t, 0.0, h.Metric("greetings_total", map[string]string{"team": "dev"}))
+}
+
+func TestHarnessInitializeChecksManifest(t *testing.T) {
+	err := New(t, &greeter{}).Initialize(map[string]interface{}{"greting": "hi"})
+	assert.ErrorContains(t, err, "missing required property 'greeting'")
+}
+
+func TestHarnessRunFlow(t *testing.T) {
+	h := New(t, &greeter{}).MustInitialize(map[string]interface{}{"greeting": "hi"})
+
+	run := h.RunFlow(v1beta1.Step{
+		Inputs:  map[string]string{"user": "{{ steps.lookup.result.name }}"},
+		Outputs: map[string]string{"text": "{{ result }}"},
+	}, nil, Upstream{ID: "lookup", Result: map[string]interface{}{"name": "grace"}})
+
+	require.Empty(t, run.Step.Error)
+	assert.Equal(t, "hi grace", run.Step.Result)
+	assert.Equal(t, "greeter: hi grace", run.Step.Formatted)
+	assert.Equal(t, map[string]interface{}{"text": "hi grace"}, run.Step.Outputs)
+	require.NotNil(t, run.Execution)
+	assert.Equal(t, map[string]interface{}{"name": "grace"}, run.Execution.Before["_input"])
+	run.Execution.AssertShared("greeted", "grace")
+
+	failed := h.RunFlow(v1beta1.Step{}, nil, Upstream{ID: "lookup", Err: errors.New("directory down")})
+	assert.Equal(t, "Skipped due to dependency failure", failed.Step.Error)
+	assert.Contains(t, failed.Steps["lookup"].Error, "directory down")
+	assert.Nil(t, failed.Execution)
+}
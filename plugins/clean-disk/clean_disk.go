@@ -1,5 +1,5 @@
 // plugins/clean-disk/clean_disk.go
-package main
+package cleandisk
 
 import (
 	"context"
@@ -7,25 +7,21 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 
 	"github.com/sirupsen/logrus"
 )
 
-// DefaultConfig define valores por defecto para el plugin
-var DefaultConfig = struct {
-	ThresholdMB    int64
-	TargetDirPath  string
-	AgeThresholdH  int
-	DryRun         bool
-	DeletePatterns []string
-}{
-	ThresholdMB:    1000,   // 1GB
-	TargetDirPath:  "/tmp", // Default to clean /tmp
-	AgeThresholdH:  24,     // 24 hours (1 day)
-	DryRun:         false,
-	DeletePatterns: []string{"*.tmp", "*.log.*"},
+// Config is the config block of the plugin
+type Config struct {
+	ThresholdMB    int64    `yaml:"threshold_mb" default:"1000" description:"disk usage in MB that triggers a cleanup"`
+	TargetDirPath  string   `yaml:"target_dir" default:"/tmp" description:"directory to clean"`
+	AgeThresholdH  int      `yaml:"age_hours" default:"24" description:"only delete files older than this"`
+	DryRun         bool     `yaml:"dry_run" default:"false" description:"report what would be deleted without deleting"`
+	DeletePatterns []string `yaml:"delete_patterns" default:"*.tmp,*.log.*" description:"glob patterns of files to delete"`
 }
 
 type CleanDiskPlugin struct {
@@ -42,49 +38,30 @@ func (p *CleanDiskPlugin) Initialize(ctx context.Context, config map[string]inte
 	p.logger = logger
 	p.logger.Info("Initializing Clean Disk Plugin")
 
-	// Set default values
-	p.thresholdMB = DefaultConfig.ThresholdMB
-	p.targetDirPath = DefaultConfig.TargetDirPath
-	p.ageThresholdH = DefaultConfig.AgeThresholdH
-	p.dryRun = DefaultConfig.DryRun
-	p.patterns = DefaultConfig.DeletePatterns
-
-	// Override with config if provided
-	if threshold, ok := config["threshold_mb"].(float64); ok {
-		p.thresholdMB = int64(threshold)
-		p.logger.Infof("Setting threshold to %d MB", p.thresholdMB)
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
 
-	if targetDir, ok := config["target_dir"].(string); ok && targetDir != "" {
-		p.targetDirPath = targetDir
-		p.logger.Infof("Setting target directory to: %s", p.targetDirPath)
-	}
-
-	if ageHours, ok := config["age_hours"].(float64); ok {
-		p.ageThresholdH = int(ageHours)
-		p.logger.Infof("Setting age threshold to %d hours", p.ageThresholdH)
-	}
-
-	if dryRun, ok := config["dry_run"].(bool); ok {
-		p.dryRun = dryRun
-		p.logger.Infof("Dry run mode: %v", p.dryRun)
-	}
-
-	if patterns, ok := config["delete_patterns"].([]interface{}); ok && len(patterns) > 0 {
-		p.patterns = make([]string, 0, len(patterns))
-		for _, pattern := range patterns {
-			if patternStr, ok := pattern.(string); ok && patternStr != "" {
-				p.patterns = append(p.patterns, patternStr)
-			}
-		}
-		p.logger.Infof("Setting delete patterns to: %v", p.patterns)
-	}
+	p.thresholdMB = cfg.ThresholdMB
+	p.targetDirPath = cfg.TargetDirPath
+	p.ageThresholdH = cfg.AgeThresholdH
+	p.dryRun = cfg.DryRun
+	p.patterns = cfg.DeletePatterns
+	p.logger.Infof("Cleaning %s: threshold %d MB, files older than %d hours matching %v, dry run: %v",
+		p.targetDirPath, p.thresholdMB, p.ageThresholdH, p.patterns, p.dryRun)
 
 	return nil
 }
 
+// ConfigSchema describes the config block of the plugin
+func (p *CleanDiskPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 // Execute performs disk cleanup based on age
 func (p *CleanDiskPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
 	result := struct {
 		DryRun       bool     `json:"dry_run"`
 		FilesDeleted int      `json:"files_deleted"`
@@ -98,22 +75,22 @@ func (p *CleanDiskPlugin) Execute(ctx context.Context, request *http.Request, sh
 	}
 
 	// Limpia el directorio objetivo
-	p.logger.Infof("Cleaning files older than %d hours in %s", p.ageThresholdH, p.targetDirPath)
+	logger.Infof("Cleaning files older than %d hours in %s", p.ageThresholdH, p.targetDirPath)
 
 	if p.targetDirPath == "" || p.targetDirPath == "/" {
 		return nil, fmt.Errorf("invalid target directory: %s", p.targetDirPath)
 	}
 
-	err := cleanDirectory(p.targetDirPath, p.logger)
+	err := cleanDirectory(p.targetDirPath, logger)
 	if err != nil {
-		p.logger.Errorf("Error cleaning directory %s: %v", p.targetDirPath, err)
+		logger.Errorf("Error cleaning directory %s: %v", p.targetDirPath, err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func cleanDirectory(dir string, logger *logrus.Logger) error {
+func cleanDirectory(dir string, logger *logrus.Entry) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		return nil
 	}
@@ -155,4 +132,6 @@ func (p *CleanDiskPlugin) FormatResult(result interface{}) (string, error) {
 	return fmt.Sprintf("%v", result), nil
 }
 
-var PluginInstance pluginconf.Plugin = &CleanDiskPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("clean-disk", func() pluginconf.Plugin { return &CleanDiskPlugin{} })
+}
@@ -0,0 +1,255 @@
+// plugins/exec/exec.go
+package execplugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
+	"expressops/internal/tmpl"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxOutputSize bounds the stdout and stderr kept from a run
+const maxOutputSize = 1 << 20
+
+// Output modes besides auto, which parses stdout when it is JSON
+const (
+	outputJSON = "json"
+	outputText = "text"
+)
+
+// ExecPlugin runs a command, or an inline script through a shell. Its args
+// and env are Go templates rendered with the step context, e.g. {{.username}}.
+type ExecPlugin struct {
+	logger *logrus.Logger
+
+	command string   // resolved executable
+	prefix  []string // args passed as is, e.g. the script
+	args    []*template.Template
+	env     map[string]*template.Template
+	dir     string
+	timeout time.Duration
+	output  string
+}
+
+// Config is the config block of the plugin
+type Config struct {
+	Command   string            `yaml:"command" description:"executable to run, looked up in PATH"`
+	Script    string            `yaml:"script" description:"inline script run by the shell, receiving args as $1, $2, ..."`
+	Shell     string            `yaml:"shell" default:"/bin/sh" description:"shell running the script"`
+	Args      []string          `yaml:"args" description:"arguments, as templates rendered with the step context"`
+	Env       map[string]string `yaml:"env" description:"extra environment variables, as templates rendered with the step context"`
+	Dir       string            `yaml:"dir" description:"working directory"`
+	Timeout   time.Duration     `yaml:"timeout" default:"30s" description:"bound on a run, e.g. 10s"`
+	Output    string            `yaml:"output" enum:"auto,json,text" default:"auto" description:"how stdout is parsed into the result output"`
+	Allowlist []string          `yaml:"allowlist" description:"executables (names or paths) the plugin may run"`
+}
+
+// Result is what a run of the command returns
+type Result struct {
+	Command    string      `json:"command"`
+	ExitCode   int         `json:"exit_code"`
+	Stdout     string      `json:"stdout"`
+	Stderr     string      `json:"stderr"`
+	Output     interface{} `json:"output,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+func (p *ExecPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	p.logger = logger
+	p.logger.Info("Initializing Exec Plugin")
+
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	command := cfg.Command
+	switch {
+	case cfg.Command != "" && cfg.Script != "":
+		return fmt.Errorf("set either command or script, not both")
+	case cfg.Command == "" && cfg.Script == "":
+		return fmt.Errorf("a command or a script is required")
+	case cfg.Script != "":
+		// the script gets the rendered args as $1, $2, ...
+		command = cfg.Shell
+		p.prefix = []string{"-c", cfg.Script, "expressops-exec"}
+	}
+
+	var err error
+	if p.command, err = exec.LookPath(command); err != nil {
+		return fmt.Errorf("executable '%s' not found: %w", command, err)
+	}
+	if len(cfg.Allowlist) > 0 && !allowed(command, cfg.Allowlist) && !allowed(p.command, cfg.Allowlist) {
+		return fmt.Errorf("executable '%s' is not in the allowlist %v", command, cfg.Allowlist)
+	}
+
+	p.args = make([]*template.Template, len(cfg.Args))
+	for i, arg := range cfg.Args {
+		if p.args[i], err = tmpl.Parse(fmt.Sprintf("args[%d]", i), arg); err != nil {
+			return err
+		}
+	}
+
+	p.env = make(map[string]*template.Template, len(cfg.Env))
+	for k, v := range cfg.Env {
+		if p.env[k], err = tmpl.Parse("env."+k, v); err != nil {
+			return err
+		}
+	}
+
+	p.dir = cfg.Dir
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout '%s'", cfg.Timeout)
+	}
+	p.timeout = cfg.Timeout
+	p.output = cfg.Output
+
+	p.logger.Infof("Exec Plugin will run %s", p.command)
+	return nil
+}
+
+// ConfigSchema describes the config block of the plugin
+func (p *ExecPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
+// Execute runs the command and returns its Result. A non-zero exit code, a
+// timeout or, with output json, unparsable stdout are errors.
+func (p *ExecPlugin) Execute(ctx context.Context, _ *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	args := append([]string{}, p.prefix...)
+	for _, t := range p.args {
+		arg, err := tmpl.Render(t, *shared)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	env := os.Environ()
+	for k, t := range p.env {
+		v, err := tmpl.Render(t, *shared)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, k+"="+v)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, p.command, args...)
+	cmd.Dir = p.dir
+	cmd.Env = env
+	// do not wait forever on pipes held open by children of the command
+	cmd.WaitDelay = time.Second
+
+	stdout := &cappedBuffer{limit: maxOutputSize}
+	stderr := &cappedBuffer{limit: maxOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	logger.Debugf("Exec Plugin running %s %v", p.command, args)
+	start := time.Now()
+	err := cmd.Run()
+
+	result := &Result{
+		Command:    p.command,
+		ExitCode:   cmd.ProcessState.ExitCode(),
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case runCtx.Err() != nil:
+			return nil, fmt.Errorf("command timed out after %s", p.timeout)
+		case errors.As(err, &exitErr):
+			return nil, fmt.Errorf("command exited with code %d: %s", result.ExitCode, lastLine(result.Stderr))
+		default:
+			return nil, fmt.Errorf("error running command: %w", err)
+		}
+	}
+
+	if p.output != outputText {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(result.Stdout), &parsed); err == nil {
+			result.Output = parsed
+		} else if p.output == outputJSON {
+			return nil, fmt.Errorf("command output is not valid JSON: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (p *ExecPlugin) FormatResult(result interface{}) (string, error) {
+	res, ok := result.(*Result)
+	if !ok {
+		return fmt.Sprintf("%v", result), nil
+	}
+	out := strings.TrimSpace(res.Stdout)
+	if out == "" {
+		out = "(no output)"
+	}
+	return fmt.Sprintf("%s exited with code %d in %dms:\n%s", filepath.Base(res.Command), res.ExitCode, res.DurationMs, out), nil
+}
+
+// allowed reports whether the executable, as configured or as resolved, is
+// in the allowlist. A name in the allowlist only matches a command given by
+// name; a path only matches that exact path.
+func allowed(command string, allowlist []string) bool {
+	for _, entry := range allowlist {
+		if command == entry {
+			return true
+		}
+	}
+	return false
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(data []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(data) > room {
+			b.Buffer.Write(data[:room])
+		} else {
+			b.Buffer.Write(data)
+		}
+	}
+	return len(data), nil
+}
+
+func init() {
+	pluginconf.RegisterBuiltin("exec", func() pluginconf.Plugin { return &ExecPlugin{} })
+}
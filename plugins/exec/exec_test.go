@@ -0,0 +1,124 @@
+package execplugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlugin(t *testing.T, config map[string]interface{}) *ExecPlugin {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	p := &ExecPlugin{}
+	require.NoError(t, p.Initialize(context.Background(), config, logger))
+	return p
+}
+
+func TestExecCommandWithTemplatedArgs(t *testing.T) {
+	p := newPlugin(t, map[string]interface{}{
+		"command": "echo",
+		"args":    []interface{}{"hello", "{{.username}}"},
+	})
+
+	shared := map[string]any{"username": "ada"}
+	res, err := p.Execute(context.Background(), nil, &shared)
+	require.NoError(t, err)
+
+	result := res.(*Result)
+	assert.Equal(t, "hello ada\n", result.Stdout)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Nil(t, result.Output, "plain text is not parsed")
+
+	formatted, err := p.FormatResult(res)
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "echo exited with code 0")
+	assert.Contains(t, formatted, "hello ada")
+}
+
+func TestExecScript(t *testing.T) {
+	p := newPlugin(t, map[string]interface{}{
+		"script": `printf '{"user": "%s", "home": "%s"}' "$1" "$HOME_BASE/$1"`,
+		"args":   []interface{}{"{{.username}}"},
+		"env":    map[string]interface{}{"HOME_BASE": "{{.base}}"},
+		"output": "json",
+	})
+
+	shared := map[string]any{"username": "ada", "base": "/home"}
+	res, err := p.Execute(context.Background(), nil, &shared)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "ada", "home": "/home/ada"}, res.(*Result).Output)
+}
+
+func TestExecFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		shared   map[string]any
+		expected string
+	}{
+		{
+			name:     "non-zero exit",
+			config:   map[string]interface{}{"script": "echo boom >&2; exit 3"},
+			expected: "command exited with code 3: boom",
+		},
+		{
+			name:     "timeout",
+			config:   map[string]interface{}{"command": "sleep", "args": []interface{}{"5"}, "timeout": "100ms"},
+			expected: "command timed out after 100ms",
+		},
+		{
+			name:     "missing template key",
+			config:   map[string]interface{}{"command": "echo", "args": []interface{}{"{{.missing}}"}},
+			expected: `map has no entry for key "missing"`,
+		},
+		{
+			name:     "invalid json",
+			config:   map[string]interface{}{"command": "echo", "args": []interface{}{"not json"}, "output": "json"},
+			expected: "command output is not valid JSON",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlugin(t, tc.config)
+			shared := tc.shared
+			if shared == nil {
+				shared = map[string]any{}
+			}
+			start := time.Now()
+			_, err := p.Execute(context.Background(), nil, &shared)
+			assert.ErrorContains(t, err, tc.expected)
+			assert.Less(t, time.Since(start), 3*time.Second)
+		})
+	}
+}
+
+func TestExecInitializeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{"nothing to run", map[string]interface{}{}, "a command or a script is required"},
+		{"both", map[string]interface{}{"command": "echo", "script": "true"}, "not both"},
+		{"unknown executable", map[string]interface{}{"command": "no-such-command-expressops"}, "not found"},
+		{"not allowlisted", map[string]interface{}{"command": "echo", "allowlist": []interface{}{"kubectl"}}, "is not in the allowlist"},
+		{"shell not allowlisted", map[string]interface{}{"script": "true", "allowlist": []interface{}{"echo"}}, "is not in the allowlist"},
+		{"bad template", map[string]interface{}{"command": "echo", "args": []interface{}{"{{.x"}}, "invalid template in args[0]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&ExecPlugin{}).Initialize(context.Background(), tc.config, logrus.New())
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+
+	p := newPlugin(t, map[string]interface{}{"command": "echo", "allowlist": []interface{}{"echo"}})
+	assert.NotEmpty(t, p.command)
+}
@@ -1,4 +1,4 @@
-package main
+package flowlister
 
 import (
 	"context"
@@ -7,7 +7,7 @@ import (
 	"sort"
 	"strings"
 
-	"expressops/api/v1alpha1"
+	"expressops/api/v1beta1"
 	pluginconf "expressops/internal/plugin/loader"
 
 	"github.com/sirupsen/logrus"
@@ -19,7 +19,7 @@ type FlowListerPlugin struct {
 }
 
 // FlowRegistry shared with the server package
-var FlowRegistry map[string]v1alpha1.Flow
+var FlowRegistry map[string]v1beta1.Flow
 
 func (p *FlowListerPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
 	p.logger = logger
@@ -30,14 +30,15 @@ func (p *FlowListerPlugin) Initialize(ctx context.Context, config map[string]int
 }
 
 func (p *FlowListerPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
-	p.logger.Info("Flow Lister Plugin executing")
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	logger.Info("Flow Lister Plugin executing")
 
-	var flows map[string]v1alpha1.Flow
-	if registry, ok := (*shared)["flow_registry"].(map[string]v1alpha1.Flow); ok {
+	var flows map[string]v1beta1.Flow
+	if registry, ok := (*shared)["flow_registry"].(map[string]v1beta1.Flow); ok {
 		flows = registry
 	} else {
-		p.logger.Warn("Flow registry not found in shared map")
-		flows = make(map[string]v1alpha1.Flow)
+		logger.Warn("Flow registry not found in shared map")
+		flows = make(map[string]v1beta1.Flow)
 	}
 
 	// Create a result object with the list of flows
@@ -63,9 +64,13 @@ func (p *FlowListerPlugin) Execute(ctx context.Context, request *http.Request, s
 
 	for _, name := range flowNames {
 		flow := flows[name]
+		description := flow.Description
+		if description == "" {
+			description = flow.CustomHandler
+		}
 		flowInfo := map[string]interface{}{
 			"name":         name,
-			"description":  flow.CustomHandler,
+			"description":  description,
 			"plugin_count": len(flow.Pipeline),
 			"plugins":      []string{},
 		}
@@ -78,13 +83,24 @@ func (p *FlowListerPlugin) Execute(ctx context.Context, request *http.Request, s
 		}
 		flowInfo["plugins"] = plugins
 
+		// Describe the plugins that ship a manifest
+		manifests := make(map[string]pluginconf.Manifest)
+		for _, plugin := range plugins {
+			if manifest, ok := pluginconf.GetManifest(plugin); ok && manifest.Name != "" {
+				manifests[plugin] = manifest
+			}
+		}
+		if len(manifests) > 0 {
+			flowInfo["manifests"] = manifests
+		}
+
 		flowList = append(flowList, flowInfo)
 
 		flowLine := fmt.Sprintf("📋 %s", name)
 		logLines = append(logLines, flowLine)
 
-		if flow.CustomHandler != "" {
-			descLine := fmt.Sprintf("   Description: %s", flow.CustomHandler)
+		if description != "" {
+			descLine := fmt.Sprintf("   Description: %s", description)
 			logLines = append(logLines, descLine)
 		}
 
@@ -95,6 +111,9 @@ func (p *FlowListerPlugin) Execute(ctx context.Context, request *http.Request, s
 				pluginsLine += " → "
 			}
 			pluginsLine += plugin
+			if manifest, ok := manifests[plugin]; ok && manifest.Version != "" {
+				pluginsLine += "@" + manifest.Version
+			}
 		}
 
 		logLines = append(logLines, pluginsLine)
@@ -107,7 +126,7 @@ func (p *FlowListerPlugin) Execute(ctx context.Context, request *http.Request, s
 	return result, nil
 }
 
-// Format the result for separate log lines
+// Format the result as one line per flow detail
 func (p *FlowListerPlugin) FormatResult(result interface{}) (string, error) {
 	if result == nil {
 		return "No flow information available", nil
@@ -124,9 +143,9 @@ func (p *FlowListerPlugin) FormatResult(result interface{}) (string, error) {
 		return "Could not format flow list", nil
 	}
 
-	// Use special separator that will be recognized by the server
-	return "__MULTILINE_LOG__" + strings.Join(logLines, "__MULTILINE_LOG__"), nil
+	return strings.Join(logLines, "\n"), nil
 }
 
-// important to export the plugin, same interface as the other plugins
-var PluginInstance pluginconf.Plugin = &FlowListerPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("flow-lister", func() pluginconf.Plugin { return &FlowListerPlugin{} })
+}
@@ -1,21 +1,28 @@
-package main
+package formatters
 
 import (
 	"context"
 	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"expressops/internal/metrics"
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 
 	"github.com/sirupsen/logrus"
 )
 
 // ThresholdLevels defines the threshold levels for metrics
 type ThresholdLevels struct {
-	Warning  float64
-	Critical float64
+	Warning  float64 `yaml:"warning" minimum:"0" maximum:"100" description:"percentage at which the value is flagged as WARNING"`
+	Critical float64 `yaml:"critical" minimum:"0" maximum:"100" description:"percentage at which the value is flagged as CRITICAL"`
+}
+
+// Config is the config block of the plugin
+type Config struct {
+	Thresholds map[string]ThresholdLevels `yaml:"thresholds" description:"alert levels per resource (cpu, memory, disk)"`
 }
 
 // DefaultThresholds provides default values for different metrics
@@ -50,33 +57,41 @@ func (f *FormatterPlugin) Initialize(ctx context.Context, config map[string]inte
 		f.thresholds[k] = v
 	}
 
-	if thresholdsConfig, ok := config["thresholds"].(map[string]interface{}); ok {
-		logFields["thresholdsConfigured"] = true
-		for metricType, thresholdValues := range thresholdsConfig {
-			if values, ok := thresholdValues.(map[string]interface{}); ok {
-				threshold := ThresholdLevels{}
-				if warning, ok := values["warning"].(float64); ok {
-					threshold.Warning = warning
-				}
-				if critical, ok := values["critical"].(float64); ok {
-					threshold.Critical = critical
-				}
-				f.thresholds[metricType] = threshold
-				f.logger.WithFields(logFields).WithFields(logrus.Fields{
-					"metricType":        metricType,
-					"warningThreshold":  threshold.Warning,
-					"criticalThreshold": threshold.Critical,
-				}).Debug("Umbral personalizado configurado")
-			}
-		}
-	} else {
-		logFields["thresholdsConfigured"] = false
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	logFields["thresholdsConfigured"] = len(cfg.Thresholds) > 0
+	for metricType, threshold := range cfg.Thresholds {
+		f.thresholds[metricType] = threshold
+		f.logger.WithFields(logFields).WithFields(logrus.Fields{
+			"metricType":        metricType,
+			"warningThreshold":  threshold.Warning,
+			"criticalThreshold": threshold.Critical,
+		}).Debug("Umbral personalizado configurado")
 	}
 
 	f.logger.WithFields(logFields).Info("HealthAlertFormatterPlugin inicializado")
 	return nil
 }
 
+// Manifest describes the plugin and the shared keys it reads and writes
+func (f *FormatterPlugin) Manifest() pluginconf.Manifest {
+	return pluginconf.Manifest{
+		Name:        "health-alert-formatter",
+		Description: "Turns health check results into an alert message and severity",
+		APIVersion:  pluginconf.APIVersion,
+		Inputs:      []string{"_input", "kube_health_results", "previous_result"},
+		Outputs:     []string{"message", "severity"},
+	}
+}
+
+// ConfigSchema describes the config block of the plugin
+func (f *FormatterPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 func (f *FormatterPlugin) formatPercentage(value float64, metricType string, forLog bool) string {
 	threshold, exists := f.thresholds[metricType]
 	if !exists {
@@ -101,6 +116,7 @@ func (f *FormatterPlugin) formatSize(value uint64) string {
 
 // Execute formats health data for display and notification
 func (f *FormatterPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, f.logger)
 	pluginName := "HealthAlertFormatterPlugin"
 	flowName := request.URL.Query().Get("flowName")
 	baseLogFields := logrus.Fields{
@@ -108,10 +124,10 @@ func (f *FormatterPlugin) Execute(ctx context.Context, request *http.Request, sh
 		"action":     "Execute",
 		"flowName":   flowName,
 	}
-	f.logger.WithFields(baseLogFields).Info("Formateando resultados de health check")
+	logger.WithFields(baseLogFields).Info("Formateando resultados de health check")
 
 	if _, ok := (*shared)["_input"].(map[string]interface{}); !ok {
-		f.logger.Error("No valid _input received")
+		logger.Error("No valid _input received")
 		metrics.IncFormattingOperation("health_alert", "error_input")
 		return "", fmt.Errorf("no valid _input received")
 	}
@@ -122,25 +138,25 @@ func (f *FormatterPlugin) Execute(ctx context.Context, request *http.Request, sh
 	)
 
 	if kubeResults, ok := (*shared)["kube_health_results"].([]map[string]string); ok {
-		f.logger.WithFields(baseLogFields).Info("Procesando datos de Kubernetes health")
-		formattedMessage, err = f.formatKubernetesHealth(kubeResults, shared, baseLogFields)
+		logger.WithFields(baseLogFields).Info("Procesando datos de Kubernetes health")
+		formattedMessage, err = f.formatKubernetesHealth(logger, kubeResults, shared, baseLogFields)
 	} else if prev, ok := (*shared)["previous_result"]; ok {
-		f.logger.WithFields(baseLogFields).Info("Procesando 'previous_result'")
+		logger.WithFields(baseLogFields).Info("Procesando 'previous_result'")
 		if podResults, ok := prev.([]map[string]string); ok {
-			f.logger.WithFields(baseLogFields).Info("Procesando resultados de pod desde plugin anterior")
-			formattedMessage, err = f.formatKubernetesHealth(podResults, shared, baseLogFields)
+			logger.WithFields(baseLogFields).Info("Procesando resultados de pod desde plugin anterior")
+			formattedMessage, err = f.formatKubernetesHealth(logger, podResults, shared, baseLogFields)
 		} else if healthMap, ok := prev.(map[string]interface{}); ok {
-			f.logger.WithFields(baseLogFields).Info("Procesando datos generales de health desde plugin anterior")
-			formattedMessage, err = f.formatHealthData(healthMap, shared, baseLogFields)
+			logger.WithFields(baseLogFields).Info("Procesando datos generales de health desde plugin anterior")
+			formattedMessage, err = f.formatHealthData(logger, healthMap, shared, baseLogFields)
 		} else {
 			errMsg := "Formato de 'previous_result' no reconocido"
-			f.logger.WithFields(baseLogFields).WithField("previousResultType", fmt.Sprintf("%T", prev)).Error(errMsg)
+			logger.WithFields(baseLogFields).WithField("previousResultType", fmt.Sprintf("%T", prev)).Error(errMsg)
 			formattedMessage = defaultMessage
 			(*shared)["message"] = defaultMessage
 			(*shared)["severity"] = "info" // Reset severity
 		}
 	} else {
-		f.logger.WithFields(baseLogFields).Info("No se encontraron datos de health específicos, usando mensaje por defecto.")
+		logger.WithFields(baseLogFields).Info("No se encontraron datos de health específicos, usando mensaje por defecto.")
 		formattedMessage = defaultMessage
 		(*shared)["message"] = defaultMessage
 		(*shared)["severity"] = "info"
@@ -150,19 +166,19 @@ func (f *FormatterPlugin) Execute(ctx context.Context, request *http.Request, sh
 		return nil, err
 	}
 
-	f.logger.WithFields(baseLogFields).WithField("formattedMessageLength", len(formattedMessage.(string))).Info("Formateo completado")
+	logger.WithFields(baseLogFields).WithField("formattedMessageLength", len(formattedMessage.(string))).Info("Formateo completado")
 	return formattedMessage, nil
 }
 
 // formatHealthData formats general health check data
-func (f *FormatterPlugin) formatHealthData(input map[string]interface{}, shared *map[string]any, baseLogFields logrus.Fields) (interface{}, error) {
+func (f *FormatterPlugin) formatHealthData(logger *logrus.Entry, input map[string]interface{}, shared *map[string]any, baseLogFields logrus.Fields) (interface{}, error) {
 	var logFormatted strings.Builder
 	logFormatted.WriteString("Health check: ")
 	var alertFormatted strings.Builder
 	alertFormatted.WriteString("\n✨ Health Status Report ✨\n\n")
 
 	if _, ok := input["health_status"].(map[string]string); !ok {
-		f.logger.Error("Result without health_status field")
+		logger.Error("Result without health_status field")
 		metrics.IncFormattingOperation("health_alert", "error_status")
 		return "", fmt.Errorf("health check result must contain a health_status field")
 	}
@@ -185,13 +201,13 @@ func (f *FormatterPlugin) formatHealthData(input map[string]interface{}, shared
 				checksOK = false
 				hasErrors = true
 				alertFormatted.WriteString(fmt.Sprintf("  %s: ❌ %s\n", k, v))
-				f.logger.WithFields(sectionLogFields).WithFields(logrus.Fields{"checkName": k, "checkStatus": v}).Warn("Health check fallido")
+				logger.WithFields(sectionLogFields).WithFields(logrus.Fields{"checkName": k, "checkStatus": v}).Warn("Health check fallido")
 			}
 		}
 		logFormatted.WriteString(fmt.Sprintf("Checks:%s ", map[bool]string{true: "OK", false: "FAIL"}[checksOK]))
 		alertFormatted.WriteString("\n")
 	} else {
-		f.logger.WithFields(sectionLogFields).Debug("No hay datos de 'health_status' disponibles")
+		logger.WithFields(sectionLogFields).Debug("No hay datos de 'health_status' disponibles")
 		alertFormatted.WriteString("🔍 Health Checks: No check data available\n\n")
 	}
 
@@ -283,7 +299,7 @@ func (f *FormatterPlugin) formatHealthData(input map[string]interface{}, shared
 	message := alertFormatted.String()
 	(*shared)["message"] = message
 
-	f.logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
+	logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
 		"logSummary":    logFormatted.String(),
 		"finalSeverity": (*shared)["severity"],
 	}).Debug("Resumen de log de health")
@@ -292,7 +308,7 @@ func (f *FormatterPlugin) formatHealthData(input map[string]interface{}, shared
 }
 
 // formatKubernetesHealth formats Kubernetes health check results
-func (f *FormatterPlugin) formatKubernetesHealth(kubeResults []map[string]string, shared *map[string]any, baseLogFields logrus.Fields) (interface{}, error) {
+func (f *FormatterPlugin) formatKubernetesHealth(logger *logrus.Entry, kubeResults []map[string]string, shared *map[string]any, baseLogFields logrus.Fields) (interface{}, error) {
 	var sb strings.Builder
 	totalPods := len(kubeResults)
 	problemPods := 0
@@ -303,7 +319,7 @@ func (f *FormatterPlugin) formatKubernetesHealth(kubeResults []map[string]string
 	}
 	sectionLogFields["dataType"] = "kubernetesHealth"
 
-	f.logger.WithFields(sectionLogFields).WithField("podCount", totalPods).Info("Formateando datos de health de Kubernetes")
+	logger.WithFields(sectionLogFields).WithField("podCount", totalPods).Info("Formateando datos de health de Kubernetes")
 
 	sb.WriteString("\n🚢 Kubernetes Health Report 🚢\n\n")
 	sb.WriteString("Pod Status:\n")
@@ -314,7 +330,7 @@ func (f *FormatterPlugin) formatKubernetesHealth(kubeResults []map[string]string
 		name := pod["name"]
 		if emoji != "✅" {
 			problemPods++
-			f.logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
+			logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
 				"podName":   name,
 				"podStatus": status,
 				"podEmoji":  emoji,
@@ -331,14 +347,14 @@ func (f *FormatterPlugin) formatKubernetesHealth(kubeResults []map[string]string
 		sb.WriteString(fmt.Sprintf("  Problem pods: %d 🔴\n", problemPods))
 		sb.WriteString("\n⚠️ Issues detected! Please check your Kubernetes cluster.\n")
 		(*shared)["severity"] = "warning"
-		f.logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
+		logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
 			"problemPodCount": problemPods,
 			"finalSeverity":   "warning",
 		}).Warn("Problems detectados en pods de Kubernetes")
 	} else {
 		sb.WriteString("\n✅ All pods are healthy!\n")
 		(*shared)["severity"] = "info"
-		f.logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
+		logger.WithFields(sectionLogFields).WithFields(logrus.Fields{
 			"finalSeverity": "info",
 		}).Info("Todos los pods de Kubernetes saludables")
 	}
@@ -364,5 +380,6 @@ func (f *FormatterPlugin) FormatResult(result interface{}) (string, error) {
 	return fmt.Sprintf("%v", result), nil
 }
 
-// Export the plugin instance
-var PluginInstance pluginconf.Plugin = &FormatterPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("health-alert-formatter", func() pluginconf.Plugin { return &FormatterPlugin{} })
+}
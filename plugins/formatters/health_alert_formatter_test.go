@@ -0,0 +1,41 @@
+package formatters
+
+import (
+	"testing"
+
+	"expressops/api/v1beta1"
+	"expressops/pkg/plugintest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatHealthDataFromUpstream(t *testing.T) {
+	h := plugintest.New(t, &FormatterPlugin{}).MustInitialize(map[string]interface{}{
+		"thresholds": map[string]interface{}{
+			"cpu": map[string]interface{}{"warning": 50, "critical": 90},
+		},
+	})
+
+	run := h.RunFlow(v1beta1.Step{}, nil, plugintest.Upstream{
+		ID: "health-check-plugin",
+		Result: map[string]interface{}{
+			"health_status": map[string]string{"cpu": "OK"},
+			"cpu":           map[string]interface{}{"usage_percent": 75.0},
+		},
+	})
+
+	require.Empty(t, run.Step.Error)
+	message, ok := run.Step.Result.(string)
+	require.True(t, ok)
+	assert.Contains(t, message, "Issues detected")
+	run.Execution.AssertShared("message", message)
+}
+
+func TestFormatterRequiresInput(t *testing.T) {
+	h := plugintest.New(t, &FormatterPlugin{}).MustInitialize(nil)
+
+	exec := h.Execute(map[string]interface{}{})
+	assert.EqualError(t, exec.Err, "no valid _input received")
+	exec.AssertOnlyChanged()
+}
@@ -1,15 +1,17 @@
-package main
+package healthcheck
 
 import (
 	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
 
 	"expressops/internal/metrics"
+	"expressops/internal/schema"
 
 	pluginconf "expressops/internal/plugin/loader"
 
@@ -29,6 +31,12 @@ var DefaultThresholds = map[string]float64{
 	"disk":   90.0,
 }
 
+// Config is the config block of the plugin
+type Config struct {
+	Thresholds map[string]float64 `yaml:"thresholds" minimum:"0" maximum:"100" description:"alert thresholds per resource (cpu, memory, disk), as usage percentages"`
+	Path       string             `yaml:"path" default:"/" description:"main path whose disk usage is reported"`
+}
+
 type HealthCheckPlugin struct {
 	logger      *logrus.Logger
 	checks      map[string]func() error
@@ -45,32 +53,27 @@ func (p *HealthCheckPlugin) Initialize(ctx context.Context, config map[string]in
 		"action":     "Initialize",
 	}
 
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
 	p.checks = make(map[string]func() error)
 	p.thresholds = make(map[string]float64)
 	for k, v := range DefaultThresholds {
 		p.thresholds[k] = v
 	}
 
-	if thresholdsConf, ok := config["thresholds"].(map[string]interface{}); ok {
-		logFields["thresholdsConfigured"] = true
-		for metricType, value := range thresholdsConf {
-			if threshold, ok := value.(float64); ok {
-				p.thresholds[metricType] = threshold
-				p.logger.WithFields(logFields).WithFields(logrus.Fields{
-					"metricType": metricType,
-					"threshold":  threshold,
-				}).Debug("Umbral personalizado configurado")
-			}
-		}
-	} else {
-		logFields["thresholdsConfigured"] = false
+	logFields["thresholdsConfigured"] = len(cfg.Thresholds) > 0
+	for metricType, threshold := range cfg.Thresholds {
+		p.thresholds[metricType] = threshold
+		p.logger.WithFields(logFields).WithFields(logrus.Fields{
+			"metricType": metricType,
+			"threshold":  threshold,
+		}).Debug("Umbral personalizado configurado")
 	}
 
-	if path, ok := config["path"].(string); ok {
-		p.pathToCheck = path
-	} else {
-		p.pathToCheck = "/" // Default path
-	}
+	p.pathToCheck = cfg.Path
 	logFields["pathToCheck"] = p.pathToCheck
 
 	p.logger.WithFields(logFields).Info("HealthCheckPlugin inicializado")
@@ -84,6 +87,7 @@ func (p *HealthCheckPlugin) Initialize(ctx context.Context, config map[string]in
 }
 
 func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
 	pluginName := "HealthCheckPlugin"
 	flowName := request.URL.Query().Get("flowName")
 	hostname, _ := os.Hostname()
@@ -94,7 +98,7 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 		"flowName":   flowName,
 		"hostname":   hostname,
 	}
-	p.logger.WithFields(logFields).Info("Iniciando health check")
+	logger.WithFields(logFields).Info("Iniciando health check")
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -146,7 +150,7 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 				!strings.HasPrefix(part.Device, "/dev/nvme") &&
 				!strings.HasPrefix(part.Device, "/dev/mapper") &&
 				part.Fstype != "fuse.portal" {
-				p.logger.Debugf("Skipping non-standard partition: %s (Device: %s, Fstype: %s)", part.Mountpoint, part.Device, part.Fstype)
+				logger.Debugf("Skipping non-standard partition: %s (Device: %s, Fstype: %s)", part.Mountpoint, part.Device, part.Fstype)
 				continue
 			}
 
@@ -171,15 +175,15 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 				}
 				// <--- END UPDATE --->
 			} else {
-				p.logger.Warnf("Could not get disk usage for %s: %v", part.Mountpoint, err)
+				logger.Warnf("Could not get disk usage for %s: %v", part.Mountpoint, err)
 			}
 		}
 		result["disk"] = diskInfo
 		if worstMountPoint != "" { // Ensure we have a valid mount point
 			metrics.SetResourceUsage("disk", worstMountPoint, maxDiskUsageForGauge)
-			p.logger.Debugf("Set disk resource usage gauge: Mount='%s', Usage=%.2f%%", worstMountPoint, maxDiskUsageForGauge)
+			logger.Debugf("Set disk resource usage gauge: Mount='%s', Usage=%.2f%%", worstMountPoint, maxDiskUsageForGauge)
 		} else {
-			p.logger.Debug("No suitable disk mount point found to report for resource usage gauge.")
+			logger.Debug("No suitable disk mount point found to report for resource usage gauge.")
 		}
 	}
 	result["disk"] = diskResults
@@ -188,7 +192,7 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 	healthStatus := make(map[string]string)
 
 	for name, check := range p.checks {
-		p.logger.Infof("Running check: %s", name)
+		logger.Infof("Running check: %s", name)
 		statusLabel := "ok"
 		checkSpecificLogFields := logrus.Fields{
 			"pluginName": "HealthCheckPlugin",
@@ -197,13 +201,13 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 
 		if err := check(); err != nil {
 			statusLabel = "fail"
-			p.logger.Warnf("Check failed: %s - %v", name, err) // we use warnf because it's not an error, it's a warning
+			logger.Warnf("Check failed: %s - %v", name, err) // we use warnf because it's not an error, it's a warning
 
 			healthStatus[name] = fmt.Sprintf("FAIL: %v", err)
 			allChecksOK = false
 		} else {
 			healthStatus[name] = "OK"
-			p.logger.WithFields(checkSpecificLogFields).Debug("Chequeo de health OK")
+			logger.WithFields(checkSpecificLogFields).Debug("Chequeo de health OK")
 		}
 		metrics.IncHealthCheckPerformed(name, statusLabel)
 	}
@@ -228,9 +232,9 @@ func (p *HealthCheckPlugin) Execute(ctx context.Context, request *http.Request,
 	}
 
 	if allChecksOK {
-		p.logger.WithFields(finalLogFieldsMap).Info("Health check completado exitosamente, todos los chequeos OK")
+		logger.WithFields(finalLogFieldsMap).Info("Health check completado exitosamente, todos los chequeos OK")
 	} else {
-		p.logger.WithFields(finalLogFieldsMap).Warn("Health check completado, algunos chequeos fallaron")
+		logger.WithFields(finalLogFieldsMap).Warn("Health check completado, algunos chequeos fallaron")
 	}
 
 	return result, nil
@@ -303,6 +307,11 @@ func (p *HealthCheckPlugin) FormatResult(_ interface{}) (string, error) {
 	return "Health check completed", nil
 }
 
+// ConfigSchema describes the config block of the plugin
+func (p *HealthCheckPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 func NewHealthCheckPlugin(logger *logrus.Logger) pluginconf.Plugin {
 	return &HealthCheckPlugin{
 		logger:     logger,
@@ -311,4 +320,6 @@ func NewHealthCheckPlugin(logger *logrus.Logger) pluginconf.Plugin {
 	}
 }
 
-var PluginInstance pluginconf.Plugin = NewHealthCheckPlugin(logrus.New())
+func init() {
+	pluginconf.RegisterBuiltin("health-check", func() pluginconf.Plugin { return NewHealthCheckPlugin(logrus.New()) })
+}
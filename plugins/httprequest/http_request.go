@@ -0,0 +1,357 @@
+// plugins/httprequest/http_request.go
+package httprequest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
+	"expressops/internal/tmpl"
+	"expressops/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxResponseSize bounds the response body read from the server
+const maxResponseSize = 4 << 20
+
+// HTTPRequestPlugin performs a configured HTTP call. The URL, headers and body
+// are Go templates rendered with the step context, e.g. {{.username}}; the
+// `json` template function encodes a value as JSON.
+type HTTPRequestPlugin struct {
+	logger *logrus.Logger
+	client *http.Client
+
+	method   string
+	url      *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+	username string
+	password string
+	token    string
+	expected []int
+	extract  map[string]string
+}
+
+// Result is what a call returns
+type Result struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	// Body is the decoded JSON body, or the raw body when it is not JSON
+	Body       interface{} `json:"body"`
+	DurationMs int64       `json:"duration_ms"`
+}
+
+// Config is the config block of the plugin
+type Config struct {
+	Method         string            `yaml:"method" default:"GET" description:"HTTP method"`
+	URL            string            `yaml:"url" required:"true" description:"URL, as a template rendered with the step context"`
+	Headers        map[string]string `yaml:"headers" description:"request headers, as templates rendered with the step context"`
+	Body           string            `yaml:"body" description:"request body, as a template rendered with the step context"`
+	Auth           *AuthConfig       `yaml:"auth"`
+	TLS            *TLSConfig        `yaml:"tls"`
+	Timeout        time.Duration     `yaml:"timeout" default:"30s" description:"bound on the call, e.g. 10s"`
+	ExpectedStatus []int             `yaml:"expected_status" description:"accepted status codes, any 2xx when empty"`
+	Extract        map[string]string `yaml:"extract" description:"shared keys set from the JSON response, by dotted path, e.g. data.items.0.id"`
+}
+
+// AuthConfig holds basic or bearer credentials, given inline (typically
+// from an environment variable) or in a file such as a mounted secret
+type AuthConfig struct {
+	Type         string `yaml:"type" required:"true" enum:"basic,bearer"`
+	Username     string `yaml:"username" description:"basic auth user"`
+	Password     string `yaml:"password" secret:"true" description:"basic auth password, e.g. $API_PASSWORD"`
+	PasswordFile string `yaml:"password_file" description:"file holding the basic auth password"`
+	Token        string `yaml:"token" secret:"true" description:"bearer token, e.g. $API_TOKEN"`
+	TokenFile    string `yaml:"token_file" description:"file holding the bearer token"`
+}
+
+// TLSConfig sets the CAs trusted for the server and a client certificate
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file" description:"PEM bundle of the CAs trusted for the server"`
+	CertFile           string `yaml:"cert_file" description:"client certificate"`
+	KeyFile            string `yaml:"key_file" description:"client key"`
+	ServerName         string `yaml:"server_name" description:"name checked against the server certificate"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" description:"skip server certificate checks"`
+}
+
+func (p *HTTPRequestPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
+	p.logger = logger
+	p.logger.Info("Initializing HTTP Request Plugin")
+
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	var err error
+	if p.url, err = tmpl.Parse("url", cfg.URL); err != nil {
+		return err
+	}
+
+	p.method = strings.ToUpper(cfg.Method)
+
+	p.headers = make(map[string]*template.Template, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		if p.headers[k], err = tmpl.Parse("headers."+k, v); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Body != "" {
+		if p.body, err = tmpl.Parse("body", cfg.Body); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Auth != nil {
+		if err := p.configureAuth(cfg.Auth); err != nil {
+			return fmt.Errorf("invalid auth: %w", err)
+		}
+	}
+
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout '%s'", cfg.Timeout)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS != nil {
+		if transport.TLSClientConfig, err = buildTLSConfig(cfg.TLS); err != nil {
+			return fmt.Errorf("invalid tls: %w", err)
+		}
+	}
+	p.client = &http.Client{Timeout: cfg.Timeout, Transport: transport}
+
+	p.expected = cfg.ExpectedStatus
+	p.extract = cfg.Extract
+
+	p.logger.Infof("HTTP Request Plugin will call %s %s", p.method, cfg.URL)
+	return nil
+}
+
+// configureAuth reads the basic or bearer credentials
+func (p *HTTPRequestPlugin) configureAuth(auth *AuthConfig) error {
+	switch auth.Type {
+	case "basic":
+		password, err := secretValue(auth.Password, auth.PasswordFile, "password")
+		if err != nil {
+			return err
+		}
+		if auth.Username == "" {
+			return fmt.Errorf("basic auth requires a username")
+		}
+		p.username = auth.Username
+		p.password = password
+	case "bearer":
+		token, err := secretValue(auth.Token, auth.TokenFile, "token")
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return fmt.Errorf("bearer auth requires a token or a token_file")
+		}
+		p.token = token
+	}
+	return nil
+}
+
+// secretValue returns value, or the trimmed content of the file at path
+func secretValue(value, path, key string) (string, error) {
+	if value != "" || path == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s_file: %w", key, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	pluginconf.RegisterSecret(secret)
+	return secret, nil
+}
+
+func buildTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		ServerName:         config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in '%s'", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ConfigSchema describes the config block of the plugin
+func (p *HTTPRequestPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
+// Execute performs the call. An unexpected status code or an extract path
+// missing from the response are errors.
+func (p *HTTPRequestPlugin) Execute(ctx context.Context, _ *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	url, err := tmpl.Render(p.url, *shared)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if p.body != nil {
+		rendered, err := tmpl.Render(p.body, *shared)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(rendered)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, p.method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, t := range p.headers {
+		v, err := tmpl.Render(t, *shared)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+	tracing.Inject(ctx, req.Header)
+	switch {
+	case p.token != "":
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	case p.username != "":
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	logger.Debugf("HTTP Request Plugin calling %s %s", p.method, url)
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	result := &Result{
+		StatusCode: resp.StatusCode,
+		Headers:    make(map[string]string, len(resp.Header)),
+		Body:       string(raw),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	for k := range resp.Header {
+		result.Headers[k] = resp.Header.Get(k)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err == nil {
+		result.Body = decoded
+	}
+
+	if !p.statusExpected(resp.StatusCode) {
+		return nil, fmt.Errorf("unexpected status %d from %s: %.200s", resp.StatusCode, url, string(raw))
+	}
+
+	keys := make([]string, 0, len(p.extract))
+	for key := range p.extract {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := lookup(result.Body, p.extract[key])
+		if err != nil {
+			return nil, fmt.Errorf("extract '%s': %w", key, err)
+		}
+		(*shared)[key] = value
+	}
+
+	return result, nil
+}
+
+func (p *HTTPRequestPlugin) statusExpected(code int) bool {
+	if len(p.expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, expected := range p.expected {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *HTTPRequestPlugin) FormatResult(result interface{}) (string, error) {
+	res, ok := result.(*Result)
+	if !ok {
+		return fmt.Sprintf("%v", result), nil
+	}
+	body, err := json.MarshalIndent(res.Body, "", "  ")
+	if err != nil {
+		body = []byte(fmt.Sprint(res.Body))
+	}
+	return fmt.Sprintf("%s %d in %dms:\n%s", p.method, res.StatusCode, res.DurationMs, body), nil
+}
+
+// lookup follows a dotted path of map keys and list indexes in a decoded
+// JSON document
+func lookup(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("path '%s' not found in response", path)
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("path '%s' not found in response", path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("path '%s' not found in response", path)
+		}
+	}
+	return current, nil
+}
+
+func init() {
+	pluginconf.RegisterBuiltin("http-request", func() pluginconf.Plugin { return &HTTPRequestPlugin{} })
+}
@@ -0,0 +1,158 @@
+package httprequest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlugin(t *testing.T, config map[string]interface{}) *HTTPRequestPlugin {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	p := &HTTPRequestPlugin{}
+	require.NoError(t, p.Initialize(context.Background(), config, logger))
+	return p
+}
+
+func TestHTTPRequestPost(t *testing.T) {
+	var got struct {
+		method, path, auth, header string
+		body                       map[string]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path = r.Method, r.URL.Path
+		got.auth, got.header = r.Header.Get("Authorization"), r.Header.Get("X-Team")
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &got.body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"data": {"items": [{"id": "u-42"}]}}`))
+	}))
+	defer srv.Close()
+
+	p := newPlugin(t, map[string]interface{}{
+		"method":          "post",
+		"url":             srv.URL + "/users/{{.username}}",
+		"headers":         map[string]interface{}{"X-Team": "{{.team}}"},
+		"body":            `{"name": {{json .username}}, "groups": {{json .groups}}}`,
+		"auth":            map[string]interface{}{"type": "bearer", "token": "s3cret"},
+		"expected_status": []interface{}{201.0}, // as decoded from JSON
+		"extract":         map[string]interface{}{"user_id": "data.items.0.id"},
+	})
+
+	shared := map[string]any{"username": "ada", "team": "sre", "groups": []string{"dev"}}
+	res, err := p.Execute(context.Background(), nil, &shared)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPost, got.method)
+	assert.Equal(t, "/users/ada", got.path)
+	assert.Equal(t, "Bearer s3cret", got.auth)
+	assert.Equal(t, "sre", got.header)
+	assert.Equal(t, map[string]interface{}{"name": "ada", "groups": []interface{}{"dev"}}, got.body)
+
+	result := res.(*Result)
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+	assert.Equal(t, "application/json", result.Headers["Content-Type"])
+	assert.Equal(t, "u-42", shared["user_id"])
+}
+
+func TestHTTPRequestBasicAuthFromFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "ops" || pass != "from-file" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+
+	p := newPlugin(t, map[string]interface{}{
+		"url":  srv.URL,
+		"auth": map[string]interface{}{"type": "basic", "username": "ops", "password_file": passwordFile},
+	})
+	shared := map[string]any{}
+	res, err := p.Execute(context.Background(), nil, &shared)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", res.(*Result).Body)
+}
+
+func TestHTTPRequestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	shared := map[string]any{}
+	_, err := newPlugin(t, map[string]interface{}{"url": srv.URL}).Execute(context.Background(), nil, &shared)
+	assert.ErrorContains(t, err, "certificate")
+
+	insecure := newPlugin(t, map[string]interface{}{
+		"url": srv.URL,
+		"tls": map[string]interface{}{"insecure_skip_verify": true},
+	})
+	_, err = insecure.Execute(context.Background(), nil, &shared)
+	assert.NoError(t, err)
+}
+
+func TestHTTPRequestFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": []}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{"unexpected status", map[string]interface{}{"url": srv.URL + "/missing"}, "unexpected status 404"},
+		{"missing extract path", map[string]interface{}{"url": srv.URL, "extract": map[string]interface{}{"id": "data.0.id"}}, "extract 'id': path 'data.0.id' not found"},
+		{"missing template key", map[string]interface{}{"url": srv.URL + "/{{.nope}}"}, `map has no entry for key "nope"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shared := map[string]any{}
+			_, err := newPlugin(t, tc.config).Execute(context.Background(), nil, &shared)
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
+
+func TestHTTPRequestInitializeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{"no url", map[string]interface{}{}, "missing required property 'url'"},
+		{"unknown auth", map[string]interface{}{"url": "http://x", "auth": map[string]interface{}{"type": "digest"}}, "auth.type: must be one of [basic bearer]"},
+		{"bearer without token", map[string]interface{}{"url": "http://x", "auth": map[string]interface{}{"type": "bearer"}}, "requires a token"},
+		{"bad timeout", map[string]interface{}{"url": "http://x", "timeout": "never"}, "timeout: invalid duration 'never'"},
+		{"status not an integer", map[string]interface{}{"url": "http://x", "expected_status": []interface{}{"201"}}, "expected_status[0]: expected integer, got string"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&HTTPRequestPlugin{}).Initialize(context.Background(), tc.config, logrus.New())
+			assert.ErrorContains(t, err, tc.expected)
+		})
+	}
+}
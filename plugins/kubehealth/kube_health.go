@@ -1,43 +1,57 @@
 // plugins/kubehealth/kube_health.go
-package main
+package kubehealth
 
 import (
 	"context"
 	"fmt"
 	"net/http"
 	"os/exec"
+	"reflect"
 	"strings"
 	"time"
 
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Config is the config block of the plugin
+type Config struct {
+	Namespace string `yaml:"namespace" default:"default" description:"namespace whose pods are checked"`
+}
+
 type KubeHealthPlugin struct {
-	logger *logrus.Logger
-	config map[string]interface{}
+	logger    *logrus.Logger
+	namespace string
 }
 
 // Initialize sets up the plugin with logger and configuration
 func (p *KubeHealthPlugin) Initialize(_ context.Context, config map[string]interface{}, logger *logrus.Logger) error {
 
 	p.logger = logger
-	p.config = config
 	p.logger.Info("Initializing KubeHealth Plugin")
+
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	p.namespace = cfg.Namespace
 	return nil
 }
 
+// ConfigSchema describes the config block of the plugin
+func (p *KubeHealthPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 // Execute connects to Kubernetes and retrieves pod status information
 func (p *KubeHealthPlugin) Execute(ctx context.Context, _ *http.Request, shared *map[string]any) (interface{}, error) {
 
-	namespace := "default"
-	if ns, ok := p.config["namespace"].(string); ok && ns != "" {
-		namespace = ns
-	}
+	namespace := p.namespace
 
 	// Run kubectl command
-	cmd := exec.Command("kubectl", "get", "pods", "-n", namespace)
+	cmd := exec.CommandContext(ctx, "kubectl", "get", "pods", "-n", namespace)
 	output, err := cmd.CombinedOutput()
 
 	// Handle command errors
@@ -210,5 +224,6 @@ func (p *KubeHealthPlugin) FormatResult(result interface{}) (string, error) {
 	return sb.String(), nil
 }
 
-// exporting
-var PluginInstance pluginconf.Plugin = &KubeHealthPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("kube-health", func() pluginconf.Plugin { return &KubeHealthPlugin{} })
+}
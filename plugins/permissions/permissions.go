@@ -1,4 +1,4 @@
-package main
+package permissions
 
 import (
 	"context"
@@ -6,10 +6,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"expressops/internal/metrics"
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,15 +19,12 @@ import (
 // Feature toggle - set to 0 to disable permissions and show GCP message
 var enablePermissionsFeature = 0
 
-// to change it
-var DefaultConfig = struct {
-	DefaultUsername    string
-	DefaultPaths       []string
-	DefaultPermissions string
-}{
-	DefaultUsername:    "example-user",
-	DefaultPaths:       []string{"it-school-2025-2-nacho", "it-school-2025-3-david"},
-	DefaultPermissions: "rwx",
+// Config is the config block of the plugin
+type Config struct {
+	DefaultUsername    string   `yaml:"default_username" default:"example-user" description:"user whose permissions are changed"`
+	DefaultPaths       []string `yaml:"default_paths" default:"it-school-2025-2-nacho,it-school-2025-3-david" description:"paths to change when the flow gets none"`
+	DefaultPermissions string   `yaml:"default_permissions" default:"rwx" description:"permissions to grant, e.g. rwx"`
+	BaseDirectory      string   `yaml:"base_directory" description:"directory the paths are relative to"`
 }
 
 type PermissionsPlugin struct {
@@ -42,45 +41,33 @@ func (p *PermissionsPlugin) Initialize(ctx context.Context, config map[string]in
 	p.config = config
 	p.logger.Info("Initializing Permissions Plugin")
 
-	p.defaultUsername = DefaultConfig.DefaultUsername
-	p.defaultPaths = DefaultConfig.DefaultPaths
-	p.defaultPerms = DefaultConfig.DefaultPermissions
-
-	if username, ok := config["default_username"].(string); ok && username != "" {
-		p.defaultUsername = username
-		p.logger.Infof("Setting default username to: %s", username)
-	}
-
-	if paths, ok := config["default_paths"].([]interface{}); ok && len(paths) > 0 {
-		p.defaultPaths = make([]string, 0, len(paths))
-		for _, path := range paths {
-			if pathStr, ok := path.(string); ok && pathStr != "" {
-				p.defaultPaths = append(p.defaultPaths, pathStr)
-			}
-		}
-		p.logger.Infof("Setting default paths to: %v", p.defaultPaths)
-	}
-
-	if perms, ok := config["default_permissions"].(string); ok && perms != "" {
-		p.defaultPerms = perms
-		p.logger.Infof("Setting default permissions to: %s", perms)
-	}
-
-	if baseDir, ok := config["base_directory"].(string); ok && baseDir != "" {
-		p.baseDir = baseDir
-		p.logger.Infof("Setting base directory to: %s", baseDir)
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
+	p.defaultUsername = cfg.DefaultUsername
+	p.defaultPaths = cfg.DefaultPaths
+	p.defaultPerms = cfg.DefaultPermissions
+	p.baseDir = cfg.BaseDirectory
+	p.logger.Infof("Default username: %s, paths: %v, permissions: %s, base directory: %s",
+		p.defaultUsername, p.defaultPaths, p.defaultPerms, p.baseDir)
 
 	return nil
 }
 
+// ConfigSchema describes the config block of the plugin
+func (p *PermissionsPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 func (p *PermissionsPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
-	p.logger.Info("Executing Permissions Plugin")
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	logger.Info("Executing Permissions Plugin")
 
 	// temporary message
 	// will use the GCP integration in the future
 	if enablePermissionsFeature == 0 {
-		p.logger.Info("Permissions feature is disabled, returning GCP integration message")
+		logger.Info("Permissions feature is disabled, returning GCP integration message")
 		metrics.IncPermissionsChange("all", "simulation", "simulation")
 		message := map[string]interface{}{
 			"message": "🚧 Coming soon: Integration with Google Cloud Platform (GCP) 🚧\n\n" +
@@ -186,4 +173,6 @@ func (p *PermissionsPlugin) FormatResult(result interface{}) (string, error) {
 	return sb.String(), nil
 }
 
-var PluginInstance pluginconf.Plugin = &PermissionsPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("permissions", func() pluginconf.Plugin { return &PermissionsPlugin{} })
+}
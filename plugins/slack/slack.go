@@ -1,5 +1,5 @@
 // plugins/slack/slack.go
-package main
+package slack
 
 import (
 	"bytes"
@@ -8,14 +8,22 @@ import (
 	pluginconf "expressops/internal/plugin/loader"
 
 	"expressops/internal/metrics"
+	"expressops/internal/schema"
+	"expressops/internal/tracing"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Config is the config block of the plugin
+type Config struct {
+	WebhookURL string `yaml:"webhook_url" required:"true" secret:"true" description:"Slack incoming webhook URL"`
+}
+
 type SlackPlugin struct {
 	webhook string
 	logger  *logrus.Logger
@@ -26,9 +34,8 @@ func (s *SlackPlugin) Initialize(_ context.Context, config map[string]interface{
 	s.logger = logger
 	pluginName := "SlackPlugin" // Definir nombre para logs
 
-	webhook, ok := config["webhook_url"].(string)
-	if !ok || webhook == "" {
-		err := fmt.Errorf("slack webhook URL required")
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
 		s.logger.WithFields(logrus.Fields{
 			"pluginName": pluginName,
 			"action":     "InitializeFail",
@@ -36,7 +43,7 @@ func (s *SlackPlugin) Initialize(_ context.Context, config map[string]interface{
 		}).Error("Configuración requerida faltante")
 		return err
 	}
-	s.webhook = webhook
+	s.webhook = cfg.WebhookURL
 
 	s.logger.WithFields(logrus.Fields{
 		"pluginName":        pluginName,
@@ -48,6 +55,7 @@ func (s *SlackPlugin) Initialize(_ context.Context, config map[string]interface{
 
 // Execute sends a message to a Slack channel
 func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
+	logger := pluginconf.LoggerFromContext(ctx, s.logger)
 	pluginName := "SlackPlugin"
 	flowName := request.URL.Query().Get("flowName") // Obtener flowName si está disponible
 
@@ -61,29 +69,29 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 	// Try to get message from shared context
 	if msgVal, ok := (*shared)["message"]; ok {
 		if msgStr, ok := msgVal.(string); ok {
-			s.logger.WithFields(logFields).WithField("source", "shared.message").Debugf("Mensaje obtenido: %.50s...", msgStr)
+			logger.WithFields(logFields).WithField("source", "shared.message").Debugf("Mensaje obtenido: %.50s...", msgStr)
 			message = msgStr
 		} else {
-			s.logger.WithFields(logFields).WithField("sourceType", fmt.Sprintf("%T", msgVal)).Warn("shared[\"message\"] existe pero no es string")
+			logger.WithFields(logFields).WithField("sourceType", fmt.Sprintf("%T", msgVal)).Warn("shared[\"message\"] existe pero no es string")
 		}
 	}
 
 	if message == "" {
-		s.logger.WithFields(logFields).Debug("shared[\"message\"] no encontrado o vacío, verificando shared[\"previous_result\"]")
+		logger.WithFields(logFields).Debug("shared[\"message\"] no encontrado o vacío, verificando shared[\"previous_result\"]")
 		if prevResult, ok := (*shared)["previous_result"]; ok {
 			if prevStr, ok := prevResult.(string); ok {
-				s.logger.WithFields(logFields).WithField("source", "shared.previous_result_string").Debugf("Mensaje obtenido: %.50s...", prevStr)
+				logger.WithFields(logFields).WithField("source", "shared.previous_result_string").Debugf("Mensaje obtenido: %.50s...", prevStr)
 				message = prevStr
 			} else {
 				message = fmt.Sprintf("%v", prevResult) // Convertir a string
-				s.logger.WithFields(logFields).WithField("source", "shared.previous_result_converted").Debugf("Mensaje obtenido: %.50s...", message)
+				logger.WithFields(logFields).WithField("source", "shared.previous_result_converted").Debugf("Mensaje obtenido: %.50s...", message)
 			}
 		}
 	}
 
 	if message == "" {
 		err := fmt.Errorf("no hay mensaje para enviar a Slack")
-		s.logger.WithFields(logFields).WithField("error", err.Error()).Error("Mensaje vacío")
+		logger.WithFields(logFields).WithField("error", err.Error()).Error("Mensaje vacío")
 		return nil, err
 	}
 
@@ -95,11 +103,11 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 
 			channelLabel = channelStr
 		} else {
-			s.logger.Warnf("shared[\"channel\"] exists but is not a string (type: %T), using default webhook channel", channelVal)
+			logger.Warnf("shared[\"channel\"] exists but is not a string (type: %T), using default webhook channel", channelVal)
 			channelLabel = "default"
 		}
 	} else {
-		s.logger.Debug("shared[\"channel\"] not found, using default webhook channel")
+		logger.Debug("shared[\"channel\"] not found, using default webhook channel")
 		channelLabel = "default"
 
 	}
@@ -109,12 +117,12 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 		if severityStr, ok := severityVal.(string); ok {
 			severity = severityStr
 		} else {
-			s.logger.WithFields(logFields).WithField("severityType", fmt.Sprintf("%T", severityVal)).Warnf("shared[\"severity\"] no es string, se usará '%s'", severity)
+			logger.WithFields(logFields).WithField("severityType", fmt.Sprintf("%T", severityVal)).Warnf("shared[\"severity\"] no es string, se usará '%s'", severity)
 		}
 	}
 	logFields["severity"] = severity
 
-	s.logger.WithFields(logFields).WithField("messageLength", len(message)).Info("Intentando enviar notificación a Slack")
+	logger.WithFields(logFields).WithField("messageLength", len(message)).Info("Intentando enviar notificación a Slack")
 
 	payload := map[string]interface{}{"text": message}
 	if channel != "" {
@@ -123,7 +131,7 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		s.logger.WithFields(logFields).WithFields(logrus.Fields{
+		logger.WithFields(logFields).WithFields(logrus.Fields{
 			"action": "ExecuteFail",
 			"step":   "MarshalPayload",
 			"error":  err.Error(),
@@ -133,7 +141,7 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhook, bytes.NewBuffer(payloadBytes))
 	if err != nil {
-		s.logger.WithFields(logFields).WithFields(logrus.Fields{
+		logger.WithFields(logFields).WithFields(logrus.Fields{
 			"action": "ExecuteFail",
 			"step":   "CreateRequest",
 			"error":  err.Error(),
@@ -141,6 +149,7 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
@@ -151,14 +160,14 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 	if err != nil {
 
 		statusLabel = "error_request"
-		s.logger.Errorf("Error sending message to Slack: %v", err)
+		logger.Errorf("Error sending message to Slack: %v", err)
 		metrics.IncSlackNotification(statusLabel, channelLabel)
 
 		return nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
-			s.logger.WithError(err).Error("Error closing response body")
+			logger.WithError(err).Error("Error closing response body")
 		}
 	}()
 
@@ -166,7 +175,7 @@ func (s *SlackPlugin) Execute(ctx context.Context, request *http.Request, shared
 		statusLabel = "error_api"
 		bodyBytes, _ := io.ReadAll(resp.Body)
 
-		s.logger.Errorf("Error in Slack API response: %s - Body: %s", resp.Status, string(bodyBytes))
+		logger.Errorf("Error in Slack API response: %s - Body: %s", resp.Status, string(bodyBytes))
 		metrics.IncSlackNotification(statusLabel, channelLabel)
 		return nil, fmt.Errorf("slack API error: %s", resp.Status)
 	}
@@ -188,5 +197,21 @@ func (s *SlackPlugin) FormatResult(result interface{}) (string, error) {
 	return fmt.Sprintf("Slack Result: %v", result), nil
 }
 
-// PluginInstance follows the original implementation
-var PluginInstance pluginconf.Plugin = &SlackPlugin{}
+// Manifest describes the plugin and the shared keys it reads
+func (s *SlackPlugin) Manifest() pluginconf.Manifest {
+	return pluginconf.Manifest{
+		Name:        "slack",
+		Description: "Sends a message to a Slack incoming webhook",
+		APIVersion:  pluginconf.APIVersion,
+		Inputs:      []string{"message", "previous_result", "channel", "severity"},
+	}
+}
+
+// ConfigSchema describes the config block of the plugin
+func (s *SlackPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
+func init() {
+	pluginconf.RegisterBuiltin("slack", func() pluginconf.Plugin { return &SlackPlugin{} })
+}
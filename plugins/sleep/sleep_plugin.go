@@ -1,43 +1,57 @@
-package main
+package sleep
 
 import (
 	"context"
 	"expressops/internal/metrics"
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 	"fmt"
 	"net/http"
+	"reflect"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Config is the config block of the plugin
+type Config struct {
+	DurationSeconds int `yaml:"duration_seconds" default:"10" minimum:"1" description:"how long each run sleeps, in seconds"`
+}
+
 type SleepPlugin struct {
-	logger *logrus.Logger
+	logger   *logrus.Logger
+	duration time.Duration
 }
 
 func (p *SleepPlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
 	p.logger = logger
 	p.logger.Info("Initializing Sleep Plugin")
+
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	p.duration = time.Duration(cfg.DurationSeconds) * time.Second
 	return nil
 }
 
-func (p *SleepPlugin) Execute(ctx context.Context, req *http.Request, shared *map[string]any) (any, error) {
-	p.logger.Info("Sleep Plugin starting to sleep")
-
-	configDuration, ok := (*shared)["duration_seconds"].(int)
-	if !ok || configDuration <= 0 {
-		configDuration = 10
-	}
+// ConfigSchema describes the config block of the plugin
+func (p *SleepPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
 
-	duration := time.Duration(configDuration) * time.Second
+func (p *SleepPlugin) Execute(ctx context.Context, req *http.Request, shared *map[string]any) (any, error) {
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	logger.Info("Sleep Plugin starting to sleep")
 
+	duration := p.duration
 	select {
 	case <-time.After(duration):
-		p.logger.Info("Sleep Plugin finished successfully")
-		metrics.ObserveSleepDuration(float64(configDuration))
+		logger.Info("Sleep Plugin finished successfully")
+		metrics.ObserveSleepDuration(duration.Seconds())
 		return fmt.Sprintf("Slept for %.0f seconds", duration.Seconds()), nil
 	case <-ctx.Done():
-		p.logger.Warn("Sleep Plugin has been canceled!")
+		logger.Warn("Sleep Plugin has been canceled!")
 		metrics.ObserveSleepDuration(0)
 		return nil, ctx.Err()
 	}
@@ -47,4 +61,6 @@ func (p *SleepPlugin) FormatResult(result interface{}) (string, error) {
 	return fmt.Sprintf("Sleep Plugin Result: %v", result), nil
 }
 
-var PluginInstance pluginconf.Plugin = &SleepPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("sleep", func() pluginconf.Plugin { return &SleepPlugin{} })
+}
@@ -1,30 +1,138 @@
-// This is the format that a plugin MUST FOLLOW
-package template
+// Package scaffold holds the template of a new plugin and renders it for
+// `expressops plugin new`. The templates in files/ are the format that a
+// plugin MUST FOLLOW: a builtin registered from init(), its config decoded
+// and validated in Initialize, a manifest and a test using pkg/plugintest.
+package scaffold
 
 import (
-	"context"
+	"bytes"
+	"embed"
 	"fmt"
-	"net/http"
-
-	pluginconf "expressops/internal/plugin/loader"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
 
-	"github.com/sirupsen/logrus"
+// Plugin kinds, each with its own Execute example
+const (
+	KindNotification = "notification"
+	KindCheck        = "check"
+	KindAction       = "action"
 )
 
-type TemplatePlugin struct {
-	logger *logrus.Logger
+// Kinds lists the supported plugin kinds
+var Kinds = []string{KindNotification, KindCheck, KindAction}
+
+//go:embed files/*.tmpl
+var files embed.FS
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// Plugin describes the plugin to generate
+type Plugin struct {
+	// Name is the builtin name, in kebab case, e.g. "ticket-notifier"
+	Name string
+	Kind string
+}
+
+// Package is the Go package name, e.g. "ticketnotifier"
+func (p Plugin) Package() string {
+	return strings.ReplaceAll(p.Name, "-", "")
 }
 
-func (p *TemplatePlugin) Initialize(ctx context.Context, config map[string]interface{}, logger *logrus.Logger) error {
-	p.logger = logger
+// Type is the Go type of the plugin, e.g. "TicketNotifierPlugin"
+func (p Plugin) Type() string {
+	var sb strings.Builder
+	for _, part := range strings.Split(p.Name, "-") {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	sb.WriteString("Plugin")
+	return sb.String()
 }
 
-func (p *TemplatePlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
-	return nil, nil
+// Dir is the directory of the plugin, relative to the repository root
+func (p Plugin) Dir() string {
+	return filepath.Join("plugins", p.Package())
 }
 
-func (p *TemplatePlugin) FormatResult(result interface{}) (string, error) {
-	return fmt.Sprintf("%v", result), nil
+// Validate checks the name and kind of the plugin
+func (p Plugin) Validate() error {
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid plugin name '%s': use lowercase letters, digits and dashes, e.g. ticket-notifier", p.Name)
+	}
+	for _, k := range Kinds {
+		if p.Kind == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown kind '%s' (use %s)", p.Kind, strings.Join(Kinds, ", "))
 }
 
-var PluginInstance pluginconf.Plugin = &TemplatePlugin{}
+// Render returns the generated files by path relative to the repository
+// root. Go files are gofmt-ed.
+func Render(p Plugin) (map[string][]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.ParseFS(files, "files/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := map[string]string{
+		"plugin.go.tmpl":      filepath.Join(p.Dir(), p.Package()+".go"),
+		"plugin_test.go.tmpl": filepath.Join(p.Dir(), p.Package()+"_test.go"),
+		"config.yaml.tmpl":    filepath.Join(p.Dir(), "config.yaml"),
+		"plugin.mk.tmpl":      filepath.Join("makefiles", "plugin-"+p.Name+".mk"),
+	}
+
+	rendered := make(map[string][]byte, len(outputs))
+	for name, path := range outputs {
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, name, p); err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", name, err)
+		}
+		data := buf.Bytes()
+		if strings.HasSuffix(path, ".go") {
+			if data, err = format.Source(data); err != nil {
+				return nil, fmt.Errorf("formatting %s: %w", path, err)
+			}
+		}
+		rendered[path] = data
+	}
+	return rendered, nil
+}
+
+// Generate writes the files of the plugin under root and returns their
+// sorted paths. It refuses to overwrite an existing file.
+func Generate(root string, p Plugin) ([]string, error) {
+	rendered, err := Render(p)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(rendered))
+	for path := range rendered {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(full, rendered[path], 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,71 @@
+package scaffold
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginNames(t *testing.T) {
+	p := Plugin{Name: "ticket-notifier", Kind: KindNotification}
+	assert.Equal(t, "ticketnotifier", p.Package())
+	assert.Equal(t, "TicketNotifierPlugin", p.Type())
+	assert.Equal(t, filepath.Join("plugins", "ticketnotifier"), p.Dir())
+
+	assert.ErrorContains(t, Plugin{Name: "Ticket_Notifier", Kind: KindAction}.Validate(), "invalid plugin name")
+	assert.ErrorContains(t, Plugin{Name: "ticket", Kind: "alert"}.Validate(), "unknown kind 'alert' (use notification, check, action)")
+}
+
+func TestGenerateRefusesToOverwrite(t *testing.T) {
+	root := t.TempDir()
+	p := Plugin{Name: "ticket", Kind: KindAction}
+
+	paths, err := Generate(root, p)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join("makefiles", "plugin-ticket.mk"),
+		filepath.Join("plugins", "ticket", "config.yaml"),
+		filepath.Join("plugins", "ticket", "ticket.go"),
+		filepath.Join("plugins", "ticket", "ticket_test.go"),
+	}, paths)
+
+	_, err = Generate(root, p)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+// TestGeneratedPluginsPass builds and tests a plugin of every kind inside
+// the module, so that the templates keep up with the plugin API
+func TestGeneratedPluginsPass(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go command")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	// testdata is ignored by ./... but can be built explicitly
+	require.NoError(t, os.MkdirAll("testdata", 0o755))
+	root, err := os.MkdirTemp("testdata", "generated-")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(root)
+		os.Remove("testdata") // only if nothing else is in it
+	})
+
+	for _, kind := range Kinds {
+		t.Run(kind, func(t *testing.T) {
+			p := Plugin{Name: "sample-" + kind, Kind: kind}
+			_, err := Generate(root, p)
+			require.NoError(t, err)
+
+			cmd := exec.Command(goBin, "test", "-count=1", "./"+filepath.Join(root, p.Dir()))
+			out, err := cmd.CombinedOutput()
+			assert.NoError(t, err, string(out))
+		})
+	}
+}
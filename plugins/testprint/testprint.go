@@ -1,6 +1,6 @@
 // ignore this file, it's just for testing flow execution
 // :D
-package main
+package testprint
 
 import (
 	"context"
@@ -48,4 +48,6 @@ func (p *TestPrintPlugin) FormatResult(result interface{}) (string, error) {
 	return fmt.Sprintf("%v", result), nil
 }
 
-var PluginInstance pluginconf.Plugin = &TestPrintPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("test-print", func() pluginconf.Plugin { return &TestPrintPlugin{} })
+}
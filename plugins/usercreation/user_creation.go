@@ -1,5 +1,5 @@
 // https://gist.github.com/salrashid123/e894e856c2851fe437eee5fc2b72c8ad
-package main
+package usercreation
 
 import (
 	"context"
@@ -7,27 +7,24 @@ import (
 	"net/http"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"expressops/internal/metrics"
 	pluginconf "expressops/internal/plugin/loader"
+	"expressops/internal/schema"
 
 	"github.com/sirupsen/logrus"
 )
 
 var enableUserCreationFeature = 0
 
-// Default configuration for the plugin
-var DefaultConfig = struct {
-	DefaultUsername    string
-	DefaultGroups      []string
-	DefaultHomeDirBase string
-	DefaultShell       string
-}{
-	DefaultUsername:    "example-user",
-	DefaultGroups:      []string{"users", "developers"},
-	DefaultHomeDirBase: "/home",
-	DefaultShell:       "/bin/bash",
+// Config is the config block of the plugin
+type Config struct {
+	DefaultUsername    string   `yaml:"default_username" default:"example-user" description:"user created when the flow gets no username"`
+	DefaultGroups      []string `yaml:"default_groups" default:"users,developers" description:"groups the user is added to"`
+	DefaultHomeDirBase string   `yaml:"default_homedir_base" default:"/home" description:"parent directory of home directories"`
+	DefaultShell       string   `yaml:"default_shell" default:"/bin/bash" description:"login shell"`
 }
 
 type UserCreationPlugin struct {
@@ -44,47 +41,32 @@ func (p *UserCreationPlugin) Initialize(ctx context.Context, config map[string]i
 	p.config = config
 	p.logger.Info("Initializing User Creation Plugin")
 
-	// Initialize with defaults
-	p.defaultUsername = DefaultConfig.DefaultUsername
-	p.defaultGroups = DefaultConfig.DefaultGroups
-	p.defaultHomeDirBase = DefaultConfig.DefaultHomeDirBase
-	p.defaultShell = DefaultConfig.DefaultShell
-
-	// Override with config if provided
-	if username, ok := config["default_username"].(string); ok && username != "" {
-		p.defaultUsername = username
-		p.logger.Infof("Setting default username to: %s", username)
-	}
-
-	if groups, ok := config["default_groups"].([]interface{}); ok && len(groups) > 0 {
-		p.defaultGroups = make([]string, 0, len(groups))
-		for _, group := range groups {
-			if groupStr, ok := group.(string); ok && groupStr != "" {
-				p.defaultGroups = append(p.defaultGroups, groupStr)
-			}
-		}
-		p.logger.Infof("Setting default groups to: %v", p.defaultGroups)
-	}
-
-	if homeDir, ok := config["default_homedir_base"].(string); ok && homeDir != "" {
-		p.defaultHomeDirBase = homeDir
-		p.logger.Infof("Setting default home directory base to: %s", homeDir)
-	}
-
-	if shell, ok := config["default_shell"].(string); ok && shell != "" {
-		p.defaultShell = shell
-		p.logger.Infof("Setting default shell to: %s", shell)
+	var cfg Config
+	if err := pluginconf.Decode(config, &cfg); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
+	p.defaultUsername = cfg.DefaultUsername
+	p.defaultGroups = cfg.DefaultGroups
+	p.defaultHomeDirBase = cfg.DefaultHomeDirBase
+	p.defaultShell = cfg.DefaultShell
+	p.logger.Infof("Default username: %s, groups: %v, home directory base: %s, shell: %s",
+		p.defaultUsername, p.defaultGroups, p.defaultHomeDirBase, p.defaultShell)
 
 	return nil
 }
 
+// ConfigSchema describes the config block of the plugin
+func (p *UserCreationPlugin) ConfigSchema() *schema.Schema {
+	return schema.FromType(reflect.TypeOf(Config{}))
+}
+
 func (p *UserCreationPlugin) Execute(ctx context.Context, request *http.Request, shared *map[string]any) (interface{}, error) {
-	p.logger.Info("Executing User Creation Plugin")
+	logger := pluginconf.LoggerFromContext(ctx, p.logger)
+	logger.Info("Executing User Creation Plugin")
 
 	// Check feature toggle
 	if enableUserCreationFeature == 0 {
-		p.logger.Info("User creation feature is disabled, returning info message")
+		logger.Info("User creation feature is disabled, returning info message")
 		metrics.IncUserCreation("simulation", "simulation")
 		message := map[string]interface{}{
 			"message": "👤 User Account Creation Service 👤\n\n" +
@@ -127,7 +109,7 @@ func (p *UserCreationPlugin) Execute(ctx context.Context, request *http.Request,
 		shell = shellVal
 	}
 
-	p.logger.Infof("Creating user %s with groups %v, home directory base %s, and shell %s",
+	logger.Infof("Creating user %s with groups %v, home directory base %s, and shell %s",
 		username, groups, homeDirBase, shell)
 
 	// Build the useradd command
@@ -142,12 +124,12 @@ func (p *UserCreationPlugin) Execute(ctx context.Context, request *http.Request,
 
 	if output, err := cmd.CombinedOutput(); err != nil {
 		errMsg := fmt.Sprintf("Failed to create user: %v - %s", err, string(output))
-		p.logger.Error(errMsg)
+		logger.Error(errMsg)
 		metrics.IncUserCreation(username, "error")
 		return nil, fmt.Errorf("user creation error: %s", errMsg)
 	}
 
-	p.logger.Infof("Successfully created user %s", username)
+	logger.Infof("Successfully created user %s", username)
 	metrics.IncUserCreation(username, "success")
 
 	return map[string]interface{}{
@@ -207,4 +189,6 @@ func (p *UserCreationPlugin) FormatResult(result interface{}) (string, error) {
 	return sb.String(), nil
 }
 
-var PluginInstance pluginconf.Plugin = &UserCreationPlugin{}
+func init() {
+	pluginconf.RegisterBuiltin("user-creation", func() pluginconf.Plugin { return &UserCreationPlugin{} })
+}
@@ -31,7 +31,7 @@ while true; do
   print_metric "expressops_cpu_usage_percent"
   print_metric "expressops_memory_usage_bytes"
   print_metric "expressops_storage_usage_bytes"
-  print_metric "expressops_concurrent_plugins"
+  print_metric "expressops_steps_in_progress"
   
   echo -n "Executing health-check: "
   HTTP_CODE=$(curl -s -o /dev/null -w "%{http_code}" "http://$HOST/flow?flowName=health-status-no-format")